@@ -41,7 +41,7 @@ for PLATFORM in "${PLATFORMS[@]}"; do
     GOOS=$GOOS GOARCH=$GOARCH go build \
         -ldflags "-X main.version=${VERSION}" \
         -o "${OUTPUT_DIR}/${OUTPUT_NAME}" \
-        ./cmd/main.go
+        ./cmd
 done
 
 echo ""
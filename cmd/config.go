@@ -0,0 +1,417 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/davedotdev/afk/internal/config"
+	"github.com/davedotdev/afk/internal/output"
+	"github.com/davedotdev/afk/internal/transport"
+)
+
+// cmdConfig inspects and edits the config afk runs with
+func cmdConfig(args []string) int {
+	sub := "show"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		sub, args = args[0], args[1:]
+	}
+
+	switch sub {
+	case "show":
+		return cmdConfigShow(args)
+	case "get":
+		return cmdConfigGet(args)
+	case "set":
+		return cmdConfigSet(args)
+	case "unset":
+		return cmdConfigUnset(args)
+	case "list", "ls":
+		return cmdConfigList(args)
+	case "validate":
+		return cmdConfigValidate(args)
+	case "edit":
+		return cmdConfigEdit(args)
+	}
+	fmt.Fprintf(os.Stderr, "400 Bad Request: Unknown config command %q (use show, get, set, unset, list, validate or edit)\n", sub)
+	return exitBadArgs
+}
+
+// cmdConfigShow prints the effective value of each setting and its source:
+// a flag, an environment variable, a config file or the default. It needs
+// no credentials.
+func cmdConfigShow(args []string) int {
+	fs := flag.NewFlagSet("config show", flag.ContinueOnError)
+	settings := settingFlags(fs)
+	quietFlag := fs.Bool("quiet", false, "Minimal output (just key=value)")
+	profileFlag := fs.String("profile", "", "Config profile to use (default: $AFK_PROFILE)")
+
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitSuccess
+		}
+		return exitBadArgs
+	}
+
+	// Works before login: an API key kept in a store is not fetched, so it
+	// shows as unset with api_key_ref naming where it is
+	cfg, err := loadSettings(*profileFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "400 Bad Request: %v\n", err)
+		return exitBadArgs
+	}
+	if err := applySettingFlags(cfg, settings); err != nil {
+		fmt.Fprintf(os.Stderr, "400 Bad Request: %v\n", err)
+		return exitBadArgs
+	}
+
+	out := output.New(cfg.Format, *quietFlag)
+	out.Settings(config.Path(), cfg.ProjectFile, cfg.Profile, cfg.Settings())
+	return exitSuccess
+}
+
+// cmdConfigGet prints the effective value of one setting
+func cmdConfigGet(args []string) int {
+	keys, args := leadingArgs(args)
+
+	fs := flag.NewFlagSet("config get", flag.ContinueOnError)
+	profileFlag := fs.String("profile", "", "Config profile to use (default: $AFK_PROFILE)")
+	revealFlag := fs.Bool("reveal", false, "Print credentials in full")
+
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitSuccess
+		}
+		return exitBadArgs
+	}
+	keys = append(keys, fs.Args()...)
+	if len(keys) != 1 {
+		fmt.Fprintln(os.Stderr, "400 Bad Request: Usage: afk config get <key>")
+		return exitBadArgs
+	}
+	key := keys[0]
+
+	cfg, err := loadConfig(*profileFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "401 Unauthorized: %v\n", err)
+		return exitBadArgs
+	}
+	value, ok := cfg.Value(key)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "404 Not Found: Unknown setting %q\n", key)
+		return exitBadArgs
+	}
+
+	switch v := value.(type) {
+	case string:
+		if config.IsSecret(key) && !*revealFlag {
+			v = config.MaskSecret(v)
+		}
+		fmt.Println(v)
+	case config.OutputFormat:
+		fmt.Println(v)
+	default:
+		data, _ := json.MarshalIndent(v, "", "  ")
+		fmt.Println(string(data))
+	}
+	return exitSuccess
+}
+
+// cmdConfigSet checks a value and stores it in the config file
+func cmdConfigSet(args []string) int {
+	keys, args := leadingArgs(args)
+
+	fs := flag.NewFlagSet("config set", flag.ContinueOnError)
+	profileFlag := fs.String("profile", "", "Set the value in this profile")
+
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitSuccess
+		}
+		return exitBadArgs
+	}
+	keys = append(keys, fs.Args()...)
+	if len(keys) != 2 {
+		fmt.Fprintln(os.Stderr, "400 Bad Request: Usage: afk config set <key> <value>")
+		return exitBadArgs
+	}
+	key, value := keys[0], keys[1]
+
+	if err := config.CheckSetting(key, value); err != nil {
+		fmt.Fprintf(os.Stderr, "400 Bad Request: %v\n", err)
+		return exitBadArgs
+	}
+	if key == "channel" {
+		if err := checkDefaultChannel(value); err != nil {
+			fmt.Fprintf(os.Stderr, "400 Bad Request: %v\n", err)
+			return exitBadArgs
+		}
+	}
+
+	cfg, err := config.Read()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitBadArgs
+	}
+	if *profileFlag == "" {
+		err = cfg.Set(key, value, config.Path())
+	} else {
+		// Store the typed value, e.g. true rather than "true"
+		var scratch config.Config
+		scratch.Set(key, value, "")
+		typed, _ := scratch.Value(key)
+		err = cfg.SetProfileValues(*profileFlag, map[string]interface{}{key: typed})
+	}
+	if err == nil {
+		err = config.Save(cfg)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitBadArgs
+	}
+
+	if config.IsSecret(key) {
+		value = config.MaskSecret(value)
+	}
+	fmt.Printf("Set %s = %s%s\n", key, value, profileSuffix(*profileFlag))
+	return exitSuccess
+}
+
+// cmdConfigUnset removes a setting from the config file, so its default
+// applies again
+func cmdConfigUnset(args []string) int {
+	keys, args := leadingArgs(args)
+
+	fs := flag.NewFlagSet("config unset", flag.ContinueOnError)
+	profileFlag := fs.String("profile", "", "Remove the value from this profile")
+
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitSuccess
+		}
+		return exitBadArgs
+	}
+	keys = append(keys, fs.Args()...)
+	if len(keys) != 1 {
+		fmt.Fprintln(os.Stderr, "400 Bad Request: Usage: afk config unset <key>")
+		return exitBadArgs
+	}
+	key := keys[0]
+
+	cfg, err := config.Read()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitBadArgs
+	}
+	if *profileFlag == "" {
+		err = cfg.Unset(key)
+	} else if _, ok := cfg.Profiles[*profileFlag]; !ok {
+		err = fmt.Errorf("no profile %q", *profileFlag)
+	} else if err = (&config.Config{}).Unset(key); err == nil {
+		err = cfg.SetProfileValues(*profileFlag, map[string]interface{}{key: nil})
+	}
+	if err == nil {
+		err = config.Save(cfg)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitBadArgs
+	}
+
+	fmt.Printf("Unset %s%s\n", key, profileSuffix(*profileFlag))
+	return exitSuccess
+}
+
+// cmdConfigList prints the single-valued settings stored in the config
+// file, without defaults or overrides
+func cmdConfigList(args []string) int {
+	fs := flag.NewFlagSet("config list", flag.ContinueOnError)
+	profileFlag := fs.String("profile", "", "List the values set in this profile")
+
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitSuccess
+		}
+		return exitBadArgs
+	}
+
+	cfg, err := config.Read()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitBadArgs
+	}
+	if *profileFlag != "" {
+		overrides, ok := cfg.Profiles[*profileFlag]
+		if !ok {
+			fmt.Fprintf(os.Stderr, "404 Not Found: No profile %q\n", *profileFlag)
+			return exitBadArgs
+		}
+		cfg = &config.Config{}
+		if err := json.Unmarshal(overrides, cfg); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: profile %q: %v\n", *profileFlag, err)
+			return exitBadArgs
+		}
+	}
+
+	for _, s := range cfg.Settings() {
+		if s.Value != "" {
+			fmt.Printf("%s=%s\n", s.Key, s.Value)
+		}
+	}
+	return exitSuccess
+}
+
+// cmdConfigValidate checks the config file and the project's .afk.json
+func cmdConfigValidate(args []string) int {
+	fs := flag.NewFlagSet("config validate", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitSuccess
+		}
+		return exitBadArgs
+	}
+
+	channels := channelNames()
+	code := exitSuccess
+	report := func(path string, errs []error) {
+		if len(errs) == 0 {
+			fmt.Printf("%s ✓\n", path)
+			return
+		}
+		fmt.Printf("%s ✗\n", path)
+		for _, err := range errs {
+			fmt.Printf("  %v\n", err)
+		}
+		code = exitBadArgs
+	}
+
+	if !config.Exists() {
+		fmt.Printf("%s (not found)\n", config.Path())
+	} else if cfg, err := config.Read(); err != nil {
+		report(config.Path(), []error{err})
+	} else {
+		report(config.Path(), cfg.Validate(channels))
+	}
+
+	if project := config.FindProject(); project != "" {
+		report(project, config.ValidateProject(project, channels))
+	}
+	return code
+}
+
+// cmdConfigEdit opens the config file in $VISUAL or $EDITOR and saves it
+// only if it is still valid
+func cmdConfigEdit(args []string) int {
+	fs := flag.NewFlagSet("config edit", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitSuccess
+		}
+		return exitBadArgs
+	}
+
+	original, err := os.ReadFile(config.Path())
+	if err != nil && !os.IsNotExist(err) {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitBadArgs
+	}
+	if len(original) == 0 {
+		original = []byte("{\n}\n")
+	}
+
+	// Edit a private copy next to the config, as it may hold credentials
+	dir := filepath.Dir(config.Path())
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitBadArgs
+	}
+	tmp, err := os.CreateTemp(dir, ".edit-*.json")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitBadArgs
+	}
+	tmp.Write(original)
+	tmp.Close()
+
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+	parts := strings.Fields(editor)
+	cmd := exec.Command(parts[0], append(parts[1:], tmp.Name())...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		os.Remove(tmp.Name())
+		fmt.Fprintf(os.Stderr, "Error: %s: %v\n", editor, err)
+		return exitBadArgs
+	}
+
+	edited, err := os.ReadFile(tmp.Name())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitBadArgs
+	}
+	if bytes.Equal(edited, original) {
+		os.Remove(tmp.Name())
+		fmt.Println("No changes.")
+		return exitSuccess
+	}
+
+	// Keep the edits for another try when they don't validate
+	var cfg config.Config
+	errs := []error{json.Unmarshal(edited, &cfg)}
+	if errs[0] == nil {
+		errs = cfg.Validate(channelNames())
+	}
+	if len(errs) > 0 {
+		fmt.Fprintln(os.Stderr, "400 Bad Request: The config was not saved:")
+		for _, err := range errs {
+			fmt.Fprintf(os.Stderr, "  %v\n", err)
+		}
+		fmt.Fprintf(os.Stderr, "Your edits are in %s\n", tmp.Name())
+		return exitBadArgs
+	}
+
+	if err := config.SaveData(edited); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitBadArgs
+	}
+	os.Remove(tmp.Name())
+	fmt.Printf("Saved %s\n", config.Path())
+	return exitSuccess
+}
+
+// leadingArgs takes the arguments before the first flag, so flags can
+// follow them
+func leadingArgs(args []string) ([]string, []string) {
+	for i, arg := range args {
+		if strings.HasPrefix(arg, "-") {
+			return args[:i], args[i:]
+		}
+	}
+	return args, nil
+}
+
+func profileSuffix(profile string) string {
+	if profile == "" {
+		return " in " + config.Path()
+	}
+	return fmt.Sprintf(" in profile %q", profile)
+}
+
+// channelNames lists the registered channels
+func channelNames() []string {
+	var names []string
+	for _, r := range transport.Registrations() {
+		names = append(names, r.Name)
+	}
+	return names
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/davedotdev/afk/internal/config"
+	"github.com/davedotdev/afk/internal/output"
+)
+
+// cmdContacts manages the contact book used by --to
+func cmdContacts(args []string) int {
+	sub := "list"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		sub, args = args[0], args[1:]
+	}
+
+	switch sub {
+	case "list", "ls":
+		return cmdContactsList(args)
+	case "add":
+		return cmdContactsAdd(args)
+	case "remove", "rm":
+		return cmdContactsRemove(args)
+	}
+	fmt.Fprintf(os.Stderr, "400 Bad Request: Unknown contacts command %q (use add, list or remove)\n", sub)
+	return exitBadArgs
+}
+
+func cmdContactsList(args []string) int {
+	fs := flag.NewFlagSet("contacts list", flag.ContinueOnError)
+	formatFlag := fs.String("format", "", "Output format: llm, human, json")
+	quietFlag := fs.Bool("quiet", false, "Minimal output (just names)")
+
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitSuccess
+		}
+		return exitBadArgs
+	}
+
+	cfg, err := config.Read()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitBadArgs
+	}
+	shared := &config.ContactBook{}
+	if path := cfg.ContactsPath(); path != "" {
+		if shared, err = config.LoadContactBook(path); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return exitBadArgs
+		}
+	}
+
+	format := cfg.Format
+	if *formatFlag != "" {
+		format = config.OutputFormat(*formatFlag)
+	}
+
+	// The config's own entries shadow the shared file's
+	var entries []output.ContactEntry
+	add := func(book *config.ContactBook, source string, shadowed map[string]bool) {
+		for name, contact := range book.Contacts {
+			if shadowed[name] {
+				continue
+			}
+			entries = append(entries, output.ContactEntry{
+				Name:    name,
+				Contact: contact,
+				Groups:  groupsOf(name, cfg.Groups, shared.Groups),
+				Source:  source,
+			})
+		}
+	}
+	own := &config.ContactBook{Contacts: cfg.Contacts}
+	add(own, config.Path(), nil)
+	add(shared, cfg.ContactsPath(), nameSet(cfg.Contacts))
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name < entries[j].Name
+	})
+
+	output.New(format, *quietFlag).Contacts(entries)
+	return exitSuccess
+}
+
+func cmdContactsAdd(args []string) int {
+	name, args := contactName(args)
+
+	fs := flag.NewFlagSet("contacts add", flag.ContinueOnError)
+	descriptionFlag := fs.String("description", "", "Who they are, e.g. \"DBA on call\"")
+	phoneFlag := fs.String("phone", "", "Phone number (for reference)")
+	slackFlag := fs.String("slack", "", "Slack user ID (U...)")
+	emailFlag := fs.String("email", "", "Email address")
+	telegramFlag := fs.String("telegram", "", "Telegram chat ID")
+	discordFlag := fs.String("discord", "", "Discord user ID")
+	matrixFlag := fs.String("matrix", "", "Matrix room ID")
+	ntfyFlag := fs.String("ntfy", "", "ntfy topic")
+	var groupFlags stringList
+	fs.Var(&groupFlags, "group", "Add the contact to this group (repeatable)")
+	fileFlag := fs.String("file", "", "Write to this contacts file instead of the config")
+
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitSuccess
+		}
+		return exitBadArgs
+	}
+	if name == "" {
+		name = fs.Arg(0)
+	}
+	if name == "" {
+		fmt.Fprintln(os.Stderr, "400 Bad Request: Usage: afk contacts add <name> [--slack ID] [--email ADDR] ...")
+		return exitBadArgs
+	}
+
+	book, save, err := openContactBook(*fileFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitBadArgs
+	}
+
+	// Adding an existing contact updates the addresses given
+	contact := book.Contacts[name]
+	for _, field := range []struct {
+		dst   *string
+		value string
+	}{
+		{&contact.Description, *descriptionFlag},
+		{&contact.Phone, *phoneFlag},
+		{&contact.Slack, *slackFlag},
+		{&contact.Email, *emailFlag},
+		{&contact.Telegram, *telegramFlag},
+		{&contact.Discord, *discordFlag},
+		{&contact.Matrix, *matrixFlag},
+		{&contact.Ntfy, *ntfyFlag},
+	} {
+		if field.value != "" {
+			*field.dst = field.value
+		}
+	}
+	if contact == (config.Contact{}) {
+		fmt.Fprintln(os.Stderr, "400 Bad Request: Give at least one address (--slack, --email, --telegram, ...)")
+		return exitBadArgs
+	}
+
+	if book.Contacts == nil {
+		book.Contacts = make(map[string]config.Contact)
+	}
+	book.Contacts[name] = contact
+	for _, group := range groupFlags {
+		if !containsString(book.Groups[group], name) {
+			if book.Groups == nil {
+				book.Groups = make(map[string][]string)
+			}
+			book.Groups[group] = append(book.Groups[group], name)
+		}
+	}
+
+	if err := save(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitBadArgs
+	}
+	fmt.Printf("Saved contact %s\n", name)
+	return exitSuccess
+}
+
+func cmdContactsRemove(args []string) int {
+	name, args := contactName(args)
+
+	fs := flag.NewFlagSet("contacts remove", flag.ContinueOnError)
+	fileFlag := fs.String("file", "", "Remove from this contacts file instead of the config")
+
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitSuccess
+		}
+		return exitBadArgs
+	}
+	if name == "" {
+		name = fs.Arg(0)
+	}
+	if name == "" {
+		fmt.Fprintln(os.Stderr, "400 Bad Request: Usage: afk contacts remove <name>")
+		return exitBadArgs
+	}
+
+	book, save, err := openContactBook(*fileFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitBadArgs
+	}
+	if _, ok := book.Contacts[name]; !ok {
+		fmt.Fprintf(os.Stderr, "404 Not Found: No contact %q in %s\n", name, contactBookName(*fileFlag))
+		return exitBadArgs
+	}
+
+	delete(book.Contacts, name)
+	for group, members := range book.Groups {
+		var kept []string
+		for _, member := range members {
+			if member != name {
+				kept = append(kept, member)
+			}
+		}
+		if len(kept) == 0 {
+			delete(book.Groups, group)
+		} else {
+			book.Groups[group] = kept
+		}
+	}
+
+	if err := save(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitBadArgs
+	}
+	fmt.Printf("Removed contact %s\n", name)
+	return exitSuccess
+}
+
+// openContactBook returns the contacts in file, or in the config when file
+// is empty, with a function that saves changes back
+func openContactBook(file string) (*config.ContactBook, func() error, error) {
+	if file != "" {
+		book, err := config.LoadContactBook(file)
+		if err != nil {
+			return nil, nil, err
+		}
+		return book, func() error { return config.SaveContactBook(file, book) }, nil
+	}
+
+	cfg, err := config.Read()
+	if err != nil {
+		return nil, nil, err
+	}
+	book := &config.ContactBook{Contacts: cfg.Contacts, Groups: cfg.Groups}
+	return book, func() error {
+		cfg.Contacts, cfg.Groups = book.Contacts, book.Groups
+		return config.Save(cfg)
+	}, nil
+}
+
+func contactBookName(file string) string {
+	if file != "" {
+		return file
+	}
+	return config.Path()
+}
+
+// contactName takes a leading name argument, so flags can follow it
+func contactName(args []string) (string, []string) {
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		return args[0], args[1:]
+	}
+	return "", args
+}
+
+// groupsOf returns the names of the groups that list name
+func groupsOf(name string, books ...map[string][]string) []string {
+	var groups []string
+	for _, book := range books {
+		for group, members := range book {
+			if containsString(members, name) && !containsString(groups, group) {
+				groups = append(groups, group)
+			}
+		}
+	}
+	sort.Strings(groups)
+	return groups
+}
+
+func nameSet(contacts map[string]config.Contact) map[string]bool {
+	names := make(map[string]bool, len(contacts))
+	for name := range contacts {
+		names[name] = true
+	}
+	return names
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,505 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+	"unicode/utf8"
+
+	"github.com/davedotdev/afk/internal/approval"
+	"github.com/davedotdev/afk/internal/choice"
+	"github.com/davedotdev/afk/internal/config"
+	"github.com/davedotdev/afk/internal/history"
+	"github.com/davedotdev/afk/internal/output"
+	"github.com/davedotdev/afk/internal/transport"
+)
+
+// conversation sends messages on one transport and waits for the reply,
+// asking again when a multiple-choice answer matches no option. With an
+// escalation chain it also sends the message on later hops while no reply
+// arrives, and takes the first reply on any of them.
+type conversation struct {
+	cfg              *config.Config
+	out              *output.Formatter
+	transport        transport.Transport
+	channel          string // Registered channel name, e.g. "whatsapp"
+	timeout          time.Duration
+	reminderInterval time.Duration
+	options          []string // Multiple-choice options, if any
+	maxReprompts     int
+	approval         bool   // Read the reply as yes/no
+	escalation       []*hop // Later hops, sent in turn while no reply arrives
+	recipient        string // Name given to --to, if any
+
+	legs     []*leg // Messages being waited on, one per hop sent
+	next     int    // Index of the next escalation hop to send
+	question string // Message repeated on each escalation hop
+}
+
+// hop is a later step of an escalation chain
+type hop struct {
+	channel   string
+	transport transport.Transport
+	after     time.Duration // Since the first message was sent
+}
+
+// leg is a sent message whose reply is awaited
+type leg struct {
+	hop       int // 1-based position in the escalation chain, 0 when not escalating
+	channel   string
+	transport transport.Transport
+	entry     *history.Entry
+}
+
+// newConversation creates a conversation on the named channel
+func newConversation(cfg *config.Config, out *output.Formatter, channel string) (*conversation, error) {
+	t, err := transport.New(channel, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &conversation{
+		cfg:       cfg,
+		out:       out,
+		transport: t,
+		channel:   channel,
+	}, nil
+}
+
+// send delivers message on the conversation's channel and records it in history
+func (c *conversation) send(message string, waiting bool) (*history.Entry, int) {
+	hop := 0
+	if len(c.escalation) > 0 {
+		hop = 1
+	}
+	return c.sendOn(c.transport, c.channel, hop, message, waiting)
+}
+
+// sendOn delivers message on t and records it in history
+func (c *conversation) sendOn(t transport.Transport, channel string, hop int, message string, waiting bool) (*history.Entry, int) {
+	receipt, err := t.Send(context.Background(), transport.Message{
+		Text:     message,
+		SysName:  c.cfg.SysName,
+		Options:  c.options,
+		Approval: c.approval,
+	})
+	if err != nil {
+		c.out.Error(500, err.Error(), "")
+		return nil, exitSendFailed
+	}
+
+	c.out.MessageSent(t.Name(), receipt.SessionID, receipt.MessageID, utf8.RuneCountInString(message), c.timeout, waiting)
+
+	entry := &history.Entry{
+		SessionID: receipt.SessionID,
+		MessageID: receipt.MessageID,
+		Transport: channel,
+		Channel:   t.Name(),
+		Message:   message,
+		Options:   c.options,
+		Approval:  c.approval,
+		Hop:       hop,
+		Recipient: c.recipient,
+		SentAt:    time.Now().UTC(),
+		Outcome:   history.OutcomePending,
+	}
+	if !waiting {
+		entry.Outcome = history.OutcomeSent
+	}
+	recordHistory(entry)
+
+	return entry, exitSuccess
+}
+
+// await waits for the reply to entry, prints it and records the outcome,
+// returning the exit code. Re-prompts and escalation hops share the
+// original timeout.
+func (c *conversation) await(entry *history.Entry) int {
+	ctx, stop := interruptible(c.out)
+	defer stop()
+
+	// Track start time for wait duration
+	startTime := time.Now()
+	deadline := startTime.Add(c.timeout)
+
+	c.legs = []*leg{{hop: entry.Hop, channel: c.channel, transport: c.transport, entry: entry}}
+	c.question = entry.Message
+
+	for reprompts := 0; ; reprompts++ {
+		answered, reply, err := c.listenAll(ctx, startTime, deadline)
+		if err != nil {
+			code := c.listenFailed(answered.entry.SessionID, err)
+			for _, l := range c.legs {
+				finishHistory(l.entry, nil, code)
+			}
+			return code
+		}
+
+		entry := answered.entry
+		waitTime := time.Since(startTime)
+
+		if c.approval {
+			decision := approval.Parse(reply.Content)
+			entry.Decision = string(decision)
+			code := approvalExitCode(decision)
+			c.out.ApprovalResponse(entry.SessionID, reply.From, reply.Channel, reply.Content, waitTime, answered.hop, string(decision))
+			c.finish(answered, reply, code)
+			return code
+		}
+
+		if len(c.options) == 0 {
+			c.out.Response(entry.SessionID, reply.From, reply.Channel, reply.Content, waitTime, answered.hop)
+			c.finish(answered, reply, exitSuccess)
+			return exitSuccess
+		}
+
+		if n := choice.Match(reply.Content, c.options); n > 0 {
+			entry.Choice = c.options[n-1]
+			entry.ChoiceIdx = n
+			c.out.ChoiceResponse(entry.SessionID, reply.From, reply.Channel, reply.Content, waitTime, answered.hop, n, entry.Choice)
+			c.finish(answered, reply, exitSuccess)
+			return exitSuccess
+		}
+
+		finishHistory(entry, reply, exitSuccess)
+
+		// Out of re-prompts: hand the raw reply back to the agent
+		if reprompts >= c.maxReprompts {
+			c.out.Response(entry.SessionID, reply.From, reply.Channel, reply.Content, waitTime, answered.hop)
+			c.finish(answered, nil, exitSuccess)
+			return exitSuccess
+		}
+
+		c.out.Unmatched(entry.SessionID, reply.Content, reprompts+1, c.maxReprompts)
+
+		// Ask again on the hop that answered
+		next, code := c.sendOn(answered.transport, answered.channel, answered.hop, choice.Reprompt(reply.Content, c.options), true)
+		if code != exitSuccess {
+			c.finish(answered, nil, code)
+			return code
+		}
+		answered.entry = next
+	}
+}
+
+// notifyInterrupt relays Ctrl+C (SIGINT) and SIGTERM to c. Tests replace
+// it to cancel a wait without signalling the process.
+var notifyInterrupt = func(c chan<- os.Signal) {
+	signal.Notify(c, syscall.SIGINT, syscall.SIGTERM)
+}
+
+// interruptible returns a context that Ctrl+C (SIGINT) or SIGTERM cancels,
+// reporting the cancellation on out. Call stop when done waiting.
+func interruptible(out *output.Formatter) (ctx context.Context, stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	sigChan := make(chan os.Signal, 1)
+	notifyInterrupt(sigChan)
+
+	go func() {
+		if _, ok := <-sigChan; ok {
+			out.Cancelled()
+			cancel()
+		}
+	}()
+
+	return ctx, func() {
+		signal.Stop(sigChan)
+		cancel()
+	}
+}
+
+// finish records the reply on the answered leg, and marks the other legs
+// of an escalation as answered elsewhere. A nil reply means the answered
+// leg was already recorded.
+func (c *conversation) finish(answered *leg, reply *transport.Reply, code int) {
+	if reply != nil {
+		finishHistory(answered.entry, reply, code)
+	}
+	for _, l := range c.legs {
+		if l != answered {
+			l.entry.Outcome = history.OutcomeEscalated
+			l.entry.ExitCode = code
+			recordHistory(l.entry)
+		}
+	}
+}
+
+// listenAll waits until deadline for a reply on any leg, sending escalation
+// hops as they fall due or when a leg fails. It returns the leg the reply
+// or error came from.
+func (c *conversation) listenAll(ctx context.Context, startTime, deadline time.Time) (*leg, *transport.Reply, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		leg   *leg
+		reply *transport.Reply
+		err   error
+	}
+	results := make(chan result, len(c.legs)+len(c.escalation))
+	listening := 0
+	start := func(l *leg) {
+		listening++
+		go func() {
+			reply, err := c.listen(ctx, l, time.Until(deadline))
+			results <- result{l, reply, err}
+		}()
+	}
+	for _, l := range c.legs {
+		start(l)
+	}
+
+	var timer *time.Timer
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+	}()
+
+	for {
+		var due <-chan time.Time
+		if c.next < len(c.escalation) {
+			if timer != nil {
+				timer.Stop()
+			}
+			timer = time.NewTimer(time.Until(startTime.Add(c.escalation[c.next].after)))
+			due = timer.C
+		}
+
+		select {
+		case res := <-results:
+			listening--
+			if res.err == nil {
+				return res.leg, res.reply, nil
+			}
+			if errors.Is(res.err, transport.ErrTimeout) || errors.Is(res.err, transport.ErrCancelled) {
+				return res.leg, nil, res.err
+			}
+			// This hop can't be answered, so don't wait for the next to fall due
+			cause := fmt.Errorf("%s: %w", res.leg.transport.Name(), res.err)
+			if l := c.escalate(time.Since(startTime), cause); l != nil {
+				start(l)
+			} else if listening == 0 {
+				return res.leg, nil, res.err
+			}
+
+		case <-due:
+			if l := c.escalate(c.escalation[c.next].after, nil); l != nil {
+				start(l)
+			}
+		}
+	}
+}
+
+// escalate sends the question on the next hop that accepts it and returns
+// its leg, or nil when the chain is exhausted
+func (c *conversation) escalate(elapsed time.Duration, cause error) *leg {
+	for c.next < len(c.escalation) {
+		h := c.escalation[c.next]
+		c.next++
+		n := c.next + 1 // The first message is hop 1
+
+		c.out.Escalated(n, h.transport.Name(), elapsed, cause)
+		entry, code := c.sendOn(h.transport, h.channel, n, c.question, true)
+		if code == exitSuccess {
+			l := &leg{hop: n, channel: h.channel, transport: h.transport, entry: entry}
+			c.legs = append(c.legs, l)
+			return l
+		}
+		cause = fmt.Errorf("%s send failed", h.transport.Name())
+	}
+	return nil
+}
+
+// listen waits up to timeout for one reply on l. Only the first leg prints
+// reminders, so escalating doesn't repeat them.
+func (c *conversation) listen(ctx context.Context, l *leg, timeout time.Duration) (*transport.Reply, error) {
+	sessionID := l.entry.SessionID
+	opts := transport.ListenOptions{
+		Timeout: timeout,
+		OnReconnect: func(attempt int, delay time.Duration, err error) {
+			c.out.Reconnecting(sessionID, attempt, delay, err)
+		},
+	}
+	if l == c.legs[0] {
+		opts.ReminderInterval = c.reminderInterval
+		opts.OnReminder = func(elapsed, remaining time.Duration) {
+			c.out.Waiting(sessionID, elapsed, remaining)
+		}
+	}
+	return l.transport.Listen(ctx, sessionID, opts)
+}
+
+// escalationHops builds the hops of the configured escalation chain. Every
+// hop must fall due before timeout.
+func escalationHops(cfg *config.Config, timeout time.Duration) ([]*hop, error) {
+	if len(cfg.Escalation) == 0 {
+		return nil, fmt.Errorf("no escalation chain configured: add \"escalation\" to %s", config.Path())
+	}
+
+	var hops []*hop
+	for i, step := range cfg.Escalation {
+		r, ok := transport.Lookup(step.Channel)
+		if !ok {
+			return nil, fmt.Errorf("escalation step %d: unknown channel %q", i+1, step.Channel)
+		}
+		after, err := time.ParseDuration(step.After)
+		if err != nil || after <= 0 {
+			return nil, fmt.Errorf("escalation step %d: invalid after %q (use a duration like 10m)", i+1, step.After)
+		}
+		if len(hops) > 0 && after < hops[len(hops)-1].after {
+			return nil, fmt.Errorf("escalation step %d: after %s is earlier than the step before", i+1, step.After)
+		}
+		if after >= timeout {
+			return nil, fmt.Errorf("escalation step %d: after %s is not within the %s timeout", i+1, step.After, timeout)
+		}
+
+		hopCfg, err := cfg.Override(step.Config)
+		if err != nil {
+			return nil, fmt.Errorf("escalation step %d: %w", i+1, err)
+		}
+		if step.Arg != "" && r.SetArg != nil {
+			r.SetArg(hopCfg, step.Arg)
+		}
+		t, err := r.New(hopCfg)
+		if err != nil {
+			return nil, fmt.Errorf("escalation step %d: %w", i+1, err)
+		}
+		hops = append(hops, &hop{channel: step.Channel, transport: t, after: after})
+	}
+	return hops, nil
+}
+
+// listenFailed reports a failed wait and returns its exit code
+func (c *conversation) listenFailed(sessionID string, err error) int {
+	if errors.Is(err, transport.ErrTimeout) {
+		c.out.Timeout(sessionID, c.timeout)
+		return exitTimeout
+	}
+	if errors.Is(err, transport.ErrCancelled) {
+		return cancelledExitCode(c.approval)
+	}
+	c.out.Error(503, err.Error(), sessionID)
+	return exitAPIError
+}
+
+// cancelledExitCode is the exit code when the wait is interrupted. An
+// approval must not exit 0, which would read as approved.
+func cancelledExitCode(approval bool) int {
+	if approval {
+		return exitCancelled
+	}
+	return exitSuccess
+}
+
+// approvalExitCode maps an approval decision to its exit code
+func approvalExitCode(d approval.Decision) int {
+	switch d {
+	case approval.Approved:
+		return exitApproved
+	case approval.Denied:
+		return exitDenied
+	default:
+		return exitUnclear
+	}
+}
+
+// channelFlag is a channel selection flag. Most are on/off; channels with
+// an Arg take a value (e.g. --webhook <url>).
+type channelFlag struct {
+	set   bool
+	value string
+	arg   bool
+}
+
+func (f *channelFlag) String() string {
+	return f.value
+}
+
+func (f *channelFlag) Set(value string) error {
+	if f.arg {
+		// "--webhook --msg x" must not take "--msg" as the URL
+		if value == "" || strings.HasPrefix(value, "-") {
+			return errors.New("value required")
+		}
+		f.set, f.value = true, value
+		return nil
+	}
+	set, err := strconv.ParseBool(value)
+	if err != nil {
+		return err
+	}
+	f.set = set
+	return nil
+}
+
+// IsBoolFlag lets on/off channel flags be given without a value
+func (f *channelFlag) IsBoolFlag() bool {
+	return !f.arg
+}
+
+// channelFlags registers a flag for every transport
+func channelFlags(fs *flag.FlagSet) map[string]*channelFlag {
+	flags := make(map[string]*channelFlag)
+	for _, r := range transport.Registrations() {
+		f := &channelFlag{arg: r.Arg != ""}
+		fs.Var(f, r.Name, r.Usage)
+		flags[r.Name] = f
+	}
+	return flags
+}
+
+// selectedChannels returns the names of the channel flags that were set
+func selectedChannels(flags map[string]*channelFlag) []string {
+	var names []string
+	for _, r := range transport.Registrations() {
+		if flags[r.Name].set {
+			names = append(names, r.Name)
+		}
+	}
+	return names
+}
+
+// applyChannelArgs stores the values of selected channel flags in the config
+func applyChannelArgs(cfg *config.Config, flags map[string]*channelFlag) {
+	for _, r := range transport.Registrations() {
+		if f := flags[r.Name]; f.set && f.arg && r.SetArg != nil {
+			r.SetArg(cfg, f.value)
+		}
+	}
+}
+
+// channelList formats the channel flags for error messages ("--sms or --whatsapp")
+func channelList() string {
+	var names []string
+	for _, r := range transport.Registrations() {
+		name := "--" + r.Name
+		if r.Arg != "" {
+			name += " <" + r.Arg + ">"
+		}
+		names = append(names, name)
+	}
+	if len(names) <= 1 {
+		return strings.Join(names, "")
+	}
+	return strings.Join(names[:len(names)-1], ", ") + " or " + names[len(names)-1]
+}
+
+// stringList is a flag.Value collecting repeated flags
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
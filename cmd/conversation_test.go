@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/davedotdev/afk/internal/config"
+	"github.com/davedotdev/afk/internal/history"
+	"github.com/davedotdev/afk/internal/output"
+	"github.com/davedotdev/afk/internal/transport"
+)
+
+// blockingTransport waits for a reply that never comes, reporting on
+// listening once each Listen has started
+type blockingTransport struct {
+	listening chan struct{}
+}
+
+func (b *blockingTransport) Name() string { return "Test" }
+
+func (b *blockingTransport) Send(ctx context.Context, msg transport.Message) (*transport.Receipt, error) {
+	return &transport.Receipt{SessionID: "afk-test"}, nil
+}
+
+func (b *blockingTransport) Listen(ctx context.Context, sessionID string, opts transport.ListenOptions) (*transport.Reply, error) {
+	b.listening <- struct{}{}
+	<-ctx.Done()
+	return nil, transport.ErrCancelled
+}
+
+func (b *blockingTransport) Health(ctx context.Context) error   { return nil }
+func (b *blockingTransport) Validate(ctx context.Context) error { return nil }
+
+// interruptAfter delivers an interrupt to the next wait once n listens
+// have started, as Ctrl+C would
+func interruptAfter(t *testing.T, listening <-chan struct{}, n int) {
+	t.Helper()
+	notify := notifyInterrupt
+	t.Cleanup(func() { notifyInterrupt = notify })
+	notifyInterrupt = func(c chan<- os.Signal) {
+		go func() {
+			for i := 0; i < n; i++ {
+				<-listening
+			}
+			c <- os.Interrupt
+		}()
+	}
+}
+
+func testConversation(t *testing.T, approval bool) (*conversation, *blockingTransport) {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir()) // History is written under the home directory
+
+	bt := &blockingTransport{listening: make(chan struct{}, 4)}
+	return &conversation{
+		cfg:       &config.Config{},
+		out:       output.New(config.FormatJSON, true),
+		transport: bt,
+		channel:   "test",
+		timeout:   time.Minute,
+		approval:  approval,
+	}, bt
+}
+
+func TestCancelledExitCode(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		approval bool
+		want     int
+	}{
+		{"message", false, exitSuccess},
+		{"approval", true, exitCancelled},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			c, bt := testConversation(t, tt.approval)
+			entry, code := c.send("Deploy?", true)
+			if code != exitSuccess {
+				t.Fatalf("send = %d", code)
+			}
+
+			interruptAfter(t, bt.listening, 1)
+			if got := c.await(entry); got != tt.want {
+				t.Errorf("await = %d, want %d", got, tt.want)
+			}
+			if entry.Outcome != history.OutcomeCancelled {
+				t.Errorf("Outcome = %q, want %q", entry.Outcome, history.OutcomeCancelled)
+			}
+		})
+	}
+}
+
+func TestBroadcastCancelledApproval(t *testing.T) {
+	template, bt := testConversation(t, true)
+	b := &broadcast{out: template.out, require: "all", needed: 2, timeout: template.timeout, approval: true}
+	for _, name := range []string{"alice", "bob"} {
+		conv := *template
+		conv.recipient = name
+		b.members = append(b.members, &member{name: name, conv: &conv})
+	}
+	if code := b.send("Deploy?", true); code != exitSuccess {
+		t.Fatalf("send = %d", code)
+	}
+
+	interruptAfter(t, bt.listening, len(b.members))
+	if got := b.await(); got != exitCancelled {
+		t.Errorf("await = %d, want %d", got, exitCancelled)
+	}
+	for _, m := range b.members {
+		if m.leg.entry.Outcome != history.OutcomeCancelled {
+			t.Errorf("%s: Outcome = %q, want %q", m.name, m.leg.entry.Outcome, history.OutcomeCancelled)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/davedotdev/afk/internal/config"
+	"github.com/davedotdev/afk/internal/history"
+	"github.com/davedotdev/afk/internal/output"
+	"github.com/davedotdev/afk/internal/transport"
+)
+
+// recordHistory saves entry to the local history. History is an audit aid,
+// so failures are reported but never fail the command.
+func recordHistory(entry *history.Entry) {
+	store, err := history.Open()
+	if err == nil {
+		err = store.Record(entry)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to record history: %v\n", err)
+	}
+}
+
+// lookupHistory returns the stored entry for a session, or a fresh one if
+// the message was not sent from this machine
+func lookupHistory(sessionID string) *history.Entry {
+	if store, err := history.Open(); err == nil {
+		if entry, err := store.Get(sessionID); err == nil && entry != nil {
+			return entry
+		}
+	}
+	return &history.Entry{
+		SessionID: sessionID,
+		SentAt:    time.Now().UTC(),
+		Outcome:   history.OutcomePending,
+	}
+}
+
+// finishHistory records the outcome of waiting on entry
+func finishHistory(entry *history.Entry, reply *transport.Reply, code int) {
+	entry.ExitCode = code
+
+	switch {
+	case reply != nil:
+		now := time.Now().UTC()
+		entry.Outcome = history.OutcomeReplied
+		entry.Reply = reply.Content
+		entry.ReplyFrom = reply.From
+		entry.RepliedAt = &now
+	case code == exitTimeout:
+		entry.Outcome = history.OutcomeTimeout
+	case code == exitSuccess || code == exitCancelled:
+		entry.Outcome = history.OutcomeCancelled
+	default:
+		entry.Outcome = history.OutcomeError
+	}
+
+	recordHistory(entry)
+}
+
+func cmdHistory(args []string) int {
+	fs := flag.NewFlagSet("history", flag.ContinueOnError)
+	sinceFlag := fs.String("since", "", "Only entries sent after this (e.g., 24h, 2006-01-02, RFC 3339)")
+	untilFlag := fs.String("until", "", "Only entries sent before this (e.g., 1h, 2006-01-02, RFC 3339)")
+	channelFlag := fs.String("channel", "", "Only entries sent on this channel (e.g., SMS, WhatsApp)")
+	outcomeFlag := fs.String("outcome", "", "Only entries with this outcome")
+	searchFlag := fs.String("search", "", "Case-insensitive text to find in the message or reply")
+	limitFlag := fs.Int("limit", 20, "Show the most recent N entries (0 for all)")
+	formatFlag := fs.String("format", "", "Output format: llm, human, json")
+	quietFlag := fs.Bool("quiet", false, "Minimal output (just session IDs)")
+
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitSuccess
+		}
+		return exitBadArgs
+	}
+
+	now := time.Now()
+	filter := history.Filter{
+		Channel: *channelFlag,
+		Search:  *searchFlag,
+		Limit:   *limitFlag,
+	}
+
+	var err error
+	if *sinceFlag != "" {
+		if filter.Since, err = history.ParseTime(*sinceFlag, now); err != nil {
+			fmt.Fprintf(os.Stderr, "400 Bad Request: --since: %v\n", err)
+			return exitBadArgs
+		}
+	}
+	if *untilFlag != "" {
+		if filter.Until, err = history.ParseTime(*untilFlag, now); err != nil {
+			fmt.Fprintf(os.Stderr, "400 Bad Request: --until: %v\n", err)
+			return exitBadArgs
+		}
+	}
+	if *outcomeFlag != "" {
+		if filter.Outcome, err = history.ParseOutcome(*outcomeFlag); err != nil {
+			fmt.Fprintf(os.Stderr, "400 Bad Request: --outcome: %v\n", err)
+			return exitBadArgs
+		}
+	}
+
+	// History does not need credentials, so none are fetched; use the
+	// configured format if any
+	format := config.FormatLLM
+	if cfg, err := loadSettings(""); err == nil {
+		format = cfg.Format
+	}
+	if *formatFlag != "" {
+		format = config.OutputFormat(*formatFlag)
+	}
+
+	store, err := history.Open()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitBadArgs
+	}
+
+	entries, err := store.List(filter)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitBadArgs
+	}
+
+	output.New(format, *quietFlag).History(entries)
+	return exitSuccess
+}
@@ -3,18 +3,20 @@ package main
 import (
 	"bufio"
 	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"os"
-	"os/signal"
 	"strings"
-	"syscall"
 	"time"
 
 	"github.com/davedotdev/afk/internal/api"
+	"github.com/davedotdev/afk/internal/approval"
+	"github.com/davedotdev/afk/internal/choice"
 	"github.com/davedotdev/afk/internal/config"
 	"github.com/davedotdev/afk/internal/output"
-	"github.com/davedotdev/afk/internal/sse"
+	"github.com/davedotdev/afk/internal/transport"
 )
 
 // Version information
@@ -27,6 +29,11 @@ const (
 	exitAPIError   = 2
 	exitTimeout    = 3
 	exitSendFailed = 4
+	exitDenied     = 5 // Approval request denied
+	exitUnclear    = 6 // Approval reply was neither yes nor no
+	exitCancelled  = 7 // Approval wait cancelled (Ctrl+C or SIGTERM)
+
+	exitApproved = exitSuccess // Approval granted, so `afk approve ... && deploy` works
 )
 
 func main() {
@@ -43,11 +50,21 @@ func run() int {
 	// Check for subcommands first
 	switch os.Args[1] {
 	case "login":
-		return cmdLogin()
+		return cmdLogin(os.Args[2:])
 	case "logout":
-		return cmdLogout()
+		return cmdLogout(os.Args[2:])
 	case "status":
 		return cmdStatus()
+	case "wait":
+		return cmdWait(os.Args[2:])
+	case "history":
+		return cmdHistory(os.Args[2:])
+	case "contacts":
+		return cmdContacts(os.Args[2:])
+	case "config":
+		return cmdConfig(os.Args[2:])
+	case "approve":
+		return cmdSend(os.Args[2:], true)
 	case "-h", "--help", "help":
 		printHelp()
 		return exitSuccess
@@ -56,21 +73,41 @@ func run() int {
 		return exitSuccess
 	}
 
+	return cmdSend(os.Args[1:], false)
+}
+
+// cmdSend sends a message and waits for the reply. With approve set the
+// reply is read as yes/no and mapped to the approval exit codes.
+func cmdSend(args []string, approve bool) int {
 	// Parse flags for message sending
-	smsFlag := flag.Bool("sms", false, "Send message via SMS")
-	whatsappFlag := flag.Bool("whatsapp", false, "Send message via WhatsApp")
-	msgFlag := flag.String("msg", "", "Message content")
-	sessionFlag := flag.String("session", "", "Session ID (auto-generated if not set)")
-	noWaitFlag := flag.Bool("no-wait", false, "Send message and exit without waiting")
-	noHintFlag := flag.Bool("no-hint", false, "Don't append '[No reply expected]' hint (use with --no-wait)")
-	timeoutFlag := flag.Duration("timeout", time.Hour, "Timeout for waiting (default: 1h)")
-	reminderFlag := flag.String("reminder", "", "Reminder interval (e.g., 15m, 0 to disable)")
-	formatFlag := flag.String("format", "", "Output format: llm, human, json")
-	quietFlag := flag.Bool("quiet", false, "Minimal output (just response content)")
-	helpFlag := flag.Bool("h", false, "Show help")
-	versionFlag := flag.Bool("v", false, "Show version")
-
-	flag.Parse()
+	fs := flag.NewFlagSet("afk", flag.ContinueOnError)
+	channelFlagSet := channelFlags(fs)
+	msgFlag := fs.String("msg", "", "Message content ('-' to read from stdin)")
+	msgFileFlag := fs.String("msg-file", "", "Read message content from a file ('-' for stdin)")
+	sessionFlag := fs.String("session", "", "Session ID (auto-generated if not set)")
+	noWaitFlag := fs.Bool("no-wait", false, "Send message and exit without waiting")
+	noHintFlag := fs.Bool("no-hint", false, "Don't append '[No reply expected]' hint (use with --no-wait)")
+	settings := settingFlags(fs)
+	quietFlag := fs.Bool("quiet", false, "Minimal output (just response content)")
+	var optionFlags stringList
+	fs.Var(&optionFlags, "option", "Multiple-choice option (repeat for each option)")
+	choicesFlag := fs.String("choices", "", "Comma-separated multiple-choice options")
+	maxRepromptsFlag := fs.Int("max-reprompts", 2, "Times to ask again when a reply matches no option")
+	approvalFlag := fs.Bool("approval", false, "Ask a yes/no question and exit with the approval exit codes")
+	escalateFlag := fs.Bool("escalate", false, "Send on the configured escalation chain while no reply arrives")
+	toFlag := fs.String("to", "", "Comma-separated recipients or groups from the config")
+	profileFlag := fs.String("profile", "", "Config profile to use (default: $AFK_PROFILE)")
+	requireFlag := fs.String("require", "any", "Replies needed from --to recipients: any, all or a number")
+	helpFlag := fs.Bool("h", false, "Show help")
+	versionFlag := fs.Bool("v", false, "Show version")
+
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitSuccess
+		}
+		return exitBadArgs
+	}
+	approve = approve || *approvalFlag
 
 	if *helpFlag {
 		printHelp()
@@ -83,47 +120,99 @@ func run() int {
 	}
 
 	// Validate flags
-	if !*smsFlag && !*whatsappFlag {
-		fmt.Fprintln(os.Stderr, "400 Bad Request: Must specify --sms or --whatsapp")
-		fmt.Fprintln(os.Stderr, "Run 'afk -h' for usage")
+	channels := selectedChannels(channelFlagSet)
+	if len(channels) > 1 {
+		fmt.Fprintf(os.Stderr, "400 Bad Request: Cannot use both --%s and --%s\n", channels[0], channels[1])
 		return exitBadArgs
 	}
 
-	if *smsFlag && *whatsappFlag {
-		fmt.Fprintln(os.Stderr, "400 Bad Request: Cannot use both --sms and --whatsapp")
+	if *msgFlag == "" && *msgFileFlag == "" {
+		fmt.Fprintln(os.Stderr, "400 Bad Request: --msg or --msg-file is required")
 		return exitBadArgs
 	}
 
-	if *msgFlag == "" {
-		fmt.Fprintln(os.Stderr, "400 Bad Request: --msg is required")
+	// Prepare message
+	message, err := readMessage(*msgFlag, *msgFileFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "400 Bad Request: %v\n", err)
 		return exitBadArgs
 	}
 
-	// Load config
-	cfg, err := config.Load()
+	options := parseOptions(optionFlags, *choicesFlag)
+	if len(options) == 1 {
+		fmt.Fprintln(os.Stderr, "400 Bad Request: Multiple choice needs at least 2 options")
+		return exitBadArgs
+	}
+
+	if approve && len(options) > 0 {
+		fmt.Fprintln(os.Stderr, "400 Bad Request: Cannot combine approval with --option or --choices")
+		return exitBadArgs
+	}
+
+	if approve && *noWaitFlag {
+		fmt.Fprintln(os.Stderr, "400 Bad Request: Approval requires waiting for a reply (remove --no-wait)")
+		return exitBadArgs
+	}
+
+	if *escalateFlag && *noWaitFlag {
+		fmt.Fprintln(os.Stderr, "400 Bad Request: Escalation requires waiting for a reply (remove --no-wait)")
+		return exitBadArgs
+	}
+
+	// Load config. Asking locally needs no login, unless escalation
+	// continues on another channel.
+	load := loadConfig
+	if len(channels) == 1 && channels[0] == "local" && !*escalateFlag {
+		load = loadSettings
+	}
+	cfg, err := load(*profileFlag)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "401 Unauthorized: %v\n", err)
 		return exitBadArgs
 	}
 
 	// Override config with flags if provided
-	if *formatFlag != "" {
-		cfg.Format = config.OutputFormat(*formatFlag)
+	applyChannelArgs(cfg, channelFlagSet)
+	if err := applySettingFlags(cfg, settings); err != nil {
+		fmt.Fprintf(os.Stderr, "400 Bad Request: %v\n", err)
+		return exitBadArgs
 	}
-	if *reminderFlag != "" {
-		cfg.ReminderInterval = *reminderFlag
+
+	timeout, err := time.ParseDuration(cfg.Timeout)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "400 Bad Request: Invalid timeout: %v\n", err)
+		return exitBadArgs
 	}
 
-	// Parse reminder interval
-	var reminderInterval time.Duration
-	if cfg.ReminderInterval != "0" && cfg.ReminderInterval != "" {
-		reminderInterval, err = time.ParseDuration(cfg.ReminderInterval)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "400 Bad Request: Invalid reminder interval: %v\n", err)
+	// Without a channel flag, use the configured default channel
+	if len(channels) == 0 && cfg.Channel != "" {
+		if err := checkDefaultChannel(cfg.Channel); err != nil {
+			fmt.Fprintf(os.Stderr, "400 Bad Request: %v\n", err)
+			return exitBadArgs
+		}
+		channels = []string{cfg.Channel}
+	}
+	if len(channels) == 0 {
+		fmt.Fprintf(os.Stderr, "400 Bad Request: Must specify %s\n", channelList())
+		fmt.Fprintln(os.Stderr, "Run 'afk -h' for usage")
+		return exitBadArgs
+	}
+
+	// A later 'afk wait' must be able to receive the reply
+	if *noWaitFlag && channels[0] == "webhook" {
+		if err := transport.WebhookResumable(cfg); err != nil {
+			fmt.Fprintf(os.Stderr, "400 Bad Request: Cannot use --no-wait with --webhook: %v\n", err)
 			return exitBadArgs
 		}
 	}
 
+	// Parse reminder interval
+	reminderInterval, err := parseReminder(cfg.ReminderInterval)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "400 Bad Request: Invalid reminder interval: %v\n", err)
+		return exitBadArgs
+	}
+
 	// Create output formatter
 	out := output.New(cfg.Format, *quietFlag)
 
@@ -132,58 +221,91 @@ func run() int {
 	// but we keep the flag for backwards compatibility (it has no effect)
 	_ = *sessionFlag // Explicitly ignore - server generates session IDs
 
-	// Create API client
-	client := api.NewClient(cfg.APIURL, cfg.APIKey)
-
-	// Prepare message
-	message := *msgFlag
+	// Resolve --to before creating any transport
+	var recipients []string
+	needed := 0
+	if *toFlag != "" {
+		if recipients, err = resolveRecipients(cfg, *toFlag); err == nil {
+			needed, err = parseRequire(*requireFlag, len(recipients))
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "400 Bad Request: %v\n", err)
+			return exitBadArgs
+		}
+	}
 
-	// Unescape common shell escapes that Claude Code may add in double-quoted strings
-	// These appear when shell history expansion or other escaping is applied
-	message = strings.ReplaceAll(message, "\\!", "!")  // History expansion
-	message = strings.ReplaceAll(message, "\\?", "?")  // Glob pattern
-	message = strings.ReplaceAll(message, "\\*", "*")  // Glob pattern
-	message = strings.ReplaceAll(message, "\\[", "[")  // Glob pattern
-	message = strings.ReplaceAll(message, "\\]", "]")  // Glob pattern
+	if *escalateFlag && len(recipients) > 1 {
+		fmt.Fprintln(os.Stderr, "400 Bad Request: Cannot combine --escalate with more than one --to recipient")
+		return exitBadArgs
+	}
 
-	// Append no-reply notice if --no-wait is set (unless --no-hint)
-	if *noWaitFlag && !*noHintFlag {
-		message = message + "\n\n[No reply expected]"
+	// A single recipient is an ordinary conversation addressed to them
+	recipient := ""
+	if len(recipients) == 1 {
+		recipient, recipients = recipients[0], nil
+		if cfg, err = recipientConfig(cfg, channels[0], recipient); err != nil {
+			fmt.Fprintf(os.Stderr, "400 Bad Request: %v\n", err)
+			return exitBadArgs
+		}
 	}
 
-	// Send message
-	var msgType string
-	var sendErr error
-	var resp *api.SendMessageResponse
+	// With --to, conv is the template for each recipient's conversation
+	conv := &conversation{cfg: cfg, out: out, channel: channels[0]}
+	if recipients == nil {
+		if conv, err = newConversation(cfg, out, channels[0]); err != nil {
+			fmt.Fprintf(os.Stderr, "401 Unauthorized: %v\n", err)
+			return exitBadArgs
+		}
+	}
+	conv.recipient = recipient
+	conv.timeout = timeout
+	conv.reminderInterval = reminderInterval
+	conv.options = options
+	conv.maxReprompts = *maxRepromptsFlag
+	conv.approval = approve
+
+	if *escalateFlag {
+		if conv.escalation, err = escalationHops(cfg, timeout); err != nil {
+			fmt.Fprintf(os.Stderr, "400 Bad Request: %v\n", err)
+			return exitBadArgs
+		}
+	}
 
-	if *smsFlag {
-		msgType = "SMS"
-		resp, sendErr = client.SendSMS(message, "")
-	} else {
-		msgType = "WhatsApp"
-		resp, sendErr = client.SendWhatsApp(message, "", cfg.SysName)
+	if cfg.MessagePrefix != "" {
+		message = cfg.MessagePrefix + " " + message
 	}
 
-	if sendErr != nil {
-		out.Error(500, sendErr.Error(), "")
-		return exitSendFailed
+	// Number the options into the message so the reply can be matched
+	if len(options) > 0 {
+		message = choice.Render(message, options)
+	}
+	if approve {
+		message = approval.Prompt(message)
 	}
 
-	// Get message ID and session ID from server response
-	// Server always generates and returns the session ID for security
-	messageID := ""
-	sessionID := ""
-	if resp != nil {
-		messageID = resp.MessageID
-		sessionID = resp.SessionID
+	// Append no-reply notice if --no-wait is set (unless --no-hint)
+	if *noWaitFlag && !*noHintFlag {
+		message = message + "\n\n[No reply expected]"
 	}
 
-	if sessionID == "" {
-		out.Error(500, "Server did not return session ID", "")
-		return exitSendFailed
+	if recipients != nil {
+		group, err := newBroadcast(conv, recipients, *requireFlag, needed)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "401 Unauthorized: %v\n", err)
+			return exitBadArgs
+		}
+		if code := group.send(message, !*noWaitFlag); code != exitSuccess || *noWaitFlag {
+			return code
+		}
+		out.WaitingStart(timeout)
+		return group.await()
 	}
 
-	out.MessageSent(msgType, sessionID, messageID, len(*msgFlag), *timeoutFlag, !*noWaitFlag)
+	// Send message
+	entry, code := conv.send(message, !*noWaitFlag)
+	if code != exitSuccess {
+		return code
+	}
 
 	// If no-wait, we're done
 	if *noWaitFlag {
@@ -191,150 +313,411 @@ func run() int {
 	}
 
 	// Wait for response (human format shows extra waiting message)
-	out.WaitingStart(*timeoutFlag)
+	out.WaitingStart(timeout)
 
-	// Setup signal handling
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+	return conv.await(entry)
+}
 
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-
-	go func() {
-		<-sigChan
-		out.Cancelled()
-		cancel()
-	}()
-
-	// Track start time for wait duration
-	startTime := time.Now()
-
-	// Listen for response with reminders
-	listener := sse.NewListener(cfg.APIURL, cfg.APIKey)
-
-	event, err := listener.ListenWithOptions(ctx, sessionID, sse.ListenOptions{
-		Timeout:          *timeoutFlag,
-		ReminderInterval: reminderInterval,
-		OnEvent: func(e *sse.Event) {
-			// Calculate wait time
-			waitTime := time.Since(startTime)
-			// Determine channel from event source
-			channel := "SMS"
-			if e.From == "web" {
-				channel = "Web"
-			}
-			out.Response(sessionID, e.From, channel, e.Content, waitTime)
-		},
-		OnReminder: func(elapsed, remaining time.Duration) {
-			out.Waiting(sessionID, elapsed, remaining)
-		},
-	})
+// settingFlag is a flag that overrides a config setting
+type settingFlag struct {
+	name  string
+	key   string // JSON key of the setting
+	value *string
+}
 
-	if err != nil {
-		if strings.Contains(err.Error(), "timeout") {
-			out.Timeout(sessionID, *timeoutFlag)
-			return exitTimeout
+// settingFlags defines the flags that override config settings on fs
+func settingFlags(fs *flag.FlagSet) []settingFlag {
+	var flags []settingFlag
+	for _, f := range []struct{ name, key, usage string }{
+		{"format", "format", "Output format: llm, human, json"},
+		{"timeout", "timeout", "Timeout for waiting (default: 1h)"},
+		{"reminder", "reminder_interval", "Reminder interval (e.g., 15m, 0 to disable)"},
+		{"sys-name", "sys_name", "Name identifying the agent in messages"},
+		{"api-url", "api_url", "ChatBridge API URL"},
+	} {
+		flags = append(flags, settingFlag{f.name, f.key, fs.String(f.name, "", f.usage)})
+	}
+	return flags
+}
+
+// applySettingFlags overrides config settings with the flags given, which
+// take precedence over the environment and config files
+func applySettingFlags(cfg *config.Config, flags []settingFlag) error {
+	for _, f := range flags {
+		if *f.value == "" {
+			continue
+		}
+		if err := cfg.Set(f.key, *f.value, "--"+f.name); err != nil {
+			return fmt.Errorf("--%s: %w", f.name, err)
+		}
+	}
+	return nil
+}
+
+// checkDefaultChannel reports whether the configured default channel can be
+// used without a channel flag
+func checkDefaultChannel(name string) error {
+	r, ok := transport.Lookup(name)
+	if !ok {
+		return fmt.Errorf("unknown default channel %q (use %s)", name, channelList())
+	}
+	if r.Arg != "" {
+		return fmt.Errorf("default channel %q needs a %s; use --%s <%s>", name, r.Arg, name, r.Arg)
+	}
+	return nil
+}
+
+// loadConfig loads the config with the profile from --profile, or else
+// $AFK_PROFILE, applied
+func loadConfig(profile string) (*config.Config, error) {
+	if profile == "" {
+		profile = os.Getenv(config.ProfileEnv)
+	}
+	return config.LoadProfile(profile)
+}
+
+// loadSettings loads the config like loadConfig without requiring or
+// fetching credentials
+func loadSettings(profile string) (*config.Config, error) {
+	if profile == "" {
+		profile = os.Getenv(config.ProfileEnv)
+	}
+	return config.LoadSettings(profile)
+}
+
+// parseOptions combines --option flags and the --choices list
+func parseOptions(optionFlags []string, choices string) []string {
+	var options []string
+	for _, opt := range optionFlags {
+		if opt = strings.TrimSpace(opt); opt != "" {
+			options = append(options, opt)
+		}
+	}
+	for _, opt := range strings.Split(choices, ",") {
+		if opt = strings.TrimSpace(opt); opt != "" {
+			options = append(options, opt)
 		}
-		if strings.Contains(err.Error(), "cancelled") {
+	}
+	return options
+}
+
+// parseReminder parses a reminder interval, where "" or "0" disables reminders
+func parseReminder(interval string) (time.Duration, error) {
+	if interval == "0" || interval == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(interval)
+}
+
+// cmdWait re-attaches to a session that was already sent and waits for its reply
+func cmdWait(args []string) int {
+	fs := flag.NewFlagSet("wait", flag.ContinueOnError)
+	channelFlagSet := channelFlags(fs)
+	sessionFlag := fs.String("session", "", "Session ID to wait on (required)")
+	settings := settingFlags(fs)
+	quietFlag := fs.Bool("quiet", false, "Minimal output (just response content)")
+	maxRepromptsFlag := fs.Int("max-reprompts", 2, "Times to ask again when a reply matches no option")
+	profileFlag := fs.String("profile", "", "Config profile to use (default: $AFK_PROFILE)")
+
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
 			return exitSuccess
 		}
-		out.Error(503, err.Error(), sessionID)
-		return exitAPIError
+		return exitBadArgs
 	}
 
-	_ = event // Response already printed by OnEvent callback
-	return exitSuccess
+	if *sessionFlag == "" {
+		fmt.Fprintln(os.Stderr, "400 Bad Request: --session is required")
+		fmt.Fprintln(os.Stderr, "Run 'afk -h' for usage")
+		return exitBadArgs
+	}
+
+	// Load config
+	cfg, err := loadConfig(*profileFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "401 Unauthorized: %v\n", err)
+		return exitBadArgs
+	}
+
+	// Override config with flags if provided
+	applyChannelArgs(cfg, channelFlagSet)
+	if err := applySettingFlags(cfg, settings); err != nil {
+		fmt.Fprintf(os.Stderr, "400 Bad Request: %v\n", err)
+		return exitBadArgs
+	}
+
+	timeout, err := time.ParseDuration(cfg.Timeout)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "400 Bad Request: Invalid timeout: %v\n", err)
+		return exitBadArgs
+	}
+
+	reminderInterval, err := parseReminder(cfg.ReminderInterval)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "400 Bad Request: Invalid reminder interval: %v\n", err)
+		return exitBadArgs
+	}
+
+	out := output.New(cfg.Format, *quietFlag)
+
+	// Options sent with the original message still apply to its reply
+	entry := lookupHistory(*sessionFlag)
+
+	// Listen on the channel the message was sent on unless told otherwise
+	channel := entry.Transport
+	if channels := selectedChannels(channelFlagSet); len(channels) > 0 {
+		channel = channels[0]
+	}
+	if channel == "" {
+		channel = cfg.Channel
+	}
+	if channel == "" {
+		channel = "whatsapp"
+	}
+	if channel == "webhook" {
+		if err := transport.WebhookResumable(cfg); err != nil {
+			fmt.Fprintf(os.Stderr, "400 Bad Request: Cannot resume a webhook session: %v\n", err)
+			return exitBadArgs
+		}
+	}
+	out.Resumed(*sessionFlag, timeout)
+
+	conv, err := newConversation(cfg, out, channel)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "401 Unauthorized: %v\n", err)
+		return exitBadArgs
+	}
+	conv.timeout = timeout
+	conv.reminderInterval = reminderInterval
+	conv.options = entry.Options
+	conv.maxReprompts = *maxRepromptsFlag
+	conv.approval = entry.Approval
+
+	return conv.await(entry)
 }
 
+func cmdLogin(args []string) int {
+	fs := flag.NewFlagSet("login", flag.ContinueOnError)
+	profileFlag := fs.String("profile", "", "Save the credentials in this profile")
+	storeFlag := fs.String("store", "", "Where to keep the API key: config, keyring or file (asked if not set)")
+	apiKeyStdinFlag := fs.Bool("api-key-stdin", false, "Read the API key from stdin and ask nothing (for scripts)")
+	apiURLFlag := fs.String("api-url", "", "ChatBridge API URL (default: "+config.DefaultAPIURL+")")
+	sysNameFlag := fs.String("sys-name", "", "Name identifying the agent in messages (default: AI Agent)")
+	skipValidateFlag := fs.Bool("skip-validate", false, "Save without checking the API URL and key")
+	formatFlag := fs.String("format", "", "Output format: llm, human, json")
+
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitSuccess
+		}
+		return exitBadArgs
+	}
 
-func cmdLogin() int {
-	fmt.Println("ChatBridge Login")
-	fmt.Println("================")
-	fmt.Println()
+	// With --format json only the result goes to stdout; prompts and
+	// progress go to stderr
+	out := output.New(config.OutputFormat(*formatFlag), false)
+	jsonOut := *formatFlag == string(config.FormatJSON)
+	info := io.Writer(os.Stdout)
+	if jsonOut {
+		info = os.Stderr
+	}
+	fail := func(code, status int, err error) int {
+		if jsonOut {
+			out.Error(status, err.Error(), "")
+		} else {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		}
+		return code
+	}
+	interactive := !*apiKeyStdinFlag
+
+	if interactive {
+		fmt.Fprintln(info, "ChatBridge Login")
+		fmt.Fprintln(info, "================")
+		if *profileFlag != "" {
+			fmt.Fprintf(info, "Profile: %s\n", *profileFlag)
+		}
+		fmt.Fprintln(info)
+	}
 
 	reader := bufio.NewReader(os.Stdin)
+	ask := func(prompt string) string {
+		fmt.Fprint(info, prompt)
+		answer, _ := reader.ReadString('\n')
+		return strings.TrimSpace(answer)
+	}
 
 	// Get API key
-	fmt.Print("API Key: ")
-	apiKey, _ := reader.ReadString('\n')
-	apiKey = strings.TrimSpace(apiKey)
+	var apiKey string
+	if interactive {
+		apiKey = ask("API Key: ")
+	} else {
+		data, err := io.ReadAll(io.LimitReader(os.Stdin, 4096))
+		if err != nil {
+			return fail(exitBadArgs, 400, fmt.Errorf("failed to read API key from stdin: %w", err))
+		}
+		apiKey = strings.TrimSpace(string(data))
+		if strings.ContainsAny(apiKey, "\r\n") {
+			return fail(exitBadArgs, 400, errors.New("--api-key-stdin expects only the API key on stdin"))
+		}
+	}
 
 	if apiKey == "" {
-		fmt.Fprintln(os.Stderr, "Error: API key is required")
-		return exitBadArgs
+		return fail(exitBadArgs, 400, errors.New("API key is required"))
 	}
 
 	// Validate API key format
 	if !strings.HasPrefix(apiKey, "cb_live_") && !strings.HasPrefix(apiKey, "cb_test_") {
-		fmt.Fprintln(os.Stderr, "Error: Invalid API key format (should start with cb_live_ or cb_test_)")
-		return exitBadArgs
+		return fail(exitBadArgs, 400, errors.New("invalid API key format (should start with cb_live_ or cb_test_)"))
 	}
 
 	// Get API URL (with default)
-	fmt.Printf("API URL [default: %s]: ", config.DefaultAPIURL)
-	apiURL, _ := reader.ReadString('\n')
-	apiURL = strings.TrimSpace(apiURL)
+	apiURL := *apiURLFlag
+	if apiURL == "" && interactive {
+		apiURL = ask(fmt.Sprintf("API URL [default: %s]: ", config.DefaultAPIURL))
+	}
 	if apiURL == "" {
 		apiURL = config.DefaultAPIURL
 	}
+	if err := config.CheckSetting("api_url", apiURL); err != nil {
+		return fail(exitBadArgs, 400, err)
+	}
 
 	// Get system name for WhatsApp messages
-	fmt.Print("System Name (for WhatsApp, e.g., 'Claude Code') [default: AI Agent]: ")
-	sysName, _ := reader.ReadString('\n')
-	sysName = strings.TrimSpace(sysName)
+	sysName := *sysNameFlag
+	if sysName == "" && interactive {
+		sysName = ask("System Name (for WhatsApp, e.g., 'Claude Code') [default: AI Agent]: ")
+	}
 	if sysName == "" {
 		sysName = "AI Agent"
 	}
 
-	// Test connection
-	fmt.Print("\nTesting connection... ")
-	client := api.NewClient(apiURL, apiKey)
+	if !*skipValidateFlag {
+		// Test connection
+		fmt.Fprint(info, "\nTesting connection... ")
+		client := api.NewClient(apiURL, apiKey)
 
-	_, err := client.Health()
-	if err != nil {
-		fmt.Printf("✗ Failed\n")
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		return exitAPIError
+		if _, err := client.Health(); err != nil {
+			fmt.Fprintln(info, "✗ Failed")
+			return fail(exitAPIError, 503, err)
+		}
+		fmt.Fprintln(info, "✓ Connected")
+
+		// Validate API key
+		fmt.Fprint(info, "Validating API key... ")
+		if err := client.ValidateKey(); err != nil {
+			fmt.Fprintln(info, "✗ Failed")
+			return fail(exitAPIError, 401, err)
+		}
+		fmt.Fprintln(info, "✓ Valid")
 	}
-	fmt.Println("✓ Connected")
 
-	// Validate API key
-	fmt.Print("Validating API key... ")
-	if err := client.ValidateKey(); err != nil {
-		fmt.Printf("✗ Failed\n")
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		return exitAPIError
+	// Keep the key in the config unless a secret backend is chosen. Only
+	// an interactive login asks.
+	backend := *storeFlag
+	switch {
+	case backend == "config":
+		backend = ""
+	case backend == "" && interactive:
+		var err error
+		if backend, err = chooseKeyStore(reader, info); err != nil {
+			return fail(exitBadArgs, 400, err)
+		}
+	}
+	storage := config.PlaintextStorage
+	keyRef := ""
+	storedKey := apiKey
+	if backend != "" {
+		store, err := config.SecretStore(backend)
+		if err == nil {
+			keyRef, err = storeAPIKey(store, backend, *profileFlag, apiKey)
+		}
+		if err != nil {
+			return fail(exitBadArgs, 400, err)
+		}
+		storage = store.Description()
+		storedKey = ""
 	}
-	fmt.Println("✓ Valid")
 
-	// Save config
-	cfg := &config.Config{
-		APIKey:  apiKey,
-		APIURL:  apiURL,
-		SysName: sysName,
+	// Save config, keeping other settings and profiles
+	cfg, err := config.Read()
+	if err != nil {
+		return fail(exitBadArgs, 400, err)
+	}
+	if *profileFlag == "" {
+		cfg.APIKey = storedKey
+		cfg.APIKeyRef = keyRef
+		cfg.APIURL = apiURL
+		cfg.SysName = sysName
+	} else if err := cfg.SetProfileValues(*profileFlag, map[string]interface{}{
+		"api_key":     storedKey,
+		"api_key_ref": keyRef,
+		"api_url":     apiURL,
+		"sys_name":    sysName,
+	}); err != nil {
+		return fail(exitBadArgs, 400, err)
 	}
 
 	if err := config.Save(cfg); err != nil {
-		fmt.Fprintf(os.Stderr, "Error saving config: %v\n", err)
-		return exitBadArgs
+		return fail(exitBadArgs, 500, fmt.Errorf("saving config: %w", err))
 	}
 
-	fmt.Println()
-	fmt.Printf("Credentials saved to %s\n", config.Path())
-	fmt.Println()
-	fmt.Println("You can now use:")
-	fmt.Println("  afk --sms --msg \"Your message\"")
-	fmt.Println("  afk --whatsapp --msg \"Your message\"")
-
+	out.LoggedIn(output.LoginResult{
+		Profile:    *profileFlag,
+		Config:     config.Path(),
+		APIURL:     apiURL,
+		SysName:    sysName,
+		KeyPrefix:  keyPrefix(apiKey),
+		KeyStorage: storage,
+		Validated:  !*skipValidateFlag,
+	})
 	return exitSuccess
 }
 
-func cmdLogout() int {
+func cmdLogout(args []string) int {
+	fs := flag.NewFlagSet("logout", flag.ContinueOnError)
+	profileFlag := fs.String("profile", "", "Remove only this profile")
+
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitSuccess
+		}
+		return exitBadArgs
+	}
+
 	if !config.Exists() {
 		fmt.Println("Already logged out")
 		return exitSuccess
 	}
 
+	if *profileFlag != "" {
+		cfg, err := config.Read()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return exitBadArgs
+		}
+		if _, ok := cfg.Profiles[*profileFlag]; !ok {
+			fmt.Printf("No profile %q\n", *profileFlag)
+			return exitSuccess
+		}
+		forgetAPIKey(profileKeyRef(cfg, *profileFlag))
+		delete(cfg.Profiles, *profileFlag)
+		if err := config.Save(cfg); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return exitBadArgs
+		}
+		fmt.Printf("Profile %q removed.\n", *profileFlag)
+		return exitSuccess
+	}
+
+	// Remove keys kept in the keyring or encrypted file too
+	if cfg, err := config.Read(); err == nil {
+		forgetAPIKey(cfg.APIKeyRef)
+		for _, name := range cfg.ProfileNames() {
+			forgetAPIKey(profileKeyRef(cfg, name))
+		}
+	}
+
 	if err := config.Delete(); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		return exitBadArgs
@@ -349,8 +732,14 @@ func cmdStatus() int {
 	fmt.Println("=================")
 	fmt.Println()
 
+	stored, err := config.Read()
+	if err == nil && len(stored.Profiles) > 0 {
+		fmt.Printf("Credentials: %s ✓\n", config.Path())
+		return statusProfiles(stored)
+	}
+
 	// Check config
-	cfg, err := config.Load()
+	cfg, err := config.LoadProfile("")
 	if err != nil {
 		fmt.Printf("Credentials: %s ✗ Not configured\n", config.Path())
 		fmt.Println()
@@ -359,33 +748,135 @@ func cmdStatus() int {
 	}
 
 	fmt.Printf("Credentials: %s ✓\n", config.Path())
-	fmt.Printf("API: %s ", cfg.APIURL)
 
-	// Check API health
-	client := api.NewClient(cfg.APIURL, cfg.APIKey)
-	_, err = client.Health()
-	if err != nil {
-		fmt.Println("✗ Offline")
-		fmt.Fprintf(os.Stderr, "  Error: %v\n", err)
-		return exitAPIError
+	code := checkConfig(cfg)
+	if code == exitSuccess {
+		fmt.Println()
+		fmt.Println("Ready to send messages.")
+	}
+
+	return code
+}
+
+// statusProfiles checks the settings outside any profile, then each profile
+func statusProfiles(stored *config.Config) int {
+	active := os.Getenv(config.ProfileEnv)
+	code := exitSuccess
+
+	for _, name := range append([]string{""}, stored.ProfileNames()...) {
+		label := name
+		if name == "" {
+			label = "(none)"
+		}
+		if name == active {
+			label += " ← active"
+		}
+		fmt.Println()
+		fmt.Printf("Profile: %s\n", label)
+
+		cfg, err := config.LoadProfile(name)
+		if err != nil {
+			// Credentials may live only in the profiles
+			if name == "" {
+				fmt.Println("  No credentials outside profiles")
+				continue
+			}
+			fmt.Printf("  ✗ %v\n", err)
+			code = exitBadArgs
+			continue
+		}
+		if c := checkConfig(cfg); c != exitSuccess {
+			code = c
+		}
+	}
+
+	if code == exitSuccess {
+		fmt.Println()
+		fmt.Println("Ready to send messages.")
+	}
+	return code
+}
+
+// checkConfig prints the status of ChatBridge and every other configured
+// channel in cfg
+func checkConfig(cfg *config.Config) int {
+	code := exitSuccess
+
+	if cfg.APIKey != "" {
+		fmt.Printf("API: %s ", cfg.APIURL)
+
+		// Check API health
+		client := api.NewClient(cfg.APIURL, cfg.APIKey)
+		_, err := client.Health()
+		if err != nil {
+			fmt.Println("✗ Offline")
+			fmt.Fprintf(os.Stderr, "  Error: %v\n", err)
+			return exitAPIError
+		}
+		fmt.Println("✓ Online")
+
+		// Validate API key
+		fmt.Print("API Key: ")
+		if err := client.ValidateKey(); err != nil {
+			fmt.Println("✗ Invalid")
+			fmt.Fprintf(os.Stderr, "  Error: %v\n", err)
+			return exitAPIError
+		}
+		fmt.Printf("✓ Valid (%s...)\n", keyPrefix(cfg.APIKey))
+		fmt.Printf("Key storage: %s\n", cfg.KeyStorage())
 	}
-	fmt.Println("✓ Online")
 
-	// Validate API key
-	fmt.Print("API Key: ")
-	if err := client.ValidateKey(); err != nil {
-		fmt.Println("✗ Invalid")
+	// Check every other configured channel once per service
+	checked := map[string]bool{"ChatBridge": true}
+	for _, r := range transport.Registrations() {
+		if checked[r.Service] || !r.Configured(cfg) {
+			continue
+		}
+		checked[r.Service] = true
+
+		if !checkChannel(cfg, r) {
+			code = exitAPIError
+		}
+	}
+
+	return code
+}
+
+// checkChannel prints the health and credential status of one channel
+func checkChannel(cfg *config.Config, r transport.Registration) bool {
+	fmt.Printf("%s: ", r.Service)
+
+	t, err := r.New(cfg)
+	if err != nil {
+		fmt.Println("✗ Not configured")
 		fmt.Fprintf(os.Stderr, "  Error: %v\n", err)
-		return exitAPIError
+		return false
 	}
-	fmt.Printf("✓ Valid (%s...)\n", cfg.APIKey[:16])
 
-	fmt.Println()
-	fmt.Println("Ready to send messages.")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
 
-	return exitSuccess
+	if err := t.Health(ctx); err != nil {
+		fmt.Println("✗ Offline")
+		fmt.Fprintf(os.Stderr, "  Error: %v\n", err)
+		return false
+	}
+	if err := t.Validate(ctx); err != nil {
+		fmt.Println("✓ Online, ✗ Invalid credentials")
+		fmt.Fprintf(os.Stderr, "  Error: %v\n", err)
+		return false
+	}
+	fmt.Println("✓ Online, credentials valid")
+	return true
 }
 
+// keyPrefix returns the start of an API key for display
+func keyPrefix(key string) string {
+	if len(key) > 16 {
+		return key[:16]
+	}
+	return key
+}
 
 func printHelp() {
 	help := `afk - Away From Keyboard messenger for AI agents
@@ -396,24 +887,107 @@ Codex, Amp, etc.) to communicate with developers who are AFK.
 
 USAGE:
   afk login                    # Store API credentials (run once)
+  afk login --profile dev      # Store credentials in a named profile
+  afk login --store keyring    # Keep the API key in the system keyring
+  echo "$KEY" | afk login --api-key-stdin  # Log in from a script, asking nothing
   afk logout                   # Remove stored credentials
   afk status                   # Check connection and quota
   afk --sms --msg "text"       # Send SMS and wait for response
   afk --whatsapp --msg "text"  # Send WhatsApp and wait for response
+  afk --slack --msg "text"     # Post to Slack and wait for the threaded reply
+  afk --telegram --msg "text"  # Message a Telegram chat and wait for the reply
+  afk --email --msg "text"     # Send an email and wait for the emailed reply
+  afk --discord --msg "text"   # Send a Discord DM and wait for the reply
+  afk --matrix --msg "text"    # Post to a Matrix room and wait for the reply
+  afk --ntfy --choices A,B --msg "text"  # Push with one-tap answer buttons
+  afk --webhook <url> --msg "text"       # POST a signed envelope and wait for the answer
+  afk --local --msg "text"     # Ask on this machine (try out prompts for free)
+  afk wait --session <id>      # Wait for the reply to an already-sent message
+  afk history                  # Show past messages and replies
+  afk config show              # Show each setting and where it came from
+  afk config set timeout 30m   # Change a setting, checking its value
+  afk contacts add dba --slack U123 --email dba@example.com  # Save a contact for --to
+  afk approve --whatsapp --msg "text"  # Ask yes/no; exit 0 only if approved
   afk -v                       # Show version
   afk -h                       # Show this help
 
 MESSAGE FLAGS:
   --sms          Send message via SMS
   --whatsapp     Send message via WhatsApp
-  --msg          Message content (required with --sms or --whatsapp)
+  --slack        Send message via Slack (see CONFIGURATION)
+  --telegram     Send message via Telegram (see CONFIGURATION)
+  --email        Send message via email (see CONFIGURATION)
+  --discord      Send message via Discord direct message (see CONFIGURATION)
+  --matrix       Send message via Matrix (see CONFIGURATION)
+  --ntfy         Send push notification via ntfy (see CONFIGURATION)
+  --webhook <url>  POST the message to a webhook endpoint (see CONFIGURATION)
+  --local        Ask on this machine: desktop notification and terminal prompt
+  --msg          Message content (required with --sms or --whatsapp, '-' reads stdin)
+  --msg-file     Read message content verbatim from a file ('-' for stdin, max 64KB)
   --session      Session ID for grouping messages (auto-generated if not set)
   --no-wait      Send message and exit without waiting for response
   --no-hint      Don't append '[No reply expected]' (saves chars for SMS)
   --timeout      How long to wait for response (default: 1h, e.g., 30m, 2h)
   --reminder     Reminder interval while waiting (default: 15m, 0 to disable)
   --format       Output format: llm (default), human, json
+  --sys-name     Name identifying the agent in messages (overrides sys_name)
+  --api-url      ChatBridge API URL (overrides api_url)
+  --quiet        Minimal output (just response content)
+  --option       Multiple-choice option; repeat for each (numbered in the message)
+  --choices      Comma-separated multiple-choice options (alternative to --option)
+  --max-reprompts  Times to ask again when a reply matches no option (default: 2)
+  --approval     Ask a yes/no question (same as 'afk approve'); see EXIT CODES
+  --escalate     Also send on the configured escalation chain while no reply arrives
+  --to           Send to these contacts, recipients or groups (comma-separated)
+  --require      Replies needed from --to recipients: any (default), all or a number
+  --profile      Use this profile from the config (default: $AFK_PROFILE)
+
+LOGIN FLAGS (afk login):
+  --api-key-stdin  Read the API key from stdin and ask nothing (for scripts)
+  --api-url      ChatBridge API URL (default: https://chatbridge.net)
+  --sys-name     Name identifying the agent in messages (default: AI Agent)
+  --profile      Save the credentials in this profile
+  --store        Where to keep the API key: config (default), keyring or file
+  --skip-validate  Save without checking the API URL and key
+  --format       json prints the result as JSON (prompts go to stderr)
+
+WAIT FLAGS (afk wait):
+  --session      Session ID printed when the message was sent (required)
+  --timeout      How long to wait for response (default: 1h)
+  --reminder     Reminder interval while waiting (default: 15m, 0 to disable)
+  --format       Output format: llm (default), human, json
+  --sys-name     Name identifying the agent in messages (overrides sys_name)
+  --api-url      ChatBridge API URL (overrides api_url)
   --quiet        Minimal output (just response content)
+  --profile      Use this profile from the config (default: $AFK_PROFILE)
+
+HISTORY FLAGS (afk history):
+  --since        Only entries sent after this (duration like 24h, date, or RFC 3339)
+  --until        Only entries sent before this (same forms as --since)
+  --channel      Only this channel (SMS, WhatsApp)
+  --outcome      Only this outcome: pending, sent, replied, timeout, cancelled, error, escalated, skipped
+  --search       Case-insensitive text to find in the message or reply
+  --limit        Show the most recent N entries (default: 20, 0 for all)
+  --format       Output format: llm (default), human, json
+
+CONTACTS (afk contacts list|add|remove):
+  afk contacts                 List contacts, their addresses and groups
+  afk contacts add <name>      Add or update a contact:
+    --description "DBA on call"  --phone +15551234567  --slack U123
+    --email dba@example.com  --telegram 123  --discord 456  --matrix '!room:hs'
+    --ntfy topic  --group oncall (repeatable)
+  afk contacts remove <name>   Remove a contact and its group memberships
+  --file         Edit this shared contacts file instead of the config
+
+CONFIG (afk config show|get|set|unset|list|validate|edit):
+  afk config show              Effective settings and their sources
+  afk config get <key>         Effective value of one setting (--reveal for credentials)
+  afk config set <key> <value> Check and store a setting, e.g. format json
+  afk config unset <key>       Remove a setting so its default applies
+  afk config list              Settings stored in the config file
+  afk config validate          Check the config file and the project's .afk.json
+  afk config edit              Edit the config in $EDITOR; saved only if valid
+  --profile      Read or change this profile (show, get, set, unset, list)
 
 FOR AI AGENTS (Claude Code, Codex, Amp, etc.):
   ═══════════════════════════════════════════════════════════════════════
@@ -439,6 +1013,11 @@ FOR AI AGENTS (Claude Code, Codex, Amp, etc.):
     (\! → !, \? → ?, \* → *, \[ → [, \] → ]) so your message
     arrives correctly regardless of which shell you use.
 
+    To avoid quoting entirely, pipe the message in or read it from a file.
+    These are sent verbatim (no unescaping):
+      go test ./... 2>&1 | tail -20 | afk --whatsapp --msg -
+      afk --whatsapp --msg-file /tmp/question.txt
+
   When to use afk:
     - You need a decision that only the developer can make
     - The task is blocked until you get human input
@@ -457,6 +1036,11 @@ FOR AI AGENTS (Claude Code, Codex, Amp, etc.):
     3. SQLite (persistent, slower)
     Which should I use?"
 
+  Example - Multiple choice (reply is matched by number, name or prefix):
+    afk --whatsapp --msg "Which cache should I use?" \
+      --option Redis --option "In-memory" --option SQLite
+    # Output includes <choice index="1">Redis</choice> (JSON: choice, choice_index)
+
   Example - Long message (auto-creates web link for SMS > 255 chars):
     afk --sms --msg "I need your input on the database schema. Here are the options:
 
@@ -473,6 +1057,11 @@ FOR AI AGENTS (Claude Code, Codex, Amp, etc.):
   Example - Quick notification without waiting:
     afk --whatsapp --msg "Build completed! Tests: 142 passed." --no-wait
 
+  Example - Ask now, collect the answer later:
+    afk --whatsapp --msg "OK to drop the legacy table?" --no-wait --no-hint
+    # ... do other work, then block on the reply using the printed Session:
+    afk wait --session <session-id> --timeout 30m
+
   Tips:
     - SMS messages > 255 chars automatically become web links
     - WhatsApp is usually faster and supports richer formatting
@@ -487,8 +1076,18 @@ FOR AI AGENTS (Claude Code, Codex, Amp, etc.):
 
 CONFIGURATION:
   Credentials stored in: ~/.afk/config.json
+  Message history in:    ~/.afk/history.jsonl
   Run 'afk login' to configure your API key
 
+  Environment variables (override the config file; flags override them):
+    AFK_API_KEY     api_key          AFK_FORMAT     format
+    AFK_API_URL     api_url          AFK_REMINDER   reminder_interval
+    AFK_SYS_NAME    sys_name         AFK_CONFIG     Config file path
+    AFK_PROFILE     Profile to use   AFK_PASSPHRASE Secrets file passphrase
+  With AFK_API_KEY set, no config file is needed (e.g. in CI or containers).
+  Precedence: flag > environment > project file > config file > default.
+  'afk config show' prints every setting with its source.
+
   Config file options:
     {
       "api_key": "cb_test_...",
@@ -499,13 +1098,108 @@ CONFIGURATION:
     }
 
   sys_name: Identifies the AI agent in WhatsApp messages (e.g., "Claude Code")
+  api_key_ref: Where the API key is kept instead of api_key. 'afk login'
+    offers the system keyring ("keyring:default", via secret-tool) or
+    ~/.afk/secrets.enc, encrypted with a passphrase ("file:default";
+    asked on the terminal or read from AFK_PASSPHRASE)
+  channel: Channel used when no channel flag is given (e.g., "slack")
+  timeout: How long to wait for a reply (default: 1h)
+  message_prefix: Prepended to every message (e.g., "[payments-svc]")
+
+  Project config (optional): a .afk.json in the working directory or a
+  parent is merged over the config, so each repository can set its own
+  sys_name, channel, timeout, message_prefix, format and reminder_interval.
+  It may be committed, so any other setting (endpoints, credentials,
+  contacts or recipients) is refused there.
+
+  Profiles (optional, for --profile or AFK_PROFILE):
+    "profiles": {
+      "work": {"api_key": "cb_live_...", "sys_name": "Claude Code (work)"},
+      "dev":  {"api_key": "cb_test_...", "api_url": "https://dev.chatbridge.net"}
+    }
+    A profile's settings apply over the rest of the config. 'afk login
+    --profile <name>' saves credentials into one, 'afk logout --profile
+    <name>' removes it, and 'afk status' checks them all.
+
+  Slack (optional, for --slack):
+    "slack_bot_token": "xoxb-...",   Bot token (chat:write, im:history,
+                                     channels:history, users:read)
+    "slack_app_token": "xapp-...",   App-level token; enables Socket Mode,
+                                     otherwise replies are polled every 5s
+    "slack_channel":   "U0123ABCD"   User ID (direct message) or channel ID
+
+  Telegram (optional, for --telegram):
+    "telegram_bot_token": "123:ABC...",  Token from @BotFather
+    "telegram_chat_id":   "123456789"    Chat ID (your user ID for a private chat)
+
+  Email (optional, for --email):
+    "email_from":      "afk@example.com",     Sender address
+    "email_to":        "you@example.com",     Recipient address
+    "email_smtp_addr": "smtp.example.com:587", 465 = TLS, otherwise STARTTLS
+    "email_imap_addr": "imap.example.com:993", 993 = TLS, otherwise STARTTLS
+    "email_username":  "afk@example.com",     Login (default: email_from)
+    "email_password":  "..."                  SMTP and IMAP password
+
+  Discord (optional, for --discord):
+    "discord_bot_token": "...",             Bot token from the Developer Portal
+    "discord_user_id":   "123456789012345"  Your user ID (the bot DMs you)
+
+  Matrix (optional, for --matrix):
+    "matrix_homeserver":   "https://matrix.example.org",
+    "matrix_access_token": "syt_...",          Access token of the bot account
+    "matrix_room_id":      "!abc:example.org", Room to post in
+    "matrix_require_relation": true            Only accept threaded replies or
+                                               replies to afk's message
+
+  ntfy (optional, for --ntfy):
+    "ntfy_server":      "https://ntfy.sh",  Server URL (default: https://ntfy.sh)
+    "ntfy_topic":       "afk-x7Qp2",        Topic your phone subscribes to
+    "ntfy_reply_topic": "afk-x7Qp2-reply",  Prefix of per-question answer topics
+                                            (default: <topic>-reply)
+    "ntfy_token":       "tk_..."            Access token for protected topics
+                                            (not sent in buttons)
+
+  Webhook (optional, for --webhook <url>):
+    "webhook_secret":    "...",                   Shared HMAC-SHA256 secret (16+ chars)
+    "webhook_listen":    "127.0.0.1:8789",        Reply listener address (default: a free
+                                                  127.0.0.1 port per question)
+    "webhook_reply_url": "https://.../reply",     Public URL of the listener, if proxied
+    "webhook_poll_url":  "https://.../{session}"  Poll for the answer instead of listening
+    --no-wait and 'afk wait' need webhook_poll_url or a fixed webhook_listen port.
+
+  Local (optional, for --local):
+    "local_no_notify": true     Prompt on the terminal only, no desktop notification
+
+  Escalation (optional, for --escalate):
+    "escalation": [
+      {"channel": "sms", "after": "10m"},
+      {"channel": "email", "after": "30m", "config": {"email_to": "oncall@example.com"}}
+    ]
+    Each step is sent when no reply has arrived "after" the first message,
+    on top of the earlier ones. "config" overrides settings for that step
+    (e.g. a secondary contact); "arg" is the value for --webhook.
+
+  Recipients (optional, for --to):
+    "recipients": {
+      "alice": {"slack_channel": "U123"},
+      "bob":   {"slack_channel": "U456"}
+    },
+    "groups": {"prod-approvers": ["alice", "bob"]}
+    Each recipient lists the settings that reach them on the chosen channel.
+
+  Contacts (optional, for --to; see 'afk contacts'):
+    "contacts": {"dba": {"slack": "U123", "email": "dba@example.com"}},
+    "contacts_file": "~/team/afk-contacts.json"  Shared {"contacts", "groups"} file
 
 EXIT CODES:
-  0 - Success (message sent, response received if waiting)
+  0 - Success (message sent, response received if waiting; approved)
   1 - Invalid arguments or configuration error
   2 - API connection failed
   3 - Timeout waiting for response
   4 - Message send failed
+  5 - Approval denied (afk approve / --approval)
+  6 - Approval reply unclear (neither yes nor no)
+  7 - Approval wait cancelled (Ctrl+C or SIGTERM)
 
 EXAMPLES:
   # First-time setup
@@ -519,6 +1213,9 @@ EXAMPLES:
   afk --whatsapp --msg "Build complete! Tests passed." --no-wait
   afk --sms --msg "Task completed: Database migration finished!" --no-wait
 
+  # Gate a deployment on a human yes (exit 0 approved, 5 denied, 6 unclear)
+  afk approve --whatsapp --msg "Deploy v2.3 to production?" && ./deploy.sh
+
   # Check your connection
   afk status`
 
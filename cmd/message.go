@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxMessageBytes caps the size of a message body from any source
+const maxMessageBytes = 64 * 1024
+
+// readMessage returns the message body from --msg or --msg-file. A value of
+// "-" reads from stdin. Bodies from files and stdin are used verbatim apart
+// from trailing line endings; only inline --msg values are shell-unescaped.
+func readMessage(msg, msgFile string) (string, error) {
+	if msg != "" && msgFile != "" {
+		return "", fmt.Errorf("cannot use both --msg and --msg-file")
+	}
+
+	var message string
+	switch {
+	case msg == "-" || msgFile == "-":
+		body, err := readLimited(os.Stdin, "stdin")
+		if err != nil {
+			return "", err
+		}
+		message = body
+
+	case msgFile != "":
+		f, err := os.Open(msgFile)
+		if err != nil {
+			return "", fmt.Errorf("failed to open message file: %w", err)
+		}
+		defer f.Close()
+
+		body, err := readLimited(f, msgFile)
+		if err != nil {
+			return "", err
+		}
+		message = body
+
+	default:
+		message = unescapeShell(msg)
+		if len(message) > maxMessageBytes {
+			return "", fmt.Errorf("message is %d bytes, limit is %d", len(message), maxMessageBytes)
+		}
+	}
+
+	if !utf8.ValidString(message) {
+		return "", fmt.Errorf("message is not valid UTF-8")
+	}
+	if strings.TrimSpace(message) == "" {
+		return "", fmt.Errorf("message is empty")
+	}
+
+	return message, nil
+}
+
+// readLimited reads all of r, failing if it exceeds maxMessageBytes
+func readLimited(r io.Reader, name string) (string, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxMessageBytes+1))
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	if len(data) > maxMessageBytes {
+		return "", fmt.Errorf("message from %s exceeds %d bytes", name, maxMessageBytes)
+	}
+
+	// Heredocs and most editors end the file with a newline; drop it
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// unescapeShell undoes escapes that Claude Code may add in double-quoted strings.
+// These appear when shell history expansion or other escaping is applied.
+func unescapeShell(message string) string {
+	message = strings.ReplaceAll(message, "\\!", "!") // History expansion
+	message = strings.ReplaceAll(message, "\\?", "?") // Glob pattern
+	message = strings.ReplaceAll(message, "\\*", "*") // Glob pattern
+	message = strings.ReplaceAll(message, "\\[", "[") // Glob pattern
+	message = strings.ReplaceAll(message, "\\]", "]") // Glob pattern
+	return message
+}
@@ -0,0 +1,440 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/davedotdev/afk/internal/approval"
+	"github.com/davedotdev/afk/internal/choice"
+	"github.com/davedotdev/afk/internal/config"
+	"github.com/davedotdev/afk/internal/history"
+	"github.com/davedotdev/afk/internal/output"
+	"github.com/davedotdev/afk/internal/transport"
+)
+
+// Recipient statuses reported by output.Responses
+const (
+	statusReplied = "replied"
+	statusPending = "pending"
+	statusTimeout = "timeout"
+	statusSkipped = "skipped"
+	statusError   = "error"
+)
+
+// member is one recipient of a message sent with --to
+type member struct {
+	name     string
+	conv     *conversation
+	leg      *leg // nil until sent
+	status   string
+	reply    *transport.Reply
+	waitTime time.Duration
+	decision approval.Decision
+}
+
+// broadcast sends one message to several recipients on the same channel and
+// waits until the --require policy is met. Multiple-choice replies are
+// matched but not re-prompted.
+type broadcast struct {
+	out      *output.Formatter
+	members  []*member
+	require  string // --require as given: any, all or a number
+	needed   int    // Replies needed, or approvals for an approval request
+	timeout  time.Duration
+	options  []string
+	approval bool
+}
+
+// newBroadcast creates a conversation per recipient from template, each
+// with the recipient's settings applied over the template's config. Two
+// recipients reaching the same destination are rejected, as one person
+// could otherwise answer for both and meet --require alone, as are two
+// whose waits would compete for one update stream.
+func newBroadcast(template *conversation, names []string, require string, needed int) (*broadcast, error) {
+	b := &broadcast{
+		out:      template.out,
+		require:  require,
+		needed:   needed,
+		timeout:  template.timeout,
+		options:  template.options,
+		approval: template.approval,
+	}
+	r, _ := transport.Lookup(template.channel)
+	reached := make(map[string]string) // Destination to the recipient reaching it
+	polled := make(map[string]string)  // Update stream to the recipient reading it
+	for _, name := range names {
+		cfg, err := recipientConfig(template.cfg, template.channel, name)
+		if err != nil {
+			return nil, err
+		}
+		if r.Destination != nil {
+			destination := r.Destination(cfg)
+			if other, ok := reached[destination]; ok {
+				return nil, fmt.Errorf("recipients %q and %q reach the same %s destination (give each their own)", other, name, template.channel)
+			}
+			reached[destination] = name
+		}
+		if r.Poller != nil {
+			poller := r.Poller(cfg)
+			if other, ok := polled[poller]; ok {
+				return nil, fmt.Errorf("recipients %q and %q use the same %s bot, which only one wait can poll at a time (give each their own bot)", other, name, template.channel)
+			}
+			polled[poller] = name
+		}
+		t, err := transport.New(template.channel, cfg)
+		if err != nil {
+			return nil, fmt.Errorf("recipient %q: %w", name, err)
+		}
+		conv := *template
+		conv.cfg = cfg
+		conv.transport = t
+		conv.recipient = name
+		b.members = append(b.members, &member{name: name, conv: &conv})
+	}
+	return b, nil
+}
+
+// send delivers message to every recipient. It fails when too few are
+// reached to meet the policy, or with --no-wait when any is missed.
+func (b *broadcast) send(message string, waiting bool) int {
+	sent := 0
+	for _, m := range b.members {
+		entry, code := m.conv.send(message, waiting)
+		if code != exitSuccess {
+			m.status = statusError
+			continue
+		}
+		m.leg = &leg{channel: m.conv.channel, transport: m.conv.transport, entry: entry}
+		m.conv.legs = []*leg{m.leg}
+		m.status = statusPending
+		sent++
+	}
+
+	if !waiting {
+		if sent < len(b.members) {
+			return exitSendFailed
+		}
+		return exitSuccess
+	}
+	if sent < b.needed {
+		for _, m := range b.members {
+			if m.leg != nil {
+				finishHistory(m.leg.entry, nil, exitSendFailed)
+			}
+		}
+		return exitSendFailed
+	}
+	return exitSuccess
+}
+
+// await listens on every recipient's session at once until the policy is
+// met or can no longer be, then prints the replies and returns the exit code
+func (b *broadcast) await() int {
+	parent, stop := interruptible(b.out)
+	defer stop()
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+
+	startTime := time.Now()
+
+	type result struct {
+		member *member
+		reply  *transport.Reply
+		err    error
+	}
+	results := make(chan result, len(b.members))
+	listening := 0
+	for _, m := range b.members {
+		if m.leg == nil {
+			continue
+		}
+		// Only the first recipient prints reminders
+		if listening > 0 {
+			m.conv.reminderInterval = 0
+		}
+		listening++
+		go func(m *member) {
+			reply, err := m.conv.listen(ctx, m.leg, b.timeout)
+			results <- result{m, reply, err}
+		}(m)
+	}
+
+	var failed error
+	for listening > 0 && !b.settled() {
+		res := <-results
+		listening--
+		m := res.member
+
+		switch {
+		case res.err == nil:
+			b.accept(m, res.reply, time.Since(startTime))
+		case errors.Is(res.err, transport.ErrTimeout):
+			m.status = statusTimeout
+		case errors.Is(res.err, transport.ErrCancelled):
+			// Still pending: the wait was interrupted
+		default:
+			m.status = statusError
+			failed = res.err
+			b.out.Error(503, res.err.Error(), m.leg.entry.SessionID)
+		}
+	}
+	cancel()
+
+	settled := b.settled()
+	code := exitSuccess
+	decision := ""
+	switch {
+	case settled && b.approval:
+		d := b.decide()
+		decision = string(d)
+		code = approvalExitCode(d)
+	case settled:
+	case parent.Err() != nil:
+		code = cancelledExitCode(b.approval)
+	case failed != nil && !b.anyStatus(statusTimeout):
+		code = exitAPIError
+	default:
+		code = exitTimeout
+	}
+
+	var replies []output.RecipientReply
+	for _, m := range b.members {
+		if settled && m.status == statusPending {
+			m.status = statusSkipped
+		}
+		replies = append(replies, b.recipientReply(m))
+	}
+	if settled || b.anyStatus(statusReplied) {
+		b.out.Responses(b.require, b.needed, replies, decision)
+	}
+	if code == exitTimeout {
+		b.out.Timeout(b.firstSession(statusTimeout), b.timeout)
+	}
+
+	b.finish(code)
+	return code
+}
+
+// accept records a recipient's reply, matching it against the options or
+// reading it as an approval decision
+func (b *broadcast) accept(m *member, reply *transport.Reply, waitTime time.Duration) {
+	m.status = statusReplied
+	m.reply = reply
+	m.waitTime = waitTime
+
+	entry := m.leg.entry
+	if b.approval {
+		m.decision = approval.Parse(reply.Content)
+		entry.Decision = string(m.decision)
+	} else if len(b.options) > 0 {
+		if n := choice.Match(reply.Content, b.options); n > 0 {
+			entry.Choice = b.options[n-1]
+			entry.ChoiceIdx = n
+		}
+	}
+}
+
+// settled reports whether the policy is decided: enough replies, or for an
+// approval enough approvals, or too many other answers to reach them.
+// Recipients who have not answered in time leave it undecided.
+func (b *broadcast) settled() bool {
+	if !b.approval {
+		return b.count(statusReplied) >= b.needed
+	}
+	approvals, possible := 0, 0
+	for _, m := range b.members {
+		switch {
+		case m.status == statusReplied && m.decision == approval.Approved:
+			approvals++
+			possible++
+		case m.status == statusPending || m.status == statusTimeout:
+			possible++
+		}
+	}
+	return approvals >= b.needed || possible < b.needed
+}
+
+// decide combines the approval decisions once settled: approved with
+// enough approvals, otherwise denied if anyone denied, else unclear
+func (b *broadcast) decide() approval.Decision {
+	approvals, denials := 0, 0
+	for _, m := range b.members {
+		if m.status != statusReplied {
+			continue
+		}
+		switch m.decision {
+		case approval.Approved:
+			approvals++
+		case approval.Denied:
+			denials++
+		}
+	}
+	switch {
+	case approvals >= b.needed:
+		return approval.Approved
+	case denials > 0:
+		return approval.Denied
+	default:
+		return approval.Unclear
+	}
+}
+
+// finish records each recipient's outcome in history
+func (b *broadcast) finish(code int) {
+	for _, m := range b.members {
+		if m.leg == nil {
+			continue
+		}
+		switch m.status {
+		case statusReplied:
+			finishHistory(m.leg.entry, m.reply, code)
+		case statusSkipped:
+			m.leg.entry.Outcome = history.OutcomeSkipped
+			m.leg.entry.ExitCode = code
+			recordHistory(m.leg.entry)
+		case statusTimeout:
+			finishHistory(m.leg.entry, nil, exitTimeout)
+		case statusError:
+			finishHistory(m.leg.entry, nil, exitAPIError)
+		default:
+			finishHistory(m.leg.entry, nil, code)
+		}
+	}
+}
+
+func (b *broadcast) recipientReply(m *member) output.RecipientReply {
+	r := output.RecipientReply{
+		Recipient: m.name,
+		Status:    m.status,
+	}
+	if m.leg == nil {
+		return r
+	}
+	entry := m.leg.entry
+	r.SessionID = entry.SessionID
+	if m.reply != nil {
+		r.From = m.reply.From
+		r.Channel = m.reply.Channel
+		r.Content = m.reply.Content
+		r.WaitTime = m.waitTime
+		r.ChoiceIndex = entry.ChoiceIdx
+		r.Choice = entry.Choice
+		r.Decision = entry.Decision
+	}
+	return r
+}
+
+func (b *broadcast) count(status string) int {
+	n := 0
+	for _, m := range b.members {
+		if m.status == status {
+			n++
+		}
+	}
+	return n
+}
+
+func (b *broadcast) anyStatus(status string) bool {
+	return b.count(status) > 0
+}
+
+// firstSession returns the session of the first recipient with status
+func (b *broadcast) firstSession(status string) string {
+	for _, m := range b.members {
+		if m.status == status && m.leg != nil {
+			return m.leg.entry.SessionID
+		}
+	}
+	return ""
+}
+
+// resolveRecipients expands --to into recipient and contact names,
+// replacing group names with their members
+func resolveRecipients(cfg *config.Config, to string) ([]string, error) {
+	var names []string
+	seen := make(map[string]bool)
+	add := func(name string) error {
+		_, isRecipient := cfg.Recipients[name]
+		if _, isContact := cfg.Contact(name); !isRecipient && !isContact {
+			return fmt.Errorf("unknown recipient %q: add it with 'afk contacts add'", name)
+		}
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+		return nil
+	}
+
+	for _, name := range strings.Split(to, ",") {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		if group, ok := cfg.Group(name); ok {
+			for _, member := range group {
+				if err := add(member); err != nil {
+					return nil, fmt.Errorf("group %q: %w", name, err)
+				}
+			}
+			continue
+		}
+		if err := add(name); err != nil {
+			return nil, err
+		}
+	}
+	if len(names) == 0 {
+		return nil, errors.New("--to names no recipients")
+	}
+	return names, nil
+}
+
+// recipientConfig returns the config that reaches the named recipient on
+// channel: their "recipients" settings, or else their contact address.
+// Settings that leave the destination unset are rejected rather than
+// falling back to the default's.
+func recipientConfig(cfg *config.Config, channel, name string) (*config.Config, error) {
+	r, _ := transport.Lookup(channel)
+	if overrides, ok := cfg.Recipients[name]; ok {
+		recipientCfg, err := cfg.Override(overrides)
+		if err != nil {
+			return nil, fmt.Errorf("recipient %q: %w", name, err)
+		}
+		var own config.Config
+		if r.Destination != nil && json.Unmarshal(overrides, &own) == nil && r.Destination(&own) == "" {
+			return nil, fmt.Errorf("recipient %q: \"recipients\" settings don't say where to reach them on %s", name, channel)
+		}
+		return recipientCfg, nil
+	}
+
+	contact, _ := cfg.Contact(name)
+	recipientCfg, _ := cfg.Override(nil)
+	if r.SetRecipient != nil {
+		if err := r.SetRecipient(recipientCfg, contact); err != nil {
+			return nil, fmt.Errorf("contact %q: %w", name, err)
+		}
+	}
+	return recipientCfg, nil
+}
+
+// parseRequire converts --require (any, all or a count) into the number of
+// replies needed from recipients
+func parseRequire(value string, recipients int) (int, error) {
+	switch value {
+	case "any":
+		return 1, nil
+	case "all":
+		return recipients, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("invalid --require %q (use any, all or a number)", value)
+	}
+	if n > recipients {
+		return 0, fmt.Errorf("--require %d is more than the %d recipients", n, recipients)
+	}
+	return n, nil
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/davedotdev/afk/internal/config"
+)
+
+func TestNewBroadcastDestinations(t *testing.T) {
+	tests := []struct {
+		name       string
+		channel    string
+		recipients map[string]string
+		contacts   map[string]config.Contact
+		to         []string
+		wantErr    string
+	}{
+		{
+			name:       "own destinations",
+			channel:    "slack",
+			recipients: map[string]string{"alice": `{"slack_channel": "U0ALICE"}`},
+			contacts:   map[string]config.Contact{"bob": {Slack: "U0BOB"}},
+			to:         []string{"alice", "bob"},
+		},
+		{
+			name:       "recipient and contact reach one user",
+			channel:    "slack",
+			recipients: map[string]string{"alice": `{"slack_channel": "U0ALICE"}`},
+			contacts:   map[string]config.Contact{"alias": {Slack: "U0ALICE"}},
+			to:         []string{"alice", "alias"},
+			wantErr:    `recipients "alice" and "alias" reach the same slack destination`,
+		},
+		{
+			name:       "override without a destination",
+			channel:    "slack",
+			recipients: map[string]string{"alice": `{"sys_name": "Alice"}`},
+			contacts:   map[string]config.Contact{"bob": {Slack: "U0BOB"}},
+			to:         []string{"alice", "bob"},
+			wantErr:    `recipient "alice": "recipients" settings don't say where to reach them on slack`,
+		},
+		{
+			name:     "two chats on one Telegram bot",
+			channel:  "telegram",
+			contacts: map[string]config.Contact{"ops": {Telegram: "1001"}, "dba": {Telegram: "1002"}},
+			to:       []string{"ops", "dba"},
+			wantErr:  `recipients "ops" and "dba" use the same telegram bot`,
+		},
+		{
+			name:       "Telegram recipients with their own bots",
+			channel:    "telegram",
+			recipients: map[string]string{"ops": `{"telegram_bot_token": "1:ops", "telegram_chat_id": "1001"}`, "dba": `{"telegram_bot_token": "2:dba", "telegram_chat_id": "1002"}`},
+			to:         []string{"ops", "dba"},
+		},
+		{
+			name:     "one mailbox under two names",
+			channel:  "email",
+			contacts: map[string]config.Contact{"ops": {Email: "Ops <OPS@example.com>"}, "oncall": {Email: "ops@example.com"}},
+			to:       []string{"ops", "oncall"},
+			wantErr:  `recipients "ops" and "oncall" reach the same email destination`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{
+				SlackBotToken:    "xoxb-test",
+				SlackChannel:     "C0DEFAULT",
+				TelegramBotToken: "0:default",
+				TelegramChatID:   "1000",
+				EmailFrom:        "afk@example.com",
+				EmailTo:          "me@example.com",
+				EmailSMTPAddr:    "127.0.0.1:1",
+				EmailIMAPAddr:    "127.0.0.1:1",
+				Contacts:         tt.contacts,
+				Recipients:       make(map[string]json.RawMessage),
+			}
+			for name, overrides := range tt.recipients {
+				cfg.Recipients[name] = json.RawMessage(overrides)
+			}
+			template := &conversation{cfg: cfg, channel: tt.channel}
+
+			b, err := newBroadcast(template, tt.to, "all", len(tt.to))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("newBroadcast = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("newBroadcast: %v", err)
+			}
+			if len(b.members) != len(tt.to) {
+				t.Errorf("%d members, want %d", len(b.members), len(tt.to))
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/davedotdev/afk/internal/config"
+	"github.com/davedotdev/afk/internal/secret"
+)
+
+// chooseKeyStore asks where to keep the API key, offering the keyring only
+// when one is available. The config file ("") is the default.
+func chooseKeyStore(reader *bufio.Reader, w io.Writer) (string, error) {
+	choices := []struct{ backend, label string }{
+		{"", "config file (plaintext)"},
+	}
+	if secret.KeyringAvailable() {
+		choices = append(choices, struct{ backend, label string }{secret.Keyring, "system keyring (Secret Service)"})
+	}
+	choices = append(choices, struct{ backend, label string }{secret.File, "encrypted file (asks for a passphrase)"})
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Where should the API key be stored?")
+	for i, c := range choices {
+		fmt.Fprintf(w, "  %d) %s\n", i+1, c.label)
+	}
+	fmt.Fprint(w, "Choice [default: 1]: ")
+	answer, _ := reader.ReadString('\n')
+	answer = strings.TrimSpace(answer)
+	if answer == "" {
+		return "", nil
+	}
+	for i, c := range choices {
+		if answer == fmt.Sprint(i+1) || (c.backend != "" && answer == c.backend) {
+			return c.backend, nil
+		}
+	}
+	return "", fmt.Errorf("invalid choice %q", answer)
+}
+
+// storeAPIKey saves the key for profile in the backend's store and returns
+// the reference to keep in the config in its place
+func storeAPIKey(store secret.Store, backend, profile, apiKey string) (string, error) {
+	account := config.SecretAccount(profile)
+	if err := store.Set(account, apiKey); err != nil {
+		return "", fmt.Errorf("failed to store API key in the %s: %w", store.Description(), err)
+	}
+	return secret.Ref(backend, account), nil
+}
+
+// forgetAPIKey removes a stored key, warning rather than failing so logout
+// always completes
+func forgetAPIKey(ref string) {
+	if ref == "" {
+		return
+	}
+	backend, account, err := secret.ParseRef(ref)
+	if err == nil {
+		var store secret.Store
+		if store, err = config.SecretStore(backend); err == nil {
+			err = store.Delete(account)
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not remove the stored API key (%s): %v\n", ref, err)
+	}
+}
+
+// profileKeyRef returns the api_key_ref set in a profile itself
+func profileKeyRef(cfg *config.Config, profile string) string {
+	var stored struct {
+		APIKeyRef string `json:"api_key_ref"`
+	}
+	json.Unmarshal(cfg.Profiles[profile], &stored)
+	return stored.APIKeyRef
+}
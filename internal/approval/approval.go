@@ -0,0 +1,109 @@
+package approval
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Decision is the interpretation of a yes/no reply
+type Decision string
+
+const (
+	Approved Decision = "approved"
+	Denied   Decision = "denied"
+	Unclear  Decision = "unclear"
+)
+
+// Words and emoji recognised as a yes or a no. A negator counts as a no,
+// and also cancels a yes-word straight after it ("not ok", "do not proceed").
+var (
+	yesWords = map[string]bool{
+		"yes": true, "y": true, "yep": true, "yeah": true, "yup": true, "sure": true,
+		"ok": true, "okay": true, "approve": true, "approved": true, "go": true,
+		"lgtm": true, "ship": true, "proceed": true, "👍": true, "✅": true, "👌": true,
+	}
+	noWords = map[string]bool{
+		"no": true, "n": true, "nope": true, "nah": true, "deny": true, "denied": true,
+		"reject": true, "rejected": true, "stop": true, "don't": true, "dont": true,
+		"wait": true, "abort": true, "not": true, "never": true, "cancel": true,
+		"won't": true, "can't": true, "cannot": true, "shouldn't": true,
+		"👎": true, "❌": true, "🛑": true,
+	}
+	negators = map[string]bool{
+		"not": true, "no": true, "never": true, "don't": true, "dont": true,
+		"won't": true, "can't": true, "cannot": true, "shouldn't": true,
+		"cancel": true, "stop": true,
+	}
+)
+
+// Prompt appends the reply instructions to an approval request
+func Prompt(message string) string {
+	return message + "\n\nReply YES to approve or NO to deny."
+}
+
+// Parse interprets a reply. A reply counts as approved or denied only if
+// it contains words from one side and none from the other; anything else
+// ("no problem, go ahead", "maybe later") is unclear. A negated yes-word
+// counts for neither side, so "not approved" is denied by its negator.
+func Parse(reply string) Decision {
+	yes, no := false, false
+
+	previous := ""
+	for _, word := range tokens(reply) {
+		if yesWords[word] && !negators[previous] {
+			yes = true
+		}
+		if noWords[word] {
+			no = true
+		}
+		previous = word
+	}
+
+	switch {
+	case yes && !no:
+		return Approved
+	case no && !yes:
+		return Denied
+	default:
+		return Unclear
+	}
+}
+
+// tokens splits a reply into lowercase words, keeping apostrophes and emoji
+// (with skin tone and variation modifiers removed) as separate tokens
+func tokens(reply string) []string {
+	var words []string
+	var word []rune
+
+	flush := func() {
+		if len(word) > 0 {
+			words = append(words, string(word))
+			word = word[:0]
+		}
+	}
+
+	for _, r := range strings.ToLower(reply) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '\'' || r == '’':
+			if r == '’' {
+				r = '\''
+			}
+			word = append(word, r)
+		case isModifier(r):
+			// Skin tones and variation selectors attach to the previous emoji
+		case unicode.IsSymbol(r):
+			flush()
+			words = append(words, string(r))
+		default:
+			flush()
+		}
+	}
+	flush()
+
+	return words
+}
+
+// isModifier reports whether r is an emoji skin tone or variation selector
+func isModifier(r rune) bool {
+	return (r >= 0x1F3FB && r <= 0x1F3FF) || r == 0xFE0E || r == 0xFE0F
+}
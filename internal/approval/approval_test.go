@@ -0,0 +1,54 @@
+package approval
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		reply string
+		want  Decision
+	}{
+		// Plain answers
+		{"yes", Approved},
+		{"Y", Approved},
+		{"LGTM, ship it", Approved},
+		{"no", Denied},
+		{"Nope.", Denied},
+		{"abort", Denied},
+
+		// Negated yes-words
+		{"not approved", Denied},
+		{"do not proceed", Denied},
+		{"NOT OK", Denied},
+		{"don't approve", Denied},
+		{"Don’t go", Denied},
+		{"never", Denied},
+		{"absolutely not", Denied},
+		{"cancel", Denied},
+		{"stop, do not ship", Denied},
+
+		// Mixed yes and no
+		{"no problem, go ahead", Unclear},
+		{"yes but wait", Unclear},
+		{"ok, not yet", Unclear},
+		{"👍 👎", Unclear},
+
+		// Neither
+		{"", Unclear},
+		{"   ", Unclear},
+		{"maybe later", Unclear},
+		{"🤷", Unclear},
+
+		// Emoji only, with skin tone and variation modifiers
+		{"👍", Approved},
+		{"👍🏽", Approved},
+		{"✅️", Approved},
+		{"👎🏻", Denied},
+		{"❌", Denied},
+	}
+
+	for _, tt := range tests {
+		if got := Parse(tt.reply); got != tt.want {
+			t.Errorf("Parse(%q) = %s, want %s", tt.reply, got, tt.want)
+		}
+	}
+}
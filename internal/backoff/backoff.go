@@ -0,0 +1,42 @@
+// Package backoff computes the delay before reconnecting, shared by the
+// ChatBridge SSE listener and the transports' gateway and polling loops so
+// that every retry follows the same policy.
+package backoff
+
+import (
+	"math/rand"
+	"time"
+)
+
+// Delay bounds
+const (
+	Initial = time.Second
+	Max     = 30 * time.Second
+)
+
+// Delay returns the delay before reconnect attempt n (1-based). The base
+// delay is hint when given (an SSE retry: field or a Retry-After), doubled
+// per attempt and capped at Max, then jittered into the upper half of the
+// window. The result is never below hint, which the server asked for.
+func Delay(attempt int, hint time.Duration) time.Duration {
+	base := Initial
+	if hint > 0 {
+		base = hint
+	}
+
+	delay := base
+	for i := 1; i < attempt && delay < Max; i++ {
+		delay *= 2
+	}
+	if delay > Max {
+		delay = Max
+	}
+
+	if half := delay / 2; half > 0 {
+		delay = half + time.Duration(rand.Int63n(int64(half)))
+	}
+	if delay < hint {
+		delay = hint
+	}
+	return delay
+}
@@ -0,0 +1,34 @@
+package backoff
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDelay(t *testing.T) {
+	tests := []struct {
+		name     string
+		hint     time.Duration
+		min, max time.Duration
+	}{
+		{"no hint", 0, Initial / 2, Max},
+		{"short hint", 150 * time.Millisecond, 150 * time.Millisecond, Max},
+		{"hint above the cap", time.Minute, time.Minute, time.Minute},
+	}
+	for _, tt := range tests {
+		for attempt := 1; attempt <= 8; attempt++ {
+			for i := 0; i < 20; i++ {
+				if d := Delay(attempt, tt.hint); d < tt.min || d > tt.max {
+					t.Fatalf("%s: Delay(%d) = %v, want within [%v, %v]", tt.name, attempt, d, tt.min, tt.max)
+				}
+			}
+		}
+	}
+
+	if d := Delay(1, 0); d > Initial {
+		t.Errorf("first Delay = %v, want at most %v", d, Initial)
+	}
+	if d := Delay(20, 0); d < Max/2 {
+		t.Errorf("Delay after many attempts = %v, want near %v", d, Max)
+	}
+}
@@ -0,0 +1,100 @@
+package choice
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Render appends the numbered options and a reply hint to message
+func Render(message string, options []string) string {
+	var b strings.Builder
+	b.WriteString(message)
+	b.WriteString("\n\n")
+	writeOptions(&b, options)
+	return b.String()
+}
+
+// Reprompt builds the follow-up sent when a reply matched no option
+func Reprompt(reply string, options []string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Sorry, I couldn't match %q to one of the options.\n\n", summarize(reply))
+	writeOptions(&b, options)
+	return b.String()
+}
+
+func writeOptions(b *strings.Builder, options []string) {
+	for i, opt := range options {
+		fmt.Fprintf(b, "%d. %s\n", i+1, opt)
+	}
+	fmt.Fprintf(b, "\nReply with a number (1-%d) or the option name.", len(options))
+}
+
+// Match finds the option a reply refers to and returns its 1-based number,
+// or 0 if there is no unambiguous match. It accepts the option number
+// ("2", "#2", "option 2"), the label in any case, or a prefix that
+// identifies exactly one label ("red" for "Redis", "redis please").
+func Match(reply string, options []string) int {
+	text := normalize(reply)
+	if text == "" {
+		return 0
+	}
+
+	// By number
+	num := strings.TrimPrefix(strings.TrimPrefix(text, "option "), "#")
+	if n, err := strconv.Atoi(strings.TrimSpace(num)); err == nil {
+		if n >= 1 && n <= len(options) {
+			return n
+		}
+		return 0
+	}
+
+	// By exact label
+	for i, opt := range options {
+		if normalize(opt) == text {
+			return i + 1
+		}
+	}
+
+	// By prefix, in either direction, if only one label fits
+	match := 0
+	for i, opt := range options {
+		label := normalize(opt)
+		if label == "" {
+			continue
+		}
+		if strings.HasPrefix(label, text) || hasWordPrefix(text, label) {
+			if match != 0 {
+				return 0 // ambiguous
+			}
+			match = i + 1
+		}
+	}
+	return match
+}
+
+// hasWordPrefix reports whether text starts with the whole word(s) prefix
+func hasWordPrefix(text, prefix string) bool {
+	if !strings.HasPrefix(text, prefix) {
+		return false
+	}
+	rest := text[len(prefix):]
+	return rest == "" || rest[0] == ' '
+}
+
+// normalize lowercases, collapses whitespace and trims surrounding punctuation
+func normalize(s string) string {
+	s = strings.ToLower(strings.Join(strings.Fields(s), " "))
+	return strings.TrimFunc(s, func(r rune) bool {
+		return unicode.IsPunct(r) && r != '#'
+	})
+}
+
+func summarize(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if r := []rune(s); len(r) > 40 {
+		return string(r[:39]) + "…"
+	}
+	return s
+}
@@ -5,11 +5,13 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 )
 
 const (
 	configDir  = ".afk"
 	configFile = "config.json"
+	secretFile = "secrets.enc"
 )
 
 // OutputFormat defines the output style
@@ -24,76 +26,362 @@ const (
 // Config holds the stored credentials and settings
 type Config struct {
 	APIKey           string       `json:"api_key"`
+	APIKeyRef        string       `json:"api_key_ref,omitempty"` // Where the API key is stored instead, e.g. "keyring:default"
 	APIURL           string       `json:"api_url"`
 	SysName          string       `json:"sys_name,omitempty"`          // Name of the AI agent/system (e.g., "Claude Code")
 	ReminderInterval string       `json:"reminder_interval,omitempty"` // e.g., "15m", "0" to disable
 	Format           OutputFormat `json:"format,omitempty"`            // llm, human, json
+	Channel          string       `json:"channel,omitempty"`           // Channel used when no channel flag is given, e.g. "slack"
+	Timeout          string       `json:"timeout,omitempty"`           // How long to wait for a reply, e.g. "30m"
+	MessagePrefix    string       `json:"message_prefix,omitempty"`    // Prepended to every message, e.g. "[payments-svc]"
+
+	// Named profiles (optional): settings applied over the rest of the
+	// config when selected with --profile or AFK_PROFILE, e.g.
+	// {"dev": {"api_key": "cb_test_...", "api_url": "https://dev.chatbridge.net"}}
+	Profiles map[string]json.RawMessage `json:"profiles,omitempty"`
+	Profile  string                     `json:"-"` // Name of the profile applied by Load, if any
+
+	ProjectFile string `json:"-"` // Project config (.afk.json) applied by Load, if any
+
+	sources map[string]string // Where each setting loaded came from, by JSON key
+
+	// Slack channel (optional)
+	SlackBotToken string `json:"slack_bot_token,omitempty"` // xoxb- bot token used to post and read replies
+	SlackAppToken string `json:"slack_app_token,omitempty"` // xapp- app-level token; enables Socket Mode
+	SlackChannel  string `json:"slack_channel,omitempty"`   // User (U...) or channel (C...) ID to message
+	SlackAPIURL   string `json:"slack_api_url,omitempty"`   // Slack Web API base URL (for testing)
+
+	// Telegram channel (optional)
+	TelegramBotToken string `json:"telegram_bot_token,omitempty"` // Token from @BotFather
+	TelegramChatID   string `json:"telegram_chat_id,omitempty"`   // Chat to message (your user ID for a private chat)
+	TelegramAPIURL   string `json:"telegram_api_url,omitempty"`   // Bot API base URL (for testing)
+
+	// Email channel (optional)
+	EmailFrom     string `json:"email_from,omitempty"`      // Sender address
+	EmailTo       string `json:"email_to,omitempty"`        // Recipient address
+	EmailSMTPAddr string `json:"email_smtp_addr,omitempty"` // host:port; 465 uses TLS, otherwise STARTTLS
+	EmailIMAPAddr string `json:"email_imap_addr,omitempty"` // host:port; 993 uses TLS, otherwise STARTTLS
+	EmailUsername string `json:"email_username,omitempty"`  // SMTP/IMAP login (default: email_from)
+	EmailPassword string `json:"email_password,omitempty"`  // SMTP/IMAP password
+	EmailMailbox  string `json:"email_mailbox,omitempty"`   // IMAP mailbox to watch (default: INBOX)
+	EmailInsecure bool   `json:"email_insecure,omitempty"`  // Allow unencrypted connections (local testing only)
+
+	// Discord channel (optional)
+	DiscordBotToken string `json:"discord_bot_token,omitempty"` // Bot token from the Developer Portal
+	DiscordUserID   string `json:"discord_user_id,omitempty"`   // User to direct message
+	DiscordAPIURL   string `json:"discord_api_url,omitempty"`   // REST API base URL (for testing)
+
+	// Matrix channel (optional)
+	MatrixHomeserver      string `json:"matrix_homeserver,omitempty"`       // Homeserver URL, e.g. https://matrix.example.org
+	MatrixAccessToken     string `json:"matrix_access_token,omitempty"`     // Access token of the bot account
+	MatrixRoomID          string `json:"matrix_room_id,omitempty"`          // Room to post in, e.g. !abc:example.org
+	MatrixRequireRelation bool   `json:"matrix_require_relation,omitempty"` // Only accept threaded replies or replies to our message
+
+	// ntfy channel (optional)
+	NtfyServer     string `json:"ntfy_server,omitempty"`      // Server URL (default: https://ntfy.sh)
+	NtfyTopic      string `json:"ntfy_topic,omitempty"`       // Topic the phone subscribes to
+	NtfyReplyTopic string `json:"ntfy_reply_topic,omitempty"` // Prefix of the per-session answer topics (default: <ntfy_topic>-reply)
+	NtfyToken      string `json:"ntfy_token,omitempty"`       // Access token for protected topics
+
+	// Webhook channel (optional; the endpoint comes from --webhook <url>)
+	WebhookURL      string `json:"-"`
+	WebhookSecret   string `json:"webhook_secret,omitempty"`    // Shared HMAC-SHA256 secret
+	WebhookListen   string `json:"webhook_listen,omitempty"`    // Reply listener address (default: a free 127.0.0.1 port per session)
+	WebhookReplyURL string `json:"webhook_reply_url,omitempty"` // Public URL of the reply listener, if proxied
+	WebhookPollURL  string `json:"webhook_poll_url,omitempty"`  // Poll this URL for the reply instead of listening
+
+	// Local channel (optional)
+	LocalNoNotify bool `json:"local_no_notify,omitempty"` // Don't raise a desktop notification
+
+	// Escalation chain used with --escalate (optional)
+	Escalation []EscalationStep `json:"escalation,omitempty"`
+
+	// Recipients for --to (optional): settings overridden to reach each
+	// person, e.g. {"bob": {"slack_channel": "U456"}}, and named groups of them
+	Recipients map[string]json.RawMessage `json:"recipients,omitempty"`
+	Groups     map[string][]string        `json:"groups,omitempty"`
+
+	// Contact book for --to (optional), and a contacts file shared by the
+	// team whose entries apply where the config has none of its own
+	Contacts     map[string]Contact `json:"contacts,omitempty"`
+	ContactsFile string             `json:"contacts_file,omitempty"`
+
+	shared *ContactBook // Loaded from ContactsFile
 }
 
+// EscalationStep is a later hop of an escalation chain. It is sent when no
+// reply has arrived After the first message, and waited on alongside it.
+type EscalationStep struct {
+	Channel string          `json:"channel"`          // Registered channel name, e.g. "sms"
+	After   string          `json:"after"`            // Time since the first message, e.g. "10m"
+	Arg     string          `json:"arg,omitempty"`    // Value for channels that take one, e.g. the --webhook URL
+	Config  json.RawMessage `json:"config,omitempty"` // Settings overridden for this hop, e.g. a secondary contact's "email_to"
+}
+
+// ProfileEnv is the environment variable that selects a profile
+const ProfileEnv = "AFK_PROFILE"
+
 // DefaultAPIURL is the default ChatBridge API endpoint
 const DefaultAPIURL = "https://chatbridge.net"
 
 // DevAPIURL is the development API endpoint
 const DevAPIURL = "https://dev.chatbridge.net"
 
-// configPath returns the full path to the config file
-func configPath() (string, error) {
+// DefaultSlackAPIURL is the Slack Web API endpoint
+const DefaultSlackAPIURL = "https://slack.com/api"
+
+// DefaultTelegramAPIURL is the Telegram Bot API endpoint
+const DefaultTelegramAPIURL = "https://api.telegram.org"
+
+// DefaultDiscordAPIURL is the Discord REST API endpoint
+const DefaultDiscordAPIURL = "https://discord.com/api/v10"
+
+// DefaultNtfyServer is the public ntfy server
+const DefaultNtfyServer = "https://ntfy.sh"
+
+// DefaultWebhookListen is where the webhook reply listener binds: a free
+// port for each session, so several webhook questions can wait at once
+const DefaultWebhookListen = "127.0.0.1:0"
+
+// Dir returns the afk state directory (~/.afk)
+func Dir() (string, error) {
 	home, err := os.UserHomeDir()
 	if err != nil {
 		return "", fmt.Errorf("failed to get home directory: %w", err)
 	}
-	return filepath.Join(home, configDir, configFile), nil
+	return filepath.Join(home, configDir), nil
 }
 
-// Load reads the config from ~/.afk/config.json
+// configPath returns the full path to the config file, which $AFK_CONFIG
+// overrides
+func configPath() (string, error) {
+	if path := os.Getenv(ConfigEnv); path != "" {
+		return path, nil
+	}
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, configFile), nil
+}
+
+// Load reads the config from ~/.afk/config.json with the profile named by
+// $AFK_PROFILE applied
 func Load() (*Config, error) {
+	return LoadProfile(os.Getenv(ProfileEnv))
+}
+
+// LoadProfile reads the config with the named profile applied over it. An
+// empty name loads the config without a profile. The AFK_* environment
+// variables override the file, which may be missing when they supply the
+// credentials.
+func LoadProfile(profile string) (*Config, error) {
+	return load(profile, true)
+}
+
+// LoadSettings reads the config like LoadProfile, for commands that need
+// no credentials: a missing API key is not an error, and a key kept in the
+// keyring or encrypted file is not fetched.
+func LoadSettings(profile string) (*Config, error) {
+	return load(profile, false)
+}
+
+func load(profile string, credentials bool) (*Config, error) {
 	path, err := configPath()
 	if err != nil {
 		return nil, err
 	}
 
+	var cfg Config
 	data, err := os.ReadFile(path)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return nil, fmt.Errorf("not logged in: run 'afk login' first")
-		}
+	switch {
+	case os.IsNotExist(err):
+		data = nil
+	case err != nil:
 		return nil, fmt.Errorf("failed to read config: %w", err)
+	default:
+		if err := json.Unmarshal(data, &cfg); err != nil {
+			return nil, fmt.Errorf("failed to parse config: %w", err)
+		}
+		cfg.recordSources(data, path)
 	}
 
-	var cfg Config
-	if err := json.Unmarshal(data, &cfg); err != nil {
-		return nil, fmt.Errorf("failed to parse config: %w", err)
+	if profile != "" {
+		overrides, ok := cfg.Profiles[profile]
+		if !ok {
+			return nil, fmt.Errorf("unknown profile %q (see 'afk status' for profiles)", profile)
+		}
+		if err := json.Unmarshal(overrides, &cfg); err != nil {
+			return nil, fmt.Errorf("failed to parse profile %q: %w", profile, err)
+		}
+		cfg.recordSources(overrides, fmt.Sprintf("%s, profile %s", path, profile))
+		cfg.Profile = profile
 	}
 
-	if cfg.APIKey == "" {
+	if err := cfg.applyProject(); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.applyEnv(); err != nil {
+		return nil, err
+	}
+
+	// Fetch a key kept in the keyring or encrypted file, unless the
+	// environment supplied one
+	if credentials && cfg.APIKey == "" && cfg.APIKeyRef != "" {
+		if err := cfg.resolveAPIKey(); err != nil {
+			return nil, err
+		}
+	}
+
+	if path := cfg.ContactsPath(); path != "" {
+		if cfg.shared, err = LoadContactBook(path); err != nil {
+			return nil, err
+		}
+	}
+
+	// A config may hold only other channels' credentials; ChatBridge
+	// checks for its API key when it is used
+	if credentials && cfg.APIKey == "" && !cfg.hasChannel() {
+		if data == nil {
+			return nil, fmt.Errorf("not logged in: run 'afk login' first or set AFK_API_KEY")
+		}
 		return nil, fmt.Errorf("invalid config: missing API key")
 	}
 
 	// Default API URL if not set
-	if cfg.APIURL == "" {
-		cfg.APIURL = DefaultAPIURL
-	}
+	cfg.setDefault(&cfg.APIURL, "api_url", DefaultAPIURL)
 
 	// Default sys_name
-	if cfg.SysName == "" {
-		cfg.SysName = "AI Agent"
-	}
+	cfg.setDefault(&cfg.SysName, "sys_name", "AI Agent")
 
 	// Default reminder interval (15 minutes)
-	if cfg.ReminderInterval == "" {
-		cfg.ReminderInterval = "15m"
-	}
+	cfg.setDefault(&cfg.ReminderInterval, "reminder_interval", "15m")
+
+	// Default timeout (1 hour)
+	cfg.setDefault(&cfg.Timeout, "timeout", "1h")
 
 	// Default format (LLM)
-	if cfg.Format == "" {
-		cfg.Format = FormatLLM
+	format := string(cfg.Format)
+	cfg.setDefault(&format, "format", string(FormatLLM))
+	cfg.Format = OutputFormat(format)
+
+	cfg.setDefault(&cfg.SlackAPIURL, "slack_api_url", DefaultSlackAPIURL)
+	cfg.setDefault(&cfg.TelegramAPIURL, "telegram_api_url", DefaultTelegramAPIURL)
+	cfg.setDefault(&cfg.DiscordAPIURL, "discord_api_url", DefaultDiscordAPIURL)
+	cfg.setDefault(&cfg.NtfyServer, "ntfy_server", DefaultNtfyServer)
+	cfg.setDefault(&cfg.WebhookListen, "webhook_listen", DefaultWebhookListen)
+
+	return &cfg, nil
+}
+
+// Override returns a copy of the config with the JSON object overrides
+// applied on top, e.g. {"email_to": "oncall@example.com"}
+func (c *Config) Override(overrides json.RawMessage) (*Config, error) {
+	cfg := *c
+	if len(overrides) == 0 {
+		return &cfg, nil
+	}
+	if err := json.Unmarshal(overrides, &cfg); err != nil {
+		return nil, fmt.Errorf("invalid config overrides: %w", err)
+	}
+	return &cfg, nil
+}
+
+// ProfileNames returns the names of the configured profiles, sorted
+func (c *Config) ProfileNames() []string {
+	names := make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SetProfileValues stores values in the named profile, keeping its other
+// settings, and creates the profile if needed. A nil value removes the key.
+func (c *Config) SetProfileValues(profile string, values map[string]interface{}) error {
+	fields := make(map[string]json.RawMessage)
+	if overrides := c.Profiles[profile]; len(overrides) > 0 {
+		if err := json.Unmarshal(overrides, &fields); err != nil {
+			return fmt.Errorf("failed to parse profile %q: %w", profile, err)
+		}
+	}
+	for key, value := range values {
+		if value == nil {
+			delete(fields, key)
+			continue
+		}
+		data, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Errorf("failed to marshal %s: %w", key, err)
+		}
+		fields[key] = data
+	}
+
+	overrides, err := json.Marshal(fields)
+	if err != nil {
+		return fmt.Errorf("failed to marshal profile %q: %w", profile, err)
+	}
+	if c.Profiles == nil {
+		c.Profiles = make(map[string]json.RawMessage)
+	}
+	c.Profiles[profile] = overrides
+	return nil
+}
+
+// hasChannel reports whether credentials for any non-ChatBridge channel are
+// set, or the default channel is local, which needs none
+func (c *Config) hasChannel() bool {
+	return c.SlackBotToken != "" || c.TelegramBotToken != "" || c.EmailSMTPAddr != "" ||
+		c.DiscordBotToken != "" || c.MatrixAccessToken != "" || c.NtfyTopic != "" ||
+		c.WebhookSecret != "" || c.Channel == "local"
+}
+
+// Read returns the config as stored, without defaults or validation, for
+// commands that edit it. A missing file is an empty config.
+func Read() (*Config, error) {
+	path, err := configPath()
+	if err != nil {
+		return nil, err
 	}
 
+	var cfg Config
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &cfg, nil
+		}
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config: %w", err)
+	}
 	return &cfg, nil
 }
 
 // Save writes the config to ~/.afk/config.json
 func Save(cfg *Config) error {
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal config: %w", err)
+	}
+	return write(data)
+}
+
+// SaveData writes config JSON as given, such as after 'afk config edit',
+// once it parses as a config
+func SaveData(data []byte) error {
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return fmt.Errorf("failed to parse config: %w", err)
+	}
+	return write(data)
+}
+
+// write replaces the config file atomically, through a temporary file in
+// the same directory, so an interrupted write never leaves it truncated
+func write(data []byte) error {
 	path, err := configPath()
 	if err != nil {
 		return err
@@ -105,13 +393,26 @@ func Save(cfg *Config) error {
 		return fmt.Errorf("failed to create config directory: %w", err)
 	}
 
-	data, err := json.MarshalIndent(cfg, "", "  ")
+	// Temporary files are created with restricted permissions (user
+	// read/write only), which the rename keeps
+	tmp, err := os.CreateTemp(dir, ".config-*.json")
 	if err != nil {
-		return fmt.Errorf("failed to marshal config: %w", err)
+		return fmt.Errorf("failed to write config: %w", err)
 	}
+	defer os.Remove(tmp.Name()) // No-op once renamed
 
-	// Write with restricted permissions (user read/write only)
-	if err := os.WriteFile(path, data, 0600); err != nil {
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
 		return fmt.Errorf("failed to write config: %w", err)
 	}
 
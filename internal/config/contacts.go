@@ -0,0 +1,108 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Contact is a person afk can message by name with --to. Each field is
+// their address on one channel.
+type Contact struct {
+	Description string `json:"description,omitempty"` // Who they are, e.g. "DBA on call"
+	Phone       string `json:"phone,omitempty"`       // For reference; ChatBridge texts the account's own phone
+	Slack       string `json:"slack,omitempty"`       // Slack user ID (U...)
+	Email       string `json:"email,omitempty"`
+	Telegram    string `json:"telegram,omitempty"` // Telegram chat ID
+	Discord     string `json:"discord,omitempty"`  // Discord user ID
+	Matrix      string `json:"matrix,omitempty"`   // Matrix room ID
+	Ntfy        string `json:"ntfy,omitempty"`     // ntfy topic
+}
+
+// ContactBook is a contacts file a team can share, named by contacts_file
+type ContactBook struct {
+	Contacts map[string]Contact  `json:"contacts,omitempty"`
+	Groups   map[string][]string `json:"groups,omitempty"`
+}
+
+// Contact returns the named contact, looking in the config before the
+// shared contacts file
+func (c *Config) Contact(name string) (Contact, bool) {
+	if ct, ok := c.Contacts[name]; ok {
+		return ct, true
+	}
+	if c.shared != nil {
+		ct, ok := c.shared.Contacts[name]
+		return ct, ok
+	}
+	return Contact{}, false
+}
+
+// Group returns the members of the named group, combining its entries in
+// the config and the shared contacts file
+func (c *Config) Group(name string) ([]string, bool) {
+	members, ok := c.Groups[name]
+	if c.shared != nil {
+		if shared, found := c.shared.Groups[name]; found {
+			members = append(append([]string(nil), members...), shared...)
+			ok = true
+		}
+	}
+	return members, ok
+}
+
+// ContactsPath returns the shared contacts file path, or "" when none is
+// set. Relative paths are resolved against ~/.afk.
+func (c *Config) ContactsPath() string {
+	path := c.ContactsFile
+	if path == "" {
+		return ""
+	}
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, rest)
+		}
+	}
+	if !filepath.IsAbs(path) {
+		if dir, err := Dir(); err == nil {
+			return filepath.Join(dir, path)
+		}
+	}
+	return path
+}
+
+// LoadContactBook reads a contacts file. A missing file is an empty book.
+func LoadContactBook(path string) (*ContactBook, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &ContactBook{}, nil
+		}
+		return nil, fmt.Errorf("failed to read contacts: %w", err)
+	}
+
+	var book ContactBook
+	if err := json.Unmarshal(data, &book); err != nil {
+		return nil, fmt.Errorf("failed to parse contacts %s: %w", path, err)
+	}
+	return &book, nil
+}
+
+// SaveContactBook writes a contacts file. It holds no secrets, so it is
+// readable by the team sharing it.
+func SaveContactBook(path string, book *ContactBook) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create contacts directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(book, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal contacts: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write contacts: %w", err)
+	}
+	return nil
+}
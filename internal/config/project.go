@@ -0,0 +1,90 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ProjectFile is the name of the per-repository config, found by walking up
+// from the working directory
+const ProjectFile = ".afk.json"
+
+// FindProject returns the path of the nearest .afk.json in the working
+// directory or its parents, or "" when there is none
+func FindProject() string {
+	dir, err := os.Getwd()
+	if err != nil {
+		return ""
+	}
+	for {
+		path := filepath.Join(dir, ProjectFile)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// projectKeys are the only settings a project config may hold. The file is
+// meant to be committed, so anything that names an endpoint, a credential
+// or who gets asked stays in the user's own config.
+var projectKeys = []string{"sys_name", "channel", "timeout", "message_prefix", "format", "reminder_interval"}
+
+// applyProject merges the nearest .afk.json over the config
+func (c *Config) applyProject() error {
+	path := FindProject()
+	if path == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read project config: %w", err)
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if keys := disallowedKeys(fields); len(keys) > 0 {
+		return fmt.Errorf("%s: %w; set it in %s or the environment instead", path, projectKeyError(keys[0]), Path())
+	}
+
+	if err := json.Unmarshal(data, c); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	c.recordSources(data, path)
+	c.ProjectFile = path
+	return nil
+}
+
+// disallowedKeys returns the keys in fields that a project config may not
+// hold, sorted
+func disallowedKeys(fields map[string]json.RawMessage) []string {
+	var keys []string
+	for key := range fields {
+		allowed := false
+		for _, k := range projectKeys {
+			if key == k {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func projectKeyError(key string) error {
+	return fmt.Errorf("%q is not allowed in a project config, which may be committed (only %s)", key, strings.Join(projectKeys, ", "))
+}
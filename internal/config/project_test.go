@@ -0,0 +1,56 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateProject(t *testing.T) {
+	channels := []string{"slack", "local"}
+	tests := []struct {
+		name    string
+		data    string
+		wantErr []string
+	}{
+		{
+			name: "allowed settings",
+			data: `{"sys_name": "CI", "channel": "slack", "timeout": "30m", "message_prefix": "[svc]", "format": "json", "reminder_interval": "5m"}`,
+		},
+		{
+			name:    "endpoints and routing",
+			data:    `{"sys_name": "CI", "slack_api_url": "https://slack.example", "webhook_poll_url": "https://poll.example", "contacts_file": "team.json"}`,
+			wantErr: []string{`"contacts_file" is not allowed`, `"slack_api_url" is not allowed`, `"webhook_poll_url" is not allowed`},
+		},
+		{
+			name:    "credential ref",
+			data:    `{"api_key_ref": "keyring:default"}`,
+			wantErr: []string{`"api_key_ref" is not allowed`},
+		},
+		{
+			name:    "invalid allowed setting",
+			data:    `{"timeout": "soon"}`,
+			wantErr: []string{"timeout must be a duration"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), ProjectFile)
+			if err := os.WriteFile(path, []byte(tt.data), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			errs := ValidateProject(path, channels)
+			if len(errs) != len(tt.wantErr) {
+				t.Fatalf("ValidateProject = %v, want %d errors", errs, len(tt.wantErr))
+			}
+			for i, want := range tt.wantErr {
+				if !strings.Contains(errs[i].Error(), want) {
+					t.Errorf("error %d = %v, want %q", i, errs[i], want)
+				}
+			}
+		})
+	}
+}
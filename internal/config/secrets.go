@@ -0,0 +1,82 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/davedotdev/afk/internal/secret"
+)
+
+// PlaintextStorage describes an API key kept in the config file itself
+const PlaintextStorage = "config file (plaintext)"
+
+// SecretStore returns the store for a secret backend: the system keyring or
+// the encrypted file ~/.afk/secrets.enc
+func SecretStore(backend string) (secret.Store, error) {
+	switch backend {
+	case secret.Keyring:
+		if !secret.KeyringAvailable() {
+			return nil, errors.New("no system keyring: secret-tool (libsecret) and a D-Bus session are needed")
+		}
+		return secret.NewKeyring(), nil
+	case secret.File:
+		dir, err := Dir()
+		if err != nil {
+			return nil, err
+		}
+		return secret.NewFile(filepath.Join(dir, secretFile)), nil
+	}
+	return nil, fmt.Errorf("unknown secret backend %q (use keyring or file)", backend)
+}
+
+// SecretAccount returns the name a profile's API key is stored under
+func SecretAccount(profile string) string {
+	if profile == "" {
+		return "default"
+	}
+	return "profile:" + profile
+}
+
+// KeyStorage describes where the API key in use came from, for afk status
+func (c *Config) KeyStorage() string {
+	source := c.sources["api_key"]
+	switch {
+	case c.APIKey == "":
+		return "none"
+	case strings.HasPrefix(source, "$"):
+		return "environment (" + source + ")"
+	case c.APIKeyRef != "" && source == c.APIKeyRef:
+		if backend, _, err := secret.ParseRef(c.APIKeyRef); err == nil {
+			if store, err := SecretStore(backend); err == nil {
+				return store.Description()
+			}
+		}
+		return c.APIKeyRef
+	default:
+		return PlaintextStorage
+	}
+}
+
+// resolveAPIKey reads the API key named by api_key_ref from its store
+func (c *Config) resolveAPIKey() error {
+	backend, account, err := secret.ParseRef(c.APIKeyRef)
+	if err != nil {
+		return err
+	}
+	store, err := SecretStore(backend)
+	if err != nil {
+		return fmt.Errorf("api_key_ref %s: %w", c.APIKeyRef, err)
+	}
+	key, err := store.Get(account)
+	if err != nil {
+		if errors.Is(err, secret.ErrNotFound) {
+			return fmt.Errorf("no API key for %q in the %s: run 'afk login' again", account, store.Description())
+		}
+		return fmt.Errorf("failed to read API key from the %s: %w", store.Description(), err)
+	}
+	c.APIKey = key
+	c.setSource("api_key", c.APIKeyRef)
+	return nil
+}
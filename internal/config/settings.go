@@ -0,0 +1,209 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// ConfigEnv is the environment variable that overrides the config file path
+const ConfigEnv = "AFK_CONFIG"
+
+// SourceDefault is the source of a setting no file, variable or flag set
+const SourceDefault = "default"
+
+// envOverrides lists the settings that can be overridden from the
+// environment, in the order they are applied
+var envOverrides = []struct {
+	key string
+	env string
+}{
+	{"api_key", "AFK_API_KEY"},
+	{"api_url", "AFK_API_URL"},
+	{"sys_name", "AFK_SYS_NAME"},
+	{"format", "AFK_FORMAT"},
+	{"reminder_interval", "AFK_REMINDER"},
+}
+
+// Setting is one config value as reported by afk config show
+type Setting struct {
+	Key    string `json:"key"`
+	Value  string `json:"value"`
+	Source string `json:"source"` // File, "$VAR", "--flag" or "default"; empty when unset
+}
+
+// Set stores value in the setting with the given JSON key, converting it to
+// the field's type, and records where it came from
+func (c *Config) Set(key, value, source string) error {
+	field, ok := c.field(key)
+	if !ok {
+		return fmt.Errorf("unknown setting %q", key)
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%s must be true or false, not %q", key, value)
+		}
+		field.SetBool(b)
+	case reflect.Int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%s must be a number, not %q", key, value)
+		}
+		field.SetInt(int64(n))
+	default:
+		return fmt.Errorf("%s can't be set from a single value; use 'afk config edit'", key)
+	}
+
+	c.setSource(key, source)
+	return nil
+}
+
+// Value returns the setting with the given JSON key, of any type
+func (c *Config) Value(key string) (interface{}, bool) {
+	field, ok := c.field(key)
+	if !ok {
+		return nil, false
+	}
+	return field.Interface(), true
+}
+
+// Unset clears the setting with the given JSON key, so the default applies
+func (c *Config) Unset(key string) error {
+	field, ok := c.field(key)
+	if !ok {
+		return fmt.Errorf("unknown setting %q", key)
+	}
+	field.Set(reflect.Zero(field.Type()))
+	delete(c.sources, key)
+	return nil
+}
+
+// Settings returns every single-valued setting in the order they appear in
+// the config, with secrets masked
+func (c *Config) Settings() []Setting {
+	var settings []Setting
+	v := reflect.ValueOf(c).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		key, ok := jsonKey(v.Type().Field(i))
+		if !ok {
+			continue
+		}
+
+		var value string
+		switch field := v.Field(i); field.Kind() {
+		case reflect.String:
+			value = field.String()
+		case reflect.Bool:
+			if field.Bool() {
+				value = "true"
+			}
+		case reflect.Int:
+			if field.Int() != 0 {
+				value = strconv.FormatInt(field.Int(), 10)
+			}
+		default:
+			continue
+		}
+		source := c.sources[key]
+		if value == "" {
+			source = "" // e.g. "api_key": "" with only other channels set up
+		}
+		if IsSecret(key) {
+			value = MaskSecret(value)
+		}
+
+		settings = append(settings, Setting{Key: key, Value: value, Source: source})
+	}
+	return settings
+}
+
+// IsSecret reports whether the setting with the given JSON key holds a
+// credential
+func IsSecret(key string) bool {
+	return key == "api_key" || strings.HasSuffix(key, "_token") ||
+		strings.HasSuffix(key, "_password") || strings.HasSuffix(key, "_secret")
+}
+
+// MaskSecret shortens a credential so it can be recognised but not used
+func MaskSecret(value string) string {
+	switch {
+	case value == "":
+		return ""
+	case len(value) > 12:
+		return value[:8] + "..."
+	default:
+		return "****"
+	}
+}
+
+// applyEnv overrides settings from the AFK_* environment variables
+func (c *Config) applyEnv() error {
+	for _, o := range envOverrides {
+		value, ok := os.LookupEnv(o.env)
+		if !ok || value == "" {
+			continue
+		}
+		if err := c.Set(o.key, value, "$"+o.env); err != nil {
+			return fmt.Errorf("$%s: %w", o.env, err)
+		}
+	}
+	return nil
+}
+
+// recordSources notes source as the origin of each key in the JSON object
+// data, which has already been decoded into the config
+func (c *Config) recordSources(data []byte, source string) {
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(data, &fields) != nil {
+		return
+	}
+	for key := range fields {
+		c.setSource(key, source)
+	}
+}
+
+// setDefault sets a string setting that is still empty to value
+func (c *Config) setDefault(dst *string, key, value string) {
+	if *dst == "" {
+		*dst = value
+		c.setSource(key, SourceDefault)
+	}
+}
+
+func (c *Config) setSource(key, source string) {
+	if c.sources == nil {
+		c.sources = make(map[string]string)
+	}
+	c.sources[key] = source
+}
+
+// field returns the settable struct field with the given JSON key
+func (c *Config) field(key string) (reflect.Value, bool) {
+	v := reflect.ValueOf(c).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		if name, ok := jsonKey(v.Type().Field(i)); ok && name == key {
+			return v.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+// jsonKey returns the JSON key of an exported, serialised struct field
+func jsonKey(f reflect.StructField) (string, bool) {
+	if !f.IsExported() {
+		return "", false
+	}
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	if name == "" || name == "-" {
+		return "", false
+	}
+	return name, true
+}
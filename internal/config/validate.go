@@ -0,0 +1,142 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/davedotdev/afk/internal/secret"
+)
+
+// CheckSetting reports whether value is valid for the setting with the
+// given JSON key: of the right type, and a duration, format, URL or address
+// where the setting is one
+func CheckSetting(key, value string) error {
+	var scratch Config
+	if err := scratch.Set(key, value, ""); err != nil {
+		return err
+	}
+	return scratch.checkKey(key)
+}
+
+// Validate checks every setting in the config and its profiles, returning
+// all the problems found. channels lists the channel names known to afk,
+// for the default channel and escalation steps.
+func (c *Config) Validate(channels []string) []error {
+	var errs []error
+	for _, s := range c.Settings() {
+		if s.Value == "" {
+			continue
+		}
+		if err := c.checkKey(s.Key); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	known := func(channel string) bool {
+		for _, name := range channels {
+			if name == channel {
+				return true
+			}
+		}
+		return false
+	}
+	if c.Channel != "" && !known(c.Channel) {
+		errs = append(errs, fmt.Errorf("channel: unknown channel %q (use %s)", c.Channel, strings.Join(channels, ", ")))
+	}
+	for i, step := range c.Escalation {
+		if !known(step.Channel) {
+			errs = append(errs, fmt.Errorf("escalation step %d: unknown channel %q", i+1, step.Channel))
+		}
+		if d, err := time.ParseDuration(step.After); err != nil || d <= 0 {
+			errs = append(errs, fmt.Errorf("escalation step %d: invalid after %q (use a duration like 10m)", i+1, step.After))
+		}
+	}
+
+	for _, name := range c.ProfileNames() {
+		var profile Config
+		if err := json.Unmarshal(c.Profiles[name], &profile); err != nil {
+			errs = append(errs, fmt.Errorf("profile %s: %w", name, err))
+			continue
+		}
+		for _, err := range profile.Validate(channels) {
+			errs = append(errs, fmt.Errorf("profile %s: %w", name, err))
+		}
+	}
+	return errs
+}
+
+// ValidateProject checks a project .afk.json: that it parses, holds only
+// the settings a project may set, and that those are valid
+func ValidateProject(path string, channels []string) []error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return []error{fmt.Errorf("failed to read project config: %w", err)}
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return []error{fmt.Errorf("failed to parse: %w", err)}
+	}
+	var errs []error
+	for _, key := range disallowedKeys(fields) {
+		errs = append(errs, projectKeyError(key))
+	}
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return append(errs, fmt.Errorf("failed to parse: %w", err))
+	}
+	return append(errs, cfg.Validate(channels)...)
+}
+
+// checkKey validates the value of one setting, which must be set
+func (c *Config) checkKey(key string) error {
+	field, ok := c.field(key)
+	if !ok {
+		return fmt.Errorf("unknown setting %q", key)
+	}
+	value := field.String()
+
+	switch {
+	case key == "reminder_interval":
+		if value == "0" {
+			return nil
+		}
+		return checkDuration(key, value)
+	case key == "timeout":
+		return checkDuration(key, value)
+	case key == "format":
+		switch OutputFormat(value) {
+		case FormatLLM, FormatHuman, FormatJSON:
+			return nil
+		}
+		return fmt.Errorf("format must be llm, human or json, not %q", value)
+	case key == "api_key":
+		if !strings.HasPrefix(value, "cb_live_") && !strings.HasPrefix(value, "cb_test_") {
+			return fmt.Errorf("api_key should start with cb_live_ or cb_test_")
+		}
+	case key == "api_key_ref":
+		_, _, err := secret.ParseRef(value)
+		return err
+	case strings.HasSuffix(key, "_url") || key == "matrix_homeserver" || key == "ntfy_server":
+		u, err := url.Parse(value)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("%s must be an http or https URL, not %q", key, value)
+		}
+	case strings.HasSuffix(key, "_addr") || key == "webhook_listen":
+		if _, _, err := net.SplitHostPort(value); err != nil {
+			return fmt.Errorf("%s must be host:port, not %q", key, value)
+		}
+	}
+	return nil
+}
+
+func checkDuration(key, value string) error {
+	if d, err := time.ParseDuration(value); err != nil || d <= 0 {
+		return fmt.Errorf("%s must be a duration like 15m or 1h, not %q", key, value)
+	}
+	return nil
+}
@@ -0,0 +1,232 @@
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/davedotdev/afk/internal/config"
+)
+
+const historyFile = "history.jsonl"
+
+// Outcome describes how a conversation ended
+type Outcome string
+
+const (
+	OutcomePending   Outcome = "pending"   // Sent, still waiting for a reply
+	OutcomeSent      Outcome = "sent"      // Sent with --no-wait, no reply expected
+	OutcomeReplied   Outcome = "replied"   // Reply received
+	OutcomeTimeout   Outcome = "timeout"   // No reply before the timeout
+	OutcomeCancelled Outcome = "cancelled" // Wait interrupted (Ctrl+C)
+	OutcomeError     Outcome = "error"     // Send or listen failed
+	OutcomeEscalated Outcome = "escalated" // Another hop of the escalation chain was answered
+	OutcomeSkipped   Outcome = "skipped"   // Enough other recipients replied first
+)
+
+// Entry is one message and its reply
+type Entry struct {
+	SessionID string     `json:"session"`
+	MessageID string     `json:"message_id,omitempty"`
+	Transport string     `json:"transport,omitempty"` // Registered channel name, e.g. "whatsapp"
+	Channel   string     `json:"channel"`
+	Message   string     `json:"message,omitempty"`
+	Options   []string   `json:"options,omitempty"`   // Multiple-choice options offered
+	Approval  bool       `json:"approval,omitempty"`  // Sent as a yes/no approval request
+	Hop       int        `json:"hop,omitempty"`       // Escalation hop (1-based), 0 when not escalated
+	Recipient string     `json:"recipient,omitempty"` // Name given to --to, if any
+	SentAt    time.Time  `json:"sent_at"`
+	Reply     string     `json:"reply,omitempty"`
+	ReplyFrom string     `json:"reply_from,omitempty"`
+	RepliedAt *time.Time `json:"replied_at,omitempty"`
+	Choice    string     `json:"choice,omitempty"`
+	ChoiceIdx int        `json:"choice_index,omitempty"`
+	Decision  string     `json:"decision,omitempty"` // approved, denied or unclear
+	Outcome   Outcome    `json:"outcome"`
+	ExitCode  int        `json:"exit_code"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// Filter selects entries from the store. Zero values match everything.
+type Filter struct {
+	Since   time.Time
+	Until   time.Time
+	Channel string
+	Outcome Outcome
+	Search  string // Case-insensitive match on message or reply
+	Limit   int    // Most recent N entries, 0 for all
+}
+
+// Store is an append-only JSON Lines log under ~/.afk. Each write is a full
+// snapshot of an entry; the latest snapshot for a session wins on read.
+type Store struct {
+	path string
+}
+
+// Open returns the store at ~/.afk/history.jsonl
+func Open() (*Store, error) {
+	dir, err := config.Dir()
+	if err != nil {
+		return nil, err
+	}
+	return &Store{path: filepath.Join(dir, historyFile)}, nil
+}
+
+// Path returns the history file path
+func (s *Store) Path() string {
+	return s.path
+}
+
+// Record appends a snapshot of e to the store
+func (s *Store) Record(e *Entry) error {
+	if e.SessionID == "" {
+		return fmt.Errorf("history entry has no session ID")
+	}
+	e.UpdatedAt = time.Now().UTC()
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return fmt.Errorf("failed to create history directory: %w", err)
+	}
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("failed to marshal history entry: %w", err)
+	}
+
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open history: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	return nil
+}
+
+// Get returns the latest entry for a session, or nil if there is none
+func (s *Store) Get(sessionID string) (*Entry, error) {
+	entries, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	for i := range entries {
+		if entries[i].SessionID == sessionID {
+			return &entries[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// List returns the entries matching f, oldest first
+func (s *Store) List(f Filter) ([]Entry, error) {
+	entries, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+
+	var matched []Entry
+	for _, e := range entries {
+		if f.matches(&e) {
+			matched = append(matched, e)
+		}
+	}
+
+	if f.Limit > 0 && len(matched) > f.Limit {
+		matched = matched[len(matched)-f.Limit:]
+	}
+	return matched, nil
+}
+
+// load reads the log and folds snapshots into one entry per session
+func (s *Store) load() ([]Entry, error) {
+	file, err := os.Open(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+	defer file.Close()
+
+	index := make(map[string]int)
+	var entries []Entry
+
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			var e Entry
+			// Skip lines that fail to parse (e.g. a write cut short)
+			if json.Unmarshal(line, &e) == nil && e.SessionID != "" {
+				if i, ok := index[e.SessionID]; ok {
+					entries[i] = e
+				} else {
+					index[e.SessionID] = len(entries)
+					entries = append(entries, e)
+				}
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].SentAt.Before(entries[j].SentAt)
+	})
+	return entries, nil
+}
+
+func (f *Filter) matches(e *Entry) bool {
+	if !f.Since.IsZero() && e.SentAt.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && e.SentAt.After(f.Until) {
+		return false
+	}
+	if f.Channel != "" && !strings.EqualFold(e.Channel, f.Channel) {
+		return false
+	}
+	if f.Outcome != "" && e.Outcome != f.Outcome {
+		return false
+	}
+	if f.Search != "" {
+		q := strings.ToLower(f.Search)
+		if !strings.Contains(strings.ToLower(e.Message), q) &&
+			!strings.Contains(strings.ToLower(e.Reply), q) {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseTime parses a history time bound: either a duration back from now
+// (e.g. "24h"), an RFC 3339 timestamp, or a local date (2006-01-02)
+func ParseTime(value string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(-d), nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q (use a duration like 24h, a date like 2006-01-02, or RFC 3339)", value)
+}
+
+// ParseOutcome validates an outcome name
+func ParseOutcome(value string) (Outcome, error) {
+	switch o := Outcome(strings.ToLower(value)); o {
+	case OutcomePending, OutcomeSent, OutcomeReplied, OutcomeTimeout, OutcomeCancelled, OutcomeError, OutcomeEscalated, OutcomeSkipped:
+		return o, nil
+	}
+	return "", fmt.Errorf("invalid outcome %q (use pending, sent, replied, timeout, cancelled, error, escalated or skipped)", value)
+}
@@ -0,0 +1,399 @@
+// Package imap is a minimal IMAP4rev1 client, enough for the email
+// transport to find and fetch replies: login, select, UID SEARCH,
+// UID FETCH and IDLE.
+package imap
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxLiteralSize bounds a single literal (one fetched message)
+const maxLiteralSize = 32 << 20
+
+// Security selects how the connection is protected
+type Security int
+
+const (
+	// TLS connects with implicit TLS (port 993)
+	TLS Security = iota
+	// StartTLS connects in plaintext and upgrades with STARTTLS
+	StartTLS
+	// Plain never encrypts (local testing only)
+	Plain
+)
+
+// Client is an IMAP connection. It is not safe for concurrent use.
+type Client struct {
+	conn net.Conn
+	br   *bufio.Reader
+	tag  int
+}
+
+// response is one untagged server response, with any literals it carried
+type response struct {
+	line     string
+	literals [][]byte
+}
+
+// StatusError is a NO or BAD completion of a command
+type StatusError struct {
+	Command string
+	Status  string
+	Text    string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("imap %s: %s %s", e.Command, e.Status, e.Text)
+}
+
+// Dial connects to an IMAP server at addr (host:port) and reads the greeting
+func Dial(ctx context.Context, addr string, security Security) (*Client, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid IMAP address: %w", err)
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("imap dial failed: %w", err)
+	}
+
+	if security == TLS {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: host})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("imap TLS handshake failed: %w", err)
+		}
+		conn = tlsConn
+	}
+
+	c := &Client{conn: conn, br: bufio.NewReader(conn)}
+
+	stop := c.bind(ctx)
+	greeting, err := c.readLine()
+	stop()
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("imap greeting: %w", err)
+	}
+	if !strings.HasPrefix(greeting, "* OK") && !strings.HasPrefix(greeting, "* PREAUTH") {
+		conn.Close()
+		return nil, fmt.Errorf("imap greeting: %s", greeting)
+	}
+
+	if security == StartTLS {
+		if _, err := c.execute(ctx, "STARTTLS"); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: host})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("imap TLS handshake failed: %w", err)
+		}
+		c.conn = tlsConn
+		c.br = bufio.NewReader(tlsConn)
+	}
+
+	return c, nil
+}
+
+// Login authenticates with a username and password
+func (c *Client) Login(ctx context.Context, username, password string) error {
+	_, err := c.execute(ctx, "LOGIN "+Quote(username)+" "+Quote(password))
+	return err
+}
+
+// Select opens a mailbox read-only
+func (c *Client) Select(ctx context.Context, mailbox string) error {
+	_, err := c.execute(ctx, "EXAMINE "+Quote(mailbox))
+	return err
+}
+
+// Noop lets the server report mailbox changes and keeps the connection alive
+func (c *Client) Noop(ctx context.Context) error {
+	_, err := c.execute(ctx, "NOOP")
+	return err
+}
+
+// Capability reports whether the server advertises a capability, such as
+// IDLE. Ask after Login, as servers may list more once authenticated.
+func (c *Client) Capability(ctx context.Context, name string) (bool, error) {
+	responses, err := c.execute(ctx, "CAPABILITY")
+	if err != nil {
+		return false, err
+	}
+	for _, r := range responses {
+		rest, ok := strings.CutPrefix(r.line, "* CAPABILITY ")
+		if !ok {
+			continue
+		}
+		for _, field := range strings.Fields(rest) {
+			if strings.EqualFold(field, name) {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// Idle waits in IDLE (RFC 2177) until the server reports a new message in
+// the selected mailbox or maxWait passes, then ends the IDLE. The server
+// must advertise the IDLE capability.
+func (c *Client) Idle(ctx context.Context, maxWait time.Duration) error {
+	stop := c.bind(ctx)
+	defer func() { stop() }()
+
+	tag, err := c.send(ctx, "IDLE")
+	if err != nil {
+		return err
+	}
+	for {
+		r, err := c.readResponse()
+		if err != nil {
+			return c.wrap(ctx, err)
+		}
+		if strings.HasPrefix(r.line, "+") {
+			break
+		}
+		if rest, ok := strings.CutPrefix(r.line, tag+" "); ok {
+			status, text, _ := strings.Cut(rest, " ")
+			return &StatusError{Command: "IDLE", Status: status, Text: text}
+		}
+	}
+
+	// Wait for EXISTS, giving up quietly at maxWait
+	until := time.Now().Add(maxWait)
+	if deadline, ok := ctx.Deadline(); !ok || until.Before(deadline) {
+		c.conn.SetReadDeadline(until)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	for {
+		line, err := c.readLine()
+		if err != nil {
+			var netErr net.Error
+			if ctx.Err() == nil && errors.As(err, &netErr) && netErr.Timeout() {
+				break
+			}
+			return c.wrap(ctx, err)
+		}
+		if strings.HasPrefix(line, "* BYE") {
+			return fmt.Errorf("imap: server closed connection: %s", line)
+		}
+		if strings.HasPrefix(line, "* ") && strings.HasSuffix(line, " EXISTS") {
+			break
+		}
+	}
+
+	// Clear the maxWait deadline before ending the IDLE
+	stop()
+	stop = c.bind(ctx)
+	if _, err := io.WriteString(c.conn, "DONE\r\n"); err != nil {
+		return c.wrap(ctx, err)
+	}
+	_, err = c.complete(ctx, tag, "IDLE")
+	return err
+}
+
+// Search runs UID SEARCH with raw criteria and returns the matching UIDs.
+// Use Quote for string arguments.
+func (c *Client) Search(ctx context.Context, criteria string) ([]uint32, error) {
+	responses, err := c.execute(ctx, "UID SEARCH "+criteria)
+	if err != nil {
+		return nil, err
+	}
+
+	var uids []uint32
+	for _, r := range responses {
+		rest, ok := strings.CutPrefix(r.line, "* SEARCH")
+		if !ok {
+			continue
+		}
+		for _, field := range strings.Fields(rest) {
+			n, err := strconv.ParseUint(field, 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("imap search: bad UID %q", field)
+			}
+			uids = append(uids, uint32(n))
+		}
+	}
+	return uids, nil
+}
+
+// Fetch returns the full RFC 5322 message with the given UID, without
+// setting the \Seen flag
+func (c *Client) Fetch(ctx context.Context, uid uint32) ([]byte, error) {
+	responses, err := c.execute(ctx, fmt.Sprintf("UID FETCH %d BODY.PEEK[]", uid))
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range responses {
+		if strings.Contains(r.line, " FETCH ") && len(r.literals) > 0 {
+			return r.literals[0], nil
+		}
+	}
+	return nil, fmt.Errorf("imap fetch: message %d not found", uid)
+}
+
+// Logout ends the session and closes the connection
+func (c *Client) Logout(ctx context.Context) error {
+	_, err := c.execute(ctx, "LOGOUT")
+	c.conn.Close()
+	return err
+}
+
+// Close closes the connection without logging out
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// Quote returns s as an IMAP quoted string
+func Quote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return `"` + s + `"`
+}
+
+// bind makes reads and writes fail once ctx ends. Call the returned
+// function when the exchange is done.
+func (c *Client) bind(ctx context.Context) func() {
+	if deadline, ok := ctx.Deadline(); ok {
+		c.conn.SetDeadline(deadline)
+	} else {
+		c.conn.SetDeadline(time.Time{})
+	}
+	stop := context.AfterFunc(ctx, func() {
+		c.conn.SetDeadline(time.Now())
+	})
+	return func() { stop() }
+}
+
+// execute sends a tagged command and collects untagged responses until
+// its completion
+func (c *Client) execute(ctx context.Context, command string) ([]response, error) {
+	stop := c.bind(ctx)
+	defer stop()
+
+	tag, err := c.send(ctx, command)
+	if err != nil {
+		return nil, err
+	}
+
+	name := command
+	if i := strings.IndexByte(name, ' '); i > 0 {
+		name = name[:i]
+	}
+	if name == "UID" {
+		name = strings.Fields(command)[1]
+	}
+	return c.complete(ctx, tag, name)
+}
+
+// send writes a command with the next tag and returns the tag
+func (c *Client) send(ctx context.Context, command string) (string, error) {
+	c.tag++
+	tag := fmt.Sprintf("A%03d", c.tag)
+	if _, err := io.WriteString(c.conn, tag+" "+command+"\r\n"); err != nil {
+		return "", c.wrap(ctx, err)
+	}
+	return tag, nil
+}
+
+// complete collects untagged responses until the tagged completion of the
+// named command
+func (c *Client) complete(ctx context.Context, tag, name string) ([]response, error) {
+	var responses []response
+	for {
+		r, err := c.readResponse()
+		if err != nil {
+			return nil, c.wrap(ctx, err)
+		}
+
+		if rest, ok := strings.CutPrefix(r.line, tag+" "); ok {
+			status, text, _ := strings.Cut(rest, " ")
+			if !strings.EqualFold(status, "OK") {
+				return nil, &StatusError{Command: name, Status: status, Text: text}
+			}
+			return responses, nil
+		}
+		if strings.HasPrefix(r.line, "* BYE") && name != "LOGOUT" {
+			return nil, fmt.Errorf("imap: server closed connection: %s", r.line)
+		}
+		responses = append(responses, r)
+	}
+}
+
+// readResponse reads one response line, following any {n} literals
+func (c *Client) readResponse() (response, error) {
+	var r response
+	var line strings.Builder
+
+	for {
+		part, err := c.readLine()
+		if err != nil {
+			return r, err
+		}
+		line.WriteString(part)
+
+		n, ok := literalSize(part)
+		if !ok {
+			r.line = line.String()
+			return r, nil
+		}
+		if n > maxLiteralSize {
+			return r, fmt.Errorf("imap: literal of %d bytes too large", n)
+		}
+		literal := make([]byte, n)
+		if _, err := io.ReadFull(c.br, literal); err != nil {
+			return r, err
+		}
+		r.literals = append(r.literals, literal)
+	}
+}
+
+// literalSize parses a trailing "{n}" literal marker
+func literalSize(line string) (int, bool) {
+	if !strings.HasSuffix(line, "}") {
+		return 0, false
+	}
+	i := strings.LastIndexByte(line, '{')
+	if i < 0 {
+		return 0, false
+	}
+	n, err := strconv.Atoi(line[i+1 : len(line)-1])
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	return n, true
+}
+
+func (c *Client) readLine() (string, error) {
+	line, err := c.br.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// wrap reports a cancelled context in place of the I/O error it caused
+func (c *Client) wrap(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	if errors.Is(err, io.EOF) {
+		return errors.New("imap: connection closed")
+	}
+	return fmt.Errorf("imap: %w", err)
+}
@@ -4,9 +4,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/davedotdev/afk/internal/config"
+	"github.com/davedotdev/afk/internal/history"
 )
 
 // Formatter handles output formatting based on the configured format
@@ -94,16 +96,77 @@ func (f *Formatter) Waiting(sessionID string, elapsed, remaining time.Duration)
 	}
 }
 
-// Response outputs the received response
-func (f *Formatter) Response(sessionID, from, channel, content string, waitTime time.Duration) {
+// Reconnecting outputs a notice that the response stream dropped and is being retried
+func (f *Formatter) Reconnecting(sessionID string, attempt int, delay time.Duration, cause error) {
 	if f.quiet {
-		fmt.Println(content)
 		return
 	}
 
+	reason := ""
+	if cause != nil {
+		reason = cause.Error()
+	}
+
 	switch f.format {
 	case config.FormatJSON:
 		f.jsonOutput(map[string]interface{}{
+			"event":   "reconnecting",
+			"session": sessionID,
+			"attempt": attempt,
+			"delay":   delay.String(),
+			"reason":  reason,
+		})
+	case config.FormatHuman:
+		fmt.Fprintf(os.Stderr, "Connection lost (%s). Reconnecting in %s (attempt %d)...\n",
+			reason, formatDuration(delay), attempt)
+	default: // FormatLLM
+		fmt.Fprintln(os.Stderr, "═══ AFK RECONNECTING ═══")
+		fmt.Fprintf(os.Stderr, "Session: %s\n", sessionID)
+		fmt.Fprintf(os.Stderr, "Attempt: %d | Retry-In: %s\n", attempt, formatDuration(delay))
+		fmt.Fprintf(os.Stderr, "Reason: %s\n", reason)
+	}
+}
+
+// Response outputs the received response. hop is the escalation hop that
+// was answered, or 0 when the message was not escalated.
+func (f *Formatter) Response(sessionID, from, channel, content string, waitTime time.Duration, hop int) {
+	f.response(sessionID, from, channel, content, waitTime, replyDetails{hop: hop})
+}
+
+// ChoiceResponse outputs a response matched to one of the numbered options
+func (f *Formatter) ChoiceResponse(sessionID, from, channel, content string, waitTime time.Duration, hop, index int, label string) {
+	f.response(sessionID, from, channel, content, waitTime, replyDetails{
+		hop:         hop,
+		choiceIndex: index,
+		choice:      label,
+	})
+}
+
+// ApprovalResponse outputs a response to a yes/no approval request
+func (f *Formatter) ApprovalResponse(sessionID, from, channel, content string, waitTime time.Duration, hop int, decision string) {
+	f.response(sessionID, from, channel, content, waitTime, replyDetails{
+		hop:      hop,
+		decision: decision,
+	})
+}
+
+// replyDetails holds structured interpretations of a response
+type replyDetails struct {
+	hop         int // Escalation hop answered (1-based), 0 when not escalated
+	choiceIndex int // 1-based, 0 when not a multiple-choice reply
+	choice      string
+	decision    string // approved, denied or unclear for approval requests
+}
+
+func (f *Formatter) response(sessionID, from, channel, content string, waitTime time.Duration, details replyDetails) {
+	if f.quiet {
+		fmt.Println(content)
+		return
+	}
+
+	switch f.format {
+	case config.FormatJSON:
+		data := map[string]interface{}{
 			"event":       "response",
 			"session":     sessionID,
 			"from":        from,
@@ -111,15 +174,37 @@ func (f *Formatter) Response(sessionID, from, channel, content string, waitTime
 			"content":     content,
 			"wait_time":   waitTime.String(),
 			"received_at": time.Now().UTC().Format(time.RFC3339),
-		})
+		}
+		if details.hop > 0 {
+			data["hop"] = details.hop
+		}
+		if details.choiceIndex > 0 {
+			data["choice"] = details.choice
+			data["choice_index"] = details.choiceIndex
+		}
+		if details.decision != "" {
+			data["decision"] = details.decision
+			data["approved"] = details.decision == "approved"
+		}
+		f.jsonOutput(data)
 	case config.FormatHuman:
 		fmt.Println()
 		fmt.Println("────────────────────────────────────────")
-		fmt.Printf("[%s] Response from %s:\n", time.Now().Format("15:04:05"), from)
+		if details.hop > 0 {
+			fmt.Printf("[%s] Response from %s (escalation hop %d, %s):\n", time.Now().Format("15:04:05"), from, details.hop, channel)
+		} else {
+			fmt.Printf("[%s] Response from %s:\n", time.Now().Format("15:04:05"), from)
+		}
 		fmt.Println()
 		fmt.Println(content)
 		fmt.Println()
 		fmt.Println("────────────────────────────────────────")
+		if details.choiceIndex > 0 {
+			fmt.Printf("Choice: %d. %s\n", details.choiceIndex, details.choice)
+		}
+		if details.decision != "" {
+			fmt.Printf("Decision: %s\n", strings.ToUpper(details.decision))
+		}
 		fmt.Println()
 		fmt.Println("Response received. Exiting.")
 	default: // FormatLLM
@@ -128,12 +213,208 @@ func (f *Formatter) Response(sessionID, from, channel, content string, waitTime
 		fmt.Printf("Session: %s\n", sessionID)
 		fmt.Printf("From: %s\n", from)
 		fmt.Printf("Channel: %s\n", channel)
+		if details.hop > 0 {
+			fmt.Printf("Hop: %d\n", details.hop)
+		}
 		fmt.Printf("Received: %s\n", time.Now().UTC().Format(time.RFC3339))
 		fmt.Printf("Wait-Time: %s\n", formatDuration(waitTime))
 		fmt.Println()
 		fmt.Println("<response>")
 		fmt.Println(content)
 		fmt.Println("</response>")
+		if details.choiceIndex > 0 {
+			fmt.Printf("<choice index=\"%d\">%s</choice>\n", details.choiceIndex, details.choice)
+		}
+		if details.decision != "" {
+			fmt.Printf("<decision>%s</decision>\n", details.decision)
+		}
+	}
+}
+
+// RecipientReply is one recipient's part in a message sent to several
+type RecipientReply struct {
+	Recipient   string
+	SessionID   string
+	Status      string // replied, pending, timeout, skipped or error
+	From        string
+	Channel     string
+	Content     string
+	WaitTime    time.Duration
+	ChoiceIndex int // 1-based, 0 when not a multiple-choice reply
+	Choice      string
+	Decision    string // approved, denied or unclear for approval requests
+}
+
+// Responses outputs the replies to a message sent to several recipients.
+// require is the --require policy, needed the number of replies (or
+// approvals) it asks for, and decision the combined approval decision.
+func (f *Formatter) Responses(require string, needed int, replies []RecipientReply, decision string) {
+	received := 0
+	for _, r := range replies {
+		if r.Status == "replied" {
+			received++
+		}
+	}
+
+	if f.quiet {
+		for _, r := range replies {
+			if r.Status == "replied" {
+				fmt.Printf("%s: %s\n", r.Recipient, r.Content)
+			}
+		}
+		return
+	}
+
+	switch f.format {
+	case config.FormatJSON:
+		var recipients []map[string]interface{}
+		for _, r := range replies {
+			rec := map[string]interface{}{
+				"recipient": r.Recipient,
+				"session":   r.SessionID,
+				"status":    r.Status,
+			}
+			if r.Status == "replied" {
+				rec["from"] = r.From
+				rec["channel"] = r.Channel
+				rec["content"] = r.Content
+				rec["wait_time"] = r.WaitTime.String()
+			}
+			if r.ChoiceIndex > 0 {
+				rec["choice"] = r.Choice
+				rec["choice_index"] = r.ChoiceIndex
+			}
+			if r.Decision != "" {
+				rec["decision"] = r.Decision
+			}
+			recipients = append(recipients, rec)
+		}
+		data := map[string]interface{}{
+			"event":       "responses",
+			"require":     require,
+			"required":    needed,
+			"received":    received,
+			"recipients":  recipients,
+			"received_at": time.Now().UTC().Format(time.RFC3339),
+		}
+		if decision != "" {
+			data["decision"] = decision
+			data["approved"] = decision == "approved"
+		}
+		f.jsonOutput(data)
+	case config.FormatHuman:
+		fmt.Println()
+		fmt.Println("────────────────────────────────────────")
+		fmt.Printf("[%s] %d of %d recipients replied (require: %s)\n", time.Now().Format("15:04:05"), received, len(replies), require)
+		for _, r := range replies {
+			fmt.Println()
+			if r.Status != "replied" {
+				fmt.Printf("%s: (%s)\n", r.Recipient, r.Status)
+				continue
+			}
+			fmt.Printf("%s (%s via %s):\n", r.Recipient, r.From, r.Channel)
+			fmt.Println(r.Content)
+			if r.ChoiceIndex > 0 {
+				fmt.Printf("Choice: %d. %s\n", r.ChoiceIndex, r.Choice)
+			}
+			if r.Decision != "" {
+				fmt.Printf("Decision: %s\n", strings.ToUpper(r.Decision))
+			}
+		}
+		fmt.Println("────────────────────────────────────────")
+		if decision != "" {
+			fmt.Printf("Decision: %s\n", strings.ToUpper(decision))
+		}
+	default: // FormatLLM
+		fmt.Println()
+		fmt.Println("═══ AFK RESPONSES ═══")
+		fmt.Printf("Require: %s (%d needed)\n", require, needed)
+		fmt.Printf("Received: %d of %d\n", received, len(replies))
+		fmt.Println()
+		for _, r := range replies {
+			if r.Status != "replied" {
+				fmt.Printf("<no-response recipient=%q session=%q status=%q/>\n", r.Recipient, r.SessionID, r.Status)
+				continue
+			}
+			fmt.Printf("<response recipient=%q session=%q from=%q channel=%q wait-time=%q>\n",
+				r.Recipient, r.SessionID, r.From, r.Channel, formatDuration(r.WaitTime))
+			fmt.Println(r.Content)
+			fmt.Println("</response>")
+			if r.ChoiceIndex > 0 {
+				fmt.Printf("<choice recipient=%q index=\"%d\">%s</choice>\n", r.Recipient, r.ChoiceIndex, r.Choice)
+			}
+			if r.Decision != "" {
+				fmt.Printf("<decision recipient=%q>%s</decision>\n", r.Recipient, r.Decision)
+			}
+		}
+		if decision != "" {
+			fmt.Println()
+			fmt.Printf("<decision>%s</decision>\n", decision)
+		}
+	}
+}
+
+// Escalated outputs a notice that the message is being sent on the next hop
+// of the escalation chain, either because no reply arrived within elapsed
+// or because the previous hop failed with cause
+func (f *Formatter) Escalated(hop int, channel string, elapsed time.Duration, cause error) {
+	if f.quiet {
+		return
+	}
+
+	reason := "No reply after " + formatDuration(elapsed)
+	if cause != nil {
+		reason = cause.Error()
+	}
+
+	switch f.format {
+	case config.FormatJSON:
+		f.jsonOutput(map[string]interface{}{
+			"event":   "escalating",
+			"hop":     hop,
+			"channel": channel,
+			"elapsed": elapsed.String(),
+			"reason":  reason,
+		})
+	case config.FormatHuman:
+		fmt.Printf("\n%s. Escalating to %s (hop %d)...\n", reason, channel, hop)
+	default: // FormatLLM
+		fmt.Println()
+		fmt.Println("═══ AFK ESCALATING ═══")
+		fmt.Printf("Hop: %d\n", hop)
+		fmt.Printf("Channel: %s\n", channel)
+		fmt.Printf("Reason: %s\n", reason)
+		fmt.Println()
+	}
+}
+
+// Unmatched outputs a notice that a multiple-choice reply matched no option
+// and the question is being asked again
+func (f *Formatter) Unmatched(sessionID, content string, attempt, maxAttempts int) {
+	if f.quiet {
+		return
+	}
+
+	switch f.format {
+	case config.FormatJSON:
+		f.jsonOutput(map[string]interface{}{
+			"event":        "unmatched_reply",
+			"session":      sessionID,
+			"content":      content,
+			"reprompt":     attempt,
+			"max_reprompt": maxAttempts,
+		})
+	case config.FormatHuman:
+		fmt.Printf("\nReply %q matched no option. Asking again (%d/%d)...\n", summarize(content), attempt, maxAttempts)
+	default: // FormatLLM
+		fmt.Println()
+		fmt.Println("═══ AFK UNMATCHED REPLY ═══")
+		fmt.Printf("Session: %s\n", sessionID)
+		fmt.Printf("Reprompt: %d of %d\n", attempt, maxAttempts)
+		fmt.Println("<response>")
+		fmt.Println(content)
+		fmt.Println("</response>")
+		fmt.Println()
 	}
 }
 
@@ -218,6 +499,35 @@ func (f *Formatter) Cancelled() {
 	}
 }
 
+// Resumed outputs the confirmation that afk re-attached to an existing session
+func (f *Formatter) Resumed(sessionID string, timeout time.Duration) {
+	if f.quiet {
+		return
+	}
+
+	switch f.format {
+	case config.FormatJSON:
+		f.jsonOutput(map[string]interface{}{
+			"event":             "wait_resumed",
+			"session":           sessionID,
+			"timeout":           timeout.String(),
+			"awaiting_response": true,
+		})
+	case config.FormatHuman:
+		fmt.Printf("Session: %s\n", sessionID)
+		fmt.Println()
+		fmt.Printf("Waiting for response (timeout: %s)...\n", formatDuration(timeout))
+		fmt.Println("Press Ctrl+C to cancel")
+		fmt.Println()
+	default: // FormatLLM
+		fmt.Println("═══ AFK WAITING ═══")
+		fmt.Printf("Session: %s\n", sessionID)
+		fmt.Printf("Timeout: %s\n", formatDuration(timeout))
+		fmt.Println()
+		fmt.Println("Awaiting response...")
+	}
+}
+
 // WaitingStart outputs the initial waiting message (before SSE connects)
 func (f *Formatter) WaitingStart(timeout time.Duration) {
 	if f.quiet {
@@ -237,12 +547,289 @@ func (f *Formatter) WaitingStart(timeout time.Duration) {
 	}
 }
 
+// History outputs stored messages and their replies
+func (f *Formatter) History(entries []history.Entry) {
+	if f.quiet {
+		for _, e := range entries {
+			fmt.Println(e.SessionID)
+		}
+		return
+	}
+
+	switch f.format {
+	case config.FormatJSON:
+		if entries == nil {
+			entries = []history.Entry{}
+		}
+		f.jsonOutput(map[string]interface{}{
+			"event":   "history",
+			"count":   len(entries),
+			"entries": entries,
+		})
+	case config.FormatHuman:
+		if len(entries) == 0 {
+			fmt.Println("No messages in history.")
+			return
+		}
+		for _, e := range entries {
+			fmt.Printf("%s  %-8s  %-9s  %s\n",
+				e.SentAt.Local().Format("2006-01-02 15:04"), e.Channel, e.Outcome, e.SessionID)
+			if e.Recipient != "" {
+				fmt.Printf("  To: %s\n", e.Recipient)
+			}
+			if e.Message != "" {
+				fmt.Printf("  Q: %s\n", summarize(e.Message))
+			}
+			if e.Reply != "" {
+				fmt.Printf("  A: %s\n", summarize(e.Reply))
+			}
+		}
+	default: // FormatLLM
+		fmt.Println("═══ AFK HISTORY ═══")
+		fmt.Printf("Entries: %d\n", len(entries))
+		for _, e := range entries {
+			fmt.Println()
+			fmt.Printf("Session: %s\n", e.SessionID)
+			if e.Channel != "" {
+				fmt.Printf("Channel: %s\n", e.Channel)
+			}
+			if e.Recipient != "" {
+				fmt.Printf("Recipient: %s\n", e.Recipient)
+			}
+			if e.Hop > 0 {
+				fmt.Printf("Hop: %d\n", e.Hop)
+			}
+			fmt.Printf("Sent: %s\n", e.SentAt.UTC().Format(time.RFC3339))
+			fmt.Printf("Outcome: %s\n", e.Outcome)
+			if e.Message != "" {
+				fmt.Println("<message>")
+				fmt.Println(e.Message)
+				fmt.Println("</message>")
+			}
+			if e.Reply != "" {
+				if e.RepliedAt != nil {
+					fmt.Printf("Received: %s\n", e.RepliedAt.UTC().Format(time.RFC3339))
+				}
+				fmt.Println("<response>")
+				fmt.Println(e.Reply)
+				fmt.Println("</response>")
+			}
+		}
+	}
+}
+
+// ContactEntry is a contact listed by afk contacts
+type ContactEntry struct {
+	Name string `json:"name"`
+	config.Contact
+	Groups []string `json:"groups,omitempty"`
+	Source string   `json:"source"` // File the contact is defined in
+}
+
+// Contacts outputs the contact book
+func (f *Formatter) Contacts(entries []ContactEntry) {
+	if f.quiet {
+		for _, e := range entries {
+			fmt.Println(e.Name)
+		}
+		return
+	}
+
+	switch f.format {
+	case config.FormatJSON:
+		if entries == nil {
+			entries = []ContactEntry{}
+		}
+		f.jsonOutput(map[string]interface{}{
+			"event":    "contacts",
+			"count":    len(entries),
+			"contacts": entries,
+		})
+	case config.FormatHuman:
+		if len(entries) == 0 {
+			fmt.Println("No contacts. Add one with 'afk contacts add <name> --slack U123'.")
+			return
+		}
+		for _, e := range entries {
+			fmt.Printf("%-12s  %s\n", e.Name, e.Description)
+			for _, field := range contactFields(e.Contact) {
+				fmt.Printf("  %-9s %s\n", field[0]+":", field[1])
+			}
+			if len(e.Groups) > 0 {
+				fmt.Printf("  %-9s %s\n", "Groups:", strings.Join(e.Groups, ", "))
+			}
+		}
+	default: // FormatLLM
+		fmt.Println("═══ AFK CONTACTS ═══")
+		fmt.Printf("Contacts: %d\n", len(entries))
+		for _, e := range entries {
+			fmt.Println()
+			fmt.Printf("Name: %s\n", e.Name)
+			if e.Description != "" {
+				fmt.Printf("Description: %s\n", e.Description)
+			}
+			for _, field := range contactFields(e.Contact) {
+				fmt.Printf("%s: %s\n", field[0], field[1])
+			}
+			if len(e.Groups) > 0 {
+				fmt.Printf("Groups: %s\n", strings.Join(e.Groups, ", "))
+			}
+		}
+	}
+}
+
+// LoginResult describes the credentials saved by afk login
+type LoginResult struct {
+	Profile    string
+	Config     string // Config file written
+	APIURL     string
+	SysName    string
+	KeyPrefix  string
+	KeyStorage string // e.g. "system keyring (Secret Service)"
+	Validated  bool   // False with --skip-validate
+}
+
+// LoggedIn outputs the result of afk login
+func (f *Formatter) LoggedIn(r LoginResult) {
+	if f.format == config.FormatJSON {
+		f.jsonOutput(map[string]interface{}{
+			"event":       "login",
+			"status":      200,
+			"profile":     r.Profile,
+			"config":      r.Config,
+			"api_url":     r.APIURL,
+			"sys_name":    r.SysName,
+			"key_prefix":  r.KeyPrefix,
+			"key_storage": r.KeyStorage,
+			"validated":   r.Validated,
+		})
+		return
+	}
+
+	fmt.Println()
+	if !r.Validated {
+		fmt.Println("Warning: API URL and key were not checked (--skip-validate)")
+	}
+	if r.KeyStorage != config.PlaintextStorage {
+		fmt.Printf("API key stored in the %s\n", r.KeyStorage)
+	}
+	if r.Profile != "" {
+		fmt.Printf("Credentials saved to profile %q in %s\n", r.Profile, r.Config)
+		fmt.Println()
+		fmt.Println("Select it with --profile or AFK_PROFILE:")
+		fmt.Printf("  afk --profile %s --whatsapp --msg \"Your message\"\n", r.Profile)
+		fmt.Printf("  AFK_PROFILE=%s afk --sms --msg \"Your message\"\n", r.Profile)
+		return
+	}
+	fmt.Printf("Credentials saved to %s\n", r.Config)
+	fmt.Println()
+	fmt.Println("You can now use:")
+	fmt.Println("  afk --sms --msg \"Your message\"")
+	fmt.Println("  afk --whatsapp --msg \"Your message\"")
+}
+
+// Settings outputs the effective config and where each setting came from
+func (f *Formatter) Settings(path, project, profile string, settings []config.Setting) {
+	if f.quiet {
+		for _, s := range settings {
+			if listedSetting(s) {
+				fmt.Printf("%s=%s\n", s.Key, s.Value)
+			}
+		}
+		return
+	}
+
+	switch f.format {
+	case config.FormatJSON:
+		if settings == nil {
+			settings = []config.Setting{}
+		}
+		f.jsonOutput(map[string]interface{}{
+			"event":    "config",
+			"path":     path,
+			"project":  project,
+			"profile":  profile,
+			"settings": settings,
+		})
+	case config.FormatHuman:
+		fmt.Printf("Config: %s\n", path)
+		if project != "" {
+			fmt.Printf("Project: %s\n", project)
+		}
+		if profile != "" {
+			fmt.Printf("Profile: %s\n", profile)
+		}
+		fmt.Println()
+		for _, s := range settings {
+			switch {
+			case s.Source != "":
+				fmt.Printf("%-24s %-32s %s\n", s.Key, s.Value, s.Source)
+			case listedSetting(s):
+				fmt.Printf("%-24s %s\n", s.Key, "(unset)")
+			}
+		}
+	default: // FormatLLM
+		fmt.Println("═══ AFK CONFIG ═══")
+		fmt.Printf("Config: %s\n", path)
+		if project != "" {
+			fmt.Printf("Project: %s\n", project)
+		}
+		if profile != "" {
+			fmt.Printf("Profile: %s\n", profile)
+		}
+		fmt.Println()
+		for _, s := range settings {
+			switch {
+			case s.Source != "":
+				fmt.Printf("%s: %s (from %s)\n", s.Key, s.Value, s.Source)
+			case listedSetting(s):
+				fmt.Printf("%s: (unset)\n", s.Key)
+			}
+		}
+	}
+}
+
+// listedSetting reports whether config show prints the setting: any that
+// is set, and the API key even when unset, as before login
+func listedSetting(s config.Setting) bool {
+	return s.Source != "" || s.Key == "api_key"
+}
+
+// contactFields lists a contact's addresses as label/value pairs
+func contactFields(c config.Contact) [][2]string {
+	var fields [][2]string
+	for _, field := range [][2]string{
+		{"Phone", c.Phone},
+		{"Slack", c.Slack},
+		{"Email", c.Email},
+		{"Telegram", c.Telegram},
+		{"Discord", c.Discord},
+		{"Matrix", c.Matrix},
+		{"ntfy", c.Ntfy},
+	} {
+		if field[1] != "" {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
 func (f *Formatter) jsonOutput(data map[string]interface{}) {
 	enc := json.NewEncoder(os.Stdout)
 	enc.SetIndent("", "  ")
 	enc.Encode(data)
 }
 
+// summarize flattens text to a single line of at most 72 characters
+func summarize(text string) string {
+	line := strings.Join(strings.Fields(text), " ")
+	runes := []rune(line)
+	if len(runes) > 72 {
+		return string(runes[:71]) + "…"
+	}
+	return line
+}
+
 func formatDuration(d time.Duration) string {
 	if d >= time.Hour {
 		hours := d / time.Hour
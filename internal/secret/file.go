@@ -0,0 +1,293 @@
+package secret
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// PassphraseEnv is the environment variable that supplies the passphrase
+// for the encrypted file, for runs without a terminal
+const PassphraseEnv = "AFK_PASSPHRASE"
+
+// Key derivation parameters for the encrypted file
+const (
+	kdfIterations = 600000
+	saltSize      = 16
+	keySize       = 32 // AES-256
+)
+
+// sealed is the encrypted file as stored
+type sealed struct {
+	Version    int    `json:"version"`
+	KDF        string `json:"kdf"`
+	Iterations int    `json:"iterations"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Data       []byte `json:"data"` // AES-GCM sealed JSON object of account to secret
+}
+
+type fileStore struct {
+	path string
+}
+
+// NewFile returns a store in an encrypted file at path
+func NewFile(path string) Store {
+	return fileStore{path: path}
+}
+
+func (s fileStore) Description() string {
+	return "encrypted file (" + s.path + ")"
+}
+
+func (s fileStore) Get(account string) (string, error) {
+	secrets, err := s.open(false)
+	if err != nil {
+		return "", err
+	}
+	secret, ok := secrets[account]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return secret, nil
+}
+
+func (s fileStore) Set(account, secret string) error {
+	secrets, err := s.open(true)
+	if err != nil {
+		return err
+	}
+	secrets[account] = secret
+	return s.save(secrets)
+}
+
+func (s fileStore) Delete(account string) error {
+	if _, err := os.Stat(s.path); os.IsNotExist(err) {
+		return nil
+	}
+	secrets, err := s.open(false)
+	if err != nil {
+		return err
+	}
+	delete(secrets, account)
+	if len(secrets) == 0 {
+		return os.Remove(s.path)
+	}
+	return s.save(secrets)
+}
+
+// open decrypts the file. A missing file is empty when create is set, and
+// the passphrase for it is asked twice.
+func (s fileStore) open(create bool) (map[string]string, error) {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) && create {
+			if _, err := passphrase(true); err != nil {
+				return nil, err
+			}
+			return make(map[string]string), nil
+		}
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("no encrypted secrets file at %s", s.path)
+		}
+		return nil, fmt.Errorf("failed to read secrets: %w", err)
+	}
+
+	var file sealed
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", s.path, err)
+	}
+	if file.Version != 1 || file.KDF != "pbkdf2-sha256" {
+		return nil, fmt.Errorf("%s: unsupported format", s.path)
+	}
+
+	pass, err := passphrase(false)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(pass, file.Salt, file.Iterations)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := gcm.Open(nil, file.Nonce, file.Data, nil)
+	if err != nil {
+		forgetPassphrase()
+		return nil, errors.New("wrong passphrase for the encrypted secrets file")
+	}
+
+	secrets := make(map[string]string)
+	if err := json.Unmarshal(plain, &secrets); err != nil {
+		return nil, fmt.Errorf("failed to parse secrets: %w", err)
+	}
+	return secrets, nil
+}
+
+// save encrypts secrets with a fresh salt and nonce and replaces the file
+func (s fileStore) save(secrets map[string]string) error {
+	plain, err := json.Marshal(secrets)
+	if err != nil {
+		return fmt.Errorf("failed to marshal secrets: %w", err)
+	}
+
+	pass, err := passphrase(false)
+	if err != nil {
+		return err
+	}
+	file := sealed{Version: 1, KDF: "pbkdf2-sha256", Iterations: kdfIterations, Salt: make([]byte, saltSize)}
+	if _, err := rand.Read(file.Salt); err != nil {
+		return fmt.Errorf("failed to generate salt: %w", err)
+	}
+	gcm, err := newGCM(pass, file.Salt, file.Iterations)
+	if err != nil {
+		return err
+	}
+	file.Nonce = make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(file.Nonce); err != nil {
+		return fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	file.Data = gcm.Seal(nil, file.Nonce, plain, nil)
+
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal secrets: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return fmt.Errorf("failed to create secrets directory: %w", err)
+	}
+
+	// Write a temporary file and rename it so a failed write keeps the old one
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write secrets: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write secrets: %w", err)
+	}
+	return nil
+}
+
+func newGCM(pass string, salt []byte, iterations int) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(pbkdf2([]byte(pass), salt, iterations, keySize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// pbkdf2 derives a key from password with PBKDF2-HMAC-SHA256 (RFC 8018)
+func pbkdf2(password, salt []byte, iterations, keyLen int) []byte {
+	prf := hmac.New(sha256.New, password)
+	hashLen := prf.Size()
+	blocks := (keyLen + hashLen - 1) / hashLen
+
+	key := make([]byte, 0, blocks*hashLen)
+	u := make([]byte, hashLen)
+	var counter [4]byte
+	for block := 1; block <= blocks; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		binary.BigEndian.PutUint32(counter[:], uint32(block))
+		prf.Write(counter[:])
+		key = prf.Sum(key)
+
+		t := key[len(key)-hashLen:]
+		copy(u, t)
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range u {
+				t[j] ^= u[j]
+			}
+		}
+	}
+	return key[:keyLen]
+}
+
+var (
+	passMu sync.Mutex
+	pass   string // Passphrase entered once per run
+)
+
+// passphrase returns $AFK_PASSPHRASE or asks for it on the terminal, twice
+// when confirm is set, remembering it for the rest of the run
+func passphrase(confirm bool) (string, error) {
+	passMu.Lock()
+	defer passMu.Unlock()
+
+	if pass != "" {
+		return pass, nil
+	}
+	if env := os.Getenv(PassphraseEnv); env != "" {
+		pass = env
+		return pass, nil
+	}
+
+	entered, err := readHidden("Passphrase for the afk secrets file: ")
+	if err != nil {
+		return "", err
+	}
+	if entered == "" {
+		return "", errors.New("passphrase is required")
+	}
+	if confirm {
+		again, err := readHidden("Repeat passphrase: ")
+		if err != nil {
+			return "", err
+		}
+		if again != entered {
+			return "", errors.New("passphrases do not match")
+		}
+	}
+	pass = entered
+	return pass, nil
+}
+
+func forgetPassphrase() {
+	passMu.Lock()
+	pass = ""
+	passMu.Unlock()
+}
+
+// readHidden prompts on the terminal and reads a line with echo turned off
+func readHidden(prompt string) (string, error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return "", fmt.Errorf("no terminal to ask for the passphrase: set %s", PassphraseEnv)
+	}
+	defer tty.Close()
+
+	fmt.Fprint(tty, prompt)
+	if stty(tty, "-echo") == nil {
+		defer func() {
+			stty(tty, "echo")
+			fmt.Fprintln(tty)
+		}()
+	}
+
+	line, err := bufio.NewReader(tty).ReadString('\n')
+	if err != nil && line == "" {
+		return "", fmt.Errorf("failed to read passphrase: %w", err)
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// stty changes the terminal mode, where stty is available
+func stty(tty *os.File, mode string) error {
+	cmd := exec.Command("stty", mode)
+	cmd.Stdin = tty
+	return cmd.Run()
+}
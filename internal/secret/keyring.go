@@ -0,0 +1,79 @@
+package secret
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// service is the attribute afk's keyring items are stored under
+const service = "afk"
+
+type keyringStore struct{}
+
+// NewKeyring returns the Secret Service keyring (GNOME Keyring, KWallet),
+// accessed with secret-tool from libsecret
+func NewKeyring() Store {
+	return keyringStore{}
+}
+
+// KeyringAvailable reports whether secret-tool is installed and a session
+// bus is running to reach the keyring
+func KeyringAvailable() bool {
+	if _, err := exec.LookPath("secret-tool"); err != nil {
+		return false
+	}
+	return os.Getenv("DBUS_SESSION_BUS_ADDRESS") != ""
+}
+
+func (keyringStore) Description() string {
+	return "system keyring (Secret Service)"
+}
+
+func (keyringStore) Get(account string) (string, error) {
+	out, err := secretTool(nil, "lookup", "service", service, "account", account)
+	if err != nil {
+		return "", err
+	}
+	// lookup prints nothing and may exit 1 when there is no such item
+	if out == "" {
+		return "", ErrNotFound
+	}
+	return out, nil
+}
+
+func (keyringStore) Set(account, secret string) error {
+	label := fmt.Sprintf("afk API key (%s)", account)
+	_, err := secretTool(strings.NewReader(secret), "store", "--label", label, "service", service, "account", account)
+	return err
+}
+
+func (keyringStore) Delete(account string) error {
+	_, err := secretTool(nil, "clear", "service", service, "account", account)
+	return err
+}
+
+// secretTool runs secret-tool and returns its output. A lookup that finds
+// nothing returns "" rather than an error.
+func secretTool(stdin *strings.Reader, args ...string) (string, error) {
+	cmd := exec.Command("secret-tool", args...)
+	if stdin != nil {
+		cmd.Stdin = stdin
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if args[0] == "lookup" && stderr.Len() == 0 {
+			return "", nil
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("secret-tool %s: %s", args[0], msg)
+		}
+		return "", fmt.Errorf("secret-tool %s: %w", args[0], err)
+	}
+	return strings.TrimRight(stdout.String(), "\n"), nil
+}
@@ -0,0 +1,41 @@
+// Package secret keeps credentials out of the config file, in the system
+// keyring or in a file encrypted with a passphrase.
+package secret
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Backends a credential can be stored in
+const (
+	Keyring = "keyring" // Secret Service (libsecret) via secret-tool
+	File    = "file"    // AES-GCM encrypted file keyed from a passphrase
+)
+
+// ErrNotFound is returned when the store has no secret for the account
+var ErrNotFound = errors.New("secret not found")
+
+// Store holds secrets by account name, e.g. "default" or a profile name
+type Store interface {
+	Get(account string) (string, error)
+	Set(account, secret string) error
+	Delete(account string) error
+	Description() string // e.g. "system keyring (Secret Service)"
+}
+
+// Ref returns the reference kept in the config in place of a secret, such
+// as "keyring:default"
+func Ref(backend, account string) string {
+	return backend + ":" + account
+}
+
+// ParseRef splits a reference into its backend and account
+func ParseRef(ref string) (backend, account string, err error) {
+	backend, account, ok := strings.Cut(ref, ":")
+	if !ok || account == "" || (backend != Keyring && backend != File) {
+		return "", "", fmt.Errorf("invalid secret reference %q (use keyring:<name> or file:<name>)", ref)
+	}
+	return backend, account, nil
+}
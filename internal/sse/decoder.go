@@ -0,0 +1,158 @@
+package sse
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Frame is a single dispatched event from a text/event-stream
+type Frame struct {
+	Event string // Event name, "message" when the stream did not set one
+	Data  string // Data lines joined with "\n"
+	ID    string // Last event ID in effect when the frame was dispatched
+}
+
+// Decoder reads frames from an event stream following the WHATWG
+// server-sent events parsing rules. Lines may be any length.
+type Decoder struct {
+	r           *bufio.Reader
+	started     bool   // BOM check done
+	skipLF      bool   // previous line ended in CR, swallow a following LF
+	idBuffer    string // id: of the event being read, committed when it ends
+	lastEventID string
+	retry       time.Duration
+}
+
+// NewDecoder creates a Decoder reading from r
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: bufio.NewReader(r)}
+}
+
+// LastEventID returns the id: value in effect after the last complete
+// event. An id: in an event cut off by the stream ending is not included,
+// so resuming from it does not skip that event.
+func (d *Decoder) LastEventID() string {
+	return d.lastEventID
+}
+
+// Retry returns the most recent retry: hint, or 0 if none was sent
+func (d *Decoder) Retry() time.Duration {
+	return d.retry
+}
+
+// Next returns the next dispatched frame. It returns io.EOF when the
+// stream ends; a trailing event without a blank line is discarded.
+func (d *Decoder) Next() (*Frame, error) {
+	var data strings.Builder
+	hasData := false
+	eventType := ""
+
+	for {
+		line, err := d.readLine()
+		if err != nil {
+			return nil, err
+		}
+
+		// Blank line dispatches the pending event
+		if line == "" {
+			d.lastEventID = d.idBuffer
+			if !hasData {
+				eventType = ""
+				continue
+			}
+			frame := &Frame{
+				Event: eventType,
+				Data:  strings.TrimSuffix(data.String(), "\n"),
+				ID:    d.lastEventID,
+			}
+			if frame.Event == "" {
+				frame.Event = "message"
+			}
+			return frame, nil
+		}
+
+		// Comment
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+
+		field, value := line, ""
+		if i := strings.IndexByte(line, ':'); i >= 0 {
+			field = line[:i]
+			value = strings.TrimPrefix(line[i+1:], " ")
+		}
+
+		switch field {
+		case "event":
+			eventType = value
+		case "data":
+			data.WriteString(value)
+			data.WriteByte('\n')
+			hasData = true
+		case "id":
+			if !strings.ContainsRune(value, 0) {
+				d.idBuffer = value
+			}
+		case "retry":
+			if !isDigits(value) {
+				break
+			}
+			if ms, err := strconv.Atoi(value); err == nil {
+				d.retry = time.Duration(ms) * time.Millisecond
+			}
+		}
+		// Unknown fields are ignored
+	}
+}
+
+// readLine reads one line terminated by CRLF, LF or CR, without the terminator
+func (d *Decoder) readLine() (string, error) {
+	if !d.started {
+		d.started = true
+		if b, err := d.r.Peek(3); err == nil && bytes.Equal(b, []byte{0xEF, 0xBB, 0xBF}) {
+			d.r.Discard(3)
+		}
+	}
+
+	var line []byte
+	for {
+		b, err := d.r.ReadByte()
+		if err != nil {
+			// An unterminated final line is not a complete line
+			return "", err
+		}
+
+		if d.skipLF {
+			d.skipLF = false
+			if b == '\n' {
+				continue
+			}
+		}
+
+		switch b {
+		case '\n':
+			return string(line), nil
+		case '\r':
+			d.skipLF = true
+			return string(line), nil
+		}
+		line = append(line, b)
+	}
+}
+
+// isDigits reports whether s is a non-empty run of ASCII digits
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,197 @@
+package sse
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// decodeAll reads every dispatched frame until the stream ends
+func decodeAll(t *testing.T, stream string) ([]Frame, *Decoder) {
+	t.Helper()
+	d := NewDecoder(strings.NewReader(stream))
+	var frames []Frame
+	for {
+		f, err := d.Next()
+		if errors.Is(err, io.EOF) {
+			return frames, d
+		}
+		if err != nil {
+			t.Fatalf("Next: %v", err)
+		}
+		frames = append(frames, *f)
+	}
+}
+
+func TestDecoder(t *testing.T) {
+	long := strings.Repeat("x", 64*1024) // Several times bufio's default buffer
+
+	tests := []struct {
+		name   string
+		stream string
+		want   []Frame
+		lastID string
+		retry  time.Duration
+	}{
+		{
+			name:   "LF line endings",
+			stream: "event: connected\ndata: {}\n\n",
+			want:   []Frame{{Event: "connected", Data: "{}"}},
+		},
+		{
+			name:   "CRLF line endings",
+			stream: "id: 1\r\ndata: hello\r\n\r\ndata: world\r\n\r\n",
+			want:   []Frame{{Event: "message", Data: "hello", ID: "1"}, {Event: "message", Data: "world", ID: "1"}},
+			lastID: "1",
+		},
+		{
+			name:   "lone CR line endings",
+			stream: "event: reply\rdata: yes\r\rdata: no\r\r",
+			want:   []Frame{{Event: "reply", Data: "yes"}, {Event: "message", Data: "no"}},
+		},
+		{
+			name:   "multi-line data",
+			stream: "data: {\"type\":\"message\",\ndata: \"content\":\"yes\"}\n\n",
+			want:   []Frame{{Event: "message", Data: "{\"type\":\"message\",\n\"content\":\"yes\"}"}},
+		},
+		{
+			name:   "data without space and empty data line",
+			stream: "data:a\ndata\ndata: b\n\n",
+			want:   []Frame{{Event: "message", Data: "a\n\nb"}},
+		},
+		{
+			name:   "id containing NUL is ignored",
+			stream: "id: 7\ndata: one\n\nid: 8\x009\ndata: two\n\n",
+			want:   []Frame{{Event: "message", Data: "one", ID: "7"}, {Event: "message", Data: "two", ID: "7"}},
+			lastID: "7",
+		},
+		{
+			name:   "retry that isn't a number is ignored",
+			stream: "retry: 250\nretry: soon\nretry: 12ms\nretry: -5\ndata: x\n\n",
+			want:   []Frame{{Event: "message", Data: "x"}},
+			retry:  250 * time.Millisecond,
+		},
+		{
+			name:   "leading BOM",
+			stream: "\xEF\xBB\xBFdata: first\n\n",
+			want:   []Frame{{Event: "message", Data: "first"}},
+		},
+		{
+			name:   "BOM only at the start",
+			stream: "data: a\n\n\xEF\xBB\xBFdata: b\n\n",
+			want:   []Frame{{Event: "message", Data: "a"}},
+		},
+		{
+			name:   "comment lines",
+			stream: ": keepalive\n:\ndata: yes\n: between\n\n",
+			want:   []Frame{{Event: "message", Data: "yes"}},
+		},
+		{
+			name:   "lines longer than the buffer",
+			stream: "data: " + long + "\n\n",
+			want:   []Frame{{Event: "message", Data: long}},
+		},
+		{
+			name:   "event without data is not dispatched",
+			stream: "event: ping\n\ndata: after\n\n",
+			want:   []Frame{{Event: "message", Data: "after"}},
+		},
+		{
+			name:   "final event without blank line is not dispatched",
+			stream: "data: complete\n\ndata: partial\n",
+			want:   []Frame{{Event: "message", Data: "complete"}},
+		},
+		{
+			name:   "id of a cut-off event is not committed",
+			stream: "id: 5\ndata: complete\n\nid: 6\ndata: partial\n",
+			want:   []Frame{{Event: "message", Data: "complete", ID: "5"}},
+			lastID: "5",
+		},
+		{
+			name:   "unterminated final line is not dispatched",
+			stream: "data: complete\n\ndata: partial",
+			want:   []Frame{{Event: "message", Data: "complete"}},
+		},
+		{
+			name:   "unknown fields are ignored",
+			stream: "foo: bar\ndata: x\n\n",
+			want:   []Frame{{Event: "message", Data: "x"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			frames, d := decodeAll(t, tt.stream)
+			if len(frames) != len(tt.want) {
+				t.Fatalf("got %d frames %q, want %d", len(frames), frames, len(tt.want))
+			}
+			for i := range frames {
+				if frames[i] != tt.want[i] {
+					t.Errorf("frame %d = %+v, want %+v", i, frames[i], tt.want[i])
+				}
+			}
+			if d.LastEventID() != tt.lastID {
+				t.Errorf("LastEventID = %q, want %q", d.LastEventID(), tt.lastID)
+			}
+			if d.Retry() != tt.retry {
+				t.Errorf("Retry = %v, want %v", d.Retry(), tt.retry)
+			}
+		})
+	}
+}
+
+// TestDecoderRecordedStream replays a ChatBridge stream as captured: a
+// connected event, keepalive comments, then a reply split across reads
+func TestDecoderRecordedStream(t *testing.T) {
+	stream := "retry: 3000\r\n" +
+		"id: 41\r\nevent: connected\r\ndata: {\"session\":\"afk-s1\"}\r\n\r\n" +
+		": keepalive\r\n\r\n" +
+		"id: 42\r\n" +
+		"data: {\"type\":\"message\",\"from\":\"dev\",\r\n" +
+		"data: \"content\":\"Option B\"}\r\n\r\n"
+
+	frames, d := decodeAll(t, stream)
+	want := []Frame{
+		{Event: "connected", Data: `{"session":"afk-s1"}`, ID: "41"},
+		{Event: "message", Data: "{\"type\":\"message\",\"from\":\"dev\",\n\"content\":\"Option B\"}", ID: "42"},
+	}
+	if len(frames) != len(want) {
+		t.Fatalf("got %d frames, want %d: %q", len(frames), len(want), frames)
+	}
+	for i := range want {
+		if frames[i] != want[i] {
+			t.Errorf("frame %d = %+v, want %+v", i, frames[i], want[i])
+		}
+	}
+	if d.LastEventID() != "42" || d.Retry() != 3*time.Second {
+		t.Errorf("LastEventID = %q, Retry = %v", d.LastEventID(), d.Retry())
+	}
+}
+
+// oneByteReader returns a byte per Read, so CRLF pairs arrive split
+type oneByteReader struct{ r io.Reader }
+
+func (o oneByteReader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	return o.r.Read(p[:1])
+}
+
+func TestDecoderSplitReads(t *testing.T) {
+	d := NewDecoder(oneByteReader{strings.NewReader("data: a\r\n\r\ndata: b\r\r")})
+	for _, want := range []string{"a", "b"} {
+		f, err := d.Next()
+		if err != nil {
+			t.Fatalf("Next: %v", err)
+		}
+		if f.Data != want {
+			t.Errorf("Data = %q, want %q", f.Data, want)
+		}
+	}
+	if _, err := d.Next(); !errors.Is(err, io.EOF) {
+		t.Errorf("Next after end = %v, want io.EOF", err)
+	}
+}
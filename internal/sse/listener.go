@@ -1,13 +1,15 @@
 package sse
 
 import (
-	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
-	"strings"
 	"time"
+
+	"github.com/davedotdev/afk/internal/backoff"
 )
 
 // Event represents an SSE event from ChatBridge
@@ -43,12 +45,17 @@ type ResponseHandler func(event *Event)
 // ReminderHandler is called at reminder intervals
 type ReminderHandler func(elapsed, remaining time.Duration)
 
+// ReconnectHandler is called before each reconnect attempt with the attempt
+// number, the delay before it, and the error that ended the last connection
+type ReconnectHandler func(attempt int, delay time.Duration, err error)
+
 // ListenOptions configures the listener behavior
 type ListenOptions struct {
 	Timeout          time.Duration
 	ReminderInterval time.Duration
 	OnEvent          ResponseHandler
 	OnReminder       ReminderHandler
+	OnReconnect      ReconnectHandler
 }
 
 // Listen connects to the SSE endpoint and waits for responses
@@ -60,43 +67,17 @@ func (l *Listener) Listen(ctx context.Context, sessionID string, timeout time.Du
 	})
 }
 
-// ListenWithOptions connects to the SSE endpoint with full configuration
+// ListenWithOptions connects to the SSE endpoint with full configuration.
+// Dropped connections are retried with exponential backoff and jitter,
+// resuming from the last seen event ID, until a message arrives or the
+// overall Timeout expires.
 func (l *Listener) ListenWithOptions(ctx context.Context, sessionID string, opts ListenOptions) (*Event, error) {
-	url := fmt.Sprintf("%s/api/events/%s", l.BaseURL, sessionID)
 	startTime := time.Now()
 
-	// Create context with timeout
+	// Create context with timeout - covers every reconnect attempt
 	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
 	defer cancel()
 
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	req.Header.Set("Accept", "text/event-stream")
-	req.Header.Set("X-API-Key", l.APIKey)
-	req.Header.Set("Cache-Control", "no-cache")
-
-	resp, err := l.Client.Do(req)
-	if err != nil {
-		if ctx.Err() == context.DeadlineExceeded {
-			return nil, fmt.Errorf("timeout waiting for response")
-		}
-		if ctx.Err() == context.Canceled {
-			return nil, fmt.Errorf("cancelled")
-		}
-		return nil, fmt.Errorf("connection failed: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode == http.StatusUnauthorized {
-		return nil, fmt.Errorf("unauthorized: invalid API key")
-	}
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API error: %s", resp.Status)
-	}
-
 	// Set up reminder ticker if configured
 	var reminderTicker *time.Ticker
 	var reminderChan <-chan time.Time
@@ -106,63 +87,62 @@ func (l *Listener) ListenWithOptions(ctx context.Context, sessionID string, opts
 		defer reminderTicker.Stop()
 	}
 
-	// Create channels for SSE events
-	eventChan := make(chan *Event, 1)
-	errChan := make(chan error, 1)
+	state := &streamState{}
+	results := make(chan streamResult, 1)
+	connect := func() {
+		go func() {
+			event, connected, err := l.stream(ctx, sessionID, state)
+			results <- streamResult{event: event, connected: connected, err: err}
+		}()
+	}
+	connect()
 
-	// Read SSE in goroutine
-	go func() {
-		scanner := bufio.NewScanner(resp.Body)
-		for scanner.Scan() {
-			line := scanner.Text()
+	var retryTimer *time.Timer
+	var retryChan <-chan time.Time
+	defer func() {
+		if retryTimer != nil {
+			retryTimer.Stop()
+		}
+	}()
 
-			// Skip empty lines and comments
-			if line == "" || strings.HasPrefix(line, ":") {
-				continue
-			}
+	attempt := 0
 
-			// Handle event type
-			if strings.HasPrefix(line, "event:") {
-				eventType := strings.TrimSpace(strings.TrimPrefix(line, "event:"))
-				if eventType == "connected" {
-					continue
+	// Wait for event, reminder, reconnect, or context done
+	for {
+		select {
+		case res := <-results:
+			if res.event != nil {
+				if opts.OnEvent != nil {
+					opts.OnEvent(res.event)
 				}
+				return res.event, nil
 			}
 
-			// Handle data
-			if strings.HasPrefix(line, "data:") {
-				data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
-
-				var event Event
-				if err := json.Unmarshal([]byte(data), &event); err != nil {
-					continue
-				}
+			if ctx.Err() != nil {
+				return nil, contextError(ctx)
+			}
 
-				if event.Type == "message" && event.Content != "" {
-					eventChan <- &event
-					return
-				}
+			var perm *permanentError
+			if errors.As(res.err, &perm) {
+				return nil, perm.err
 			}
-		}
 
-		if err := scanner.Err(); err != nil {
-			errChan <- err
-		} else {
-			errChan <- fmt.Errorf("connection closed without response")
-		}
-	}()
+			// A stream that got as far as 200 OK resets the backoff
+			if res.connected {
+				attempt = 0
+			}
+			attempt++
 
-	// Wait for event, reminder, or context done
-	for {
-		select {
-		case event := <-eventChan:
-			if opts.OnEvent != nil {
-				opts.OnEvent(event)
+			delay := backoff.Delay(attempt, state.retry)
+			if opts.OnReconnect != nil {
+				opts.OnReconnect(attempt, delay, res.err)
 			}
-			return event, nil
+			retryTimer = time.NewTimer(delay)
+			retryChan = retryTimer.C
 
-		case err := <-errChan:
-			return nil, err
+		case <-retryChan:
+			retryChan = nil
+			connect()
 
 		case <-reminderChan:
 			elapsed := time.Since(startTime)
@@ -173,12 +153,112 @@ func (l *Listener) ListenWithOptions(ctx context.Context, sessionID string, opts
 			opts.OnReminder(elapsed, remaining)
 
 		case <-ctx.Done():
-			if ctx.Err() == context.DeadlineExceeded {
-				return nil, fmt.Errorf("timeout waiting for response")
-			}
-			return nil, fmt.Errorf("cancelled")
+			return nil, contextError(ctx)
+		}
+	}
+}
+
+// streamState carries resume information across reconnects. Only one
+// connection runs at a time, so it needs no locking.
+type streamState struct {
+	lastEventID string
+	retry       time.Duration // server-supplied reconnection delay
+}
+
+// streamResult is the outcome of a single SSE connection
+type streamResult struct {
+	event     *Event
+	connected bool
+	err       error
+}
+
+// permanentError marks failures that reconnecting cannot fix (e.g. bad API key)
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// stream makes one connection to the SSE endpoint and reads it until a
+// message arrives or the connection ends. connected reports whether the
+// server accepted the request.
+func (l *Listener) stream(ctx context.Context, sessionID string, state *streamState) (*Event, bool, error) {
+	url := fmt.Sprintf("%s/api/events/%s", l.BaseURL, sessionID)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, false, &permanentError{fmt.Errorf("failed to create request: %w", err)}
+	}
+
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set("X-API-Key", l.APIKey)
+	req.Header.Set("Cache-Control", "no-cache")
+
+	if state.lastEventID != "" {
+		req.Header.Set("Last-Event-ID", state.lastEventID)
+	}
+
+	resp, err := l.Client.Do(req)
+	if err != nil {
+		return nil, false, fmt.Errorf("connection failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized:
+		return nil, false, &permanentError{fmt.Errorf("unauthorized: invalid API key")}
+	case resp.StatusCode == http.StatusOK:
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return nil, false, fmt.Errorf("API error: %s", resp.Status)
+	default:
+		return nil, false, &permanentError{fmt.Errorf("API error: %s", resp.Status)}
+	}
+
+	dec := NewDecoder(resp.Body)
+	for {
+		frame, err := dec.Next()
+
+		// Keep resume information even from a failed read
+		state.lastEventID = dec.LastEventID()
+		if r := dec.Retry(); r > 0 {
+			state.retry = r
+		}
+
+		if err == io.EOF {
+			return nil, true, fmt.Errorf("connection closed without response")
 		}
+		if err != nil {
+			return nil, true, err
+		}
+
+		if frame.Event == "connected" {
+			continue
+		}
+
+		var event Event
+		if err := json.Unmarshal([]byte(frame.Data), &event); err != nil {
+			continue
+		}
+
+		if event.Type == "message" && event.Content != "" {
+			return &event, true, nil
+		}
+	}
+}
+
+// Errors returned when the wait ends without a message
+var (
+	ErrTimeout   = errors.New("timeout waiting for response")
+	ErrCancelled = errors.New("cancelled")
+)
+
+// contextError maps a finished context to the listener's errors
+func contextError(ctx context.Context) error {
+	if ctx.Err() == context.DeadlineExceeded {
+		return ErrTimeout
 	}
+	return ErrCancelled
 }
 
 // FormatTimestamp formats a Unix timestamp for display
@@ -0,0 +1,79 @@
+package sse
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestListenResumesAfterDrop(t *testing.T) {
+	const retry = 150 * time.Millisecond
+
+	var mu sync.Mutex
+	var lastEventIDs []string
+	var connectedAt []time.Time
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-API-Key") != "key" || r.URL.Path != "/api/events/sess-1" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		n := len(lastEventIDs)
+		lastEventIDs = append(lastEventIDs, r.Header.Get("Last-Event-ID"))
+		connectedAt = append(connectedAt, time.Now())
+		mu.Unlock()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		if n == 0 {
+			// A status event, then the stream drops partway into event 6,
+			// which the resumed request must ask for again
+			fmt.Fprintf(w, "retry: %d\n\nevent: connected\ndata: {}\n\n", retry.Milliseconds())
+			fmt.Fprint(w, "id: 5\ndata: {\"type\":\"delivered\",\"session_id\":\"sess-1\"}\n\n")
+			fmt.Fprint(w, "id: 6\ndata: {\"type\":\"mess")
+			return
+		}
+		fmt.Fprint(w, "id: 6\ndata: {\"type\":\"message\",\"session_id\":\"sess-1\",\"from\":\"dev\",\"content\":\"yes\"}\n\n")
+	}))
+	defer server.Close()
+
+	var delays []time.Duration
+	l := NewListener(server.URL, "key")
+	event, err := l.ListenWithOptions(context.Background(), "sess-1", ListenOptions{
+		Timeout:     5 * time.Second,
+		OnReconnect: func(attempt int, delay time.Duration, err error) { delays = append(delays, delay) },
+	})
+	if err != nil {
+		t.Fatalf("ListenWithOptions: %v", err)
+	}
+	if event.Content != "yes" || event.From != "dev" {
+		t.Errorf("event = %+v", event)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(lastEventIDs) != 2 || lastEventIDs[0] != "" || lastEventIDs[1] != "5" {
+		t.Fatalf("Last-Event-ID per connection = %q, want [\"\" \"5\"]", lastEventIDs)
+	}
+	if len(delays) != 1 || delays[0] < retry {
+		t.Errorf("reconnect delays = %v, want one of at least the %v hint", delays, retry)
+	}
+	if gap := connectedAt[1].Sub(connectedAt[0]); gap < retry {
+		t.Errorf("reconnected after %v, before the %v hint", gap, retry)
+	}
+}
+
+func TestListenPermanentError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	_, err := NewListener(server.URL, "wrong").Listen(context.Background(), "sess-1", 5*time.Second, nil)
+	if err == nil || err.Error() != "unauthorized: invalid API key" {
+		t.Errorf("Listen = %v, want the unauthorized error without retrying", err)
+	}
+}
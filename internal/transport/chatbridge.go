@@ -0,0 +1,148 @@
+package transport
+
+import (
+	"context"
+	"errors"
+
+	"github.com/davedotdev/afk/internal/api"
+	"github.com/davedotdev/afk/internal/config"
+	"github.com/davedotdev/afk/internal/sse"
+)
+
+func init() {
+	Register(Registration{
+		Name:         "sms",
+		Service:      "ChatBridge",
+		Usage:        "Send message via SMS",
+		New:          chatBridgeFactory("SMS"),
+		Configured:   chatBridgeConfigured,
+		SetRecipient: chatBridgeRecipient,
+		Destination:  chatBridgeDestination,
+	})
+	Register(Registration{
+		Name:         "whatsapp",
+		Service:      "ChatBridge",
+		Usage:        "Send message via WhatsApp",
+		New:          chatBridgeFactory("WhatsApp"),
+		Configured:   chatBridgeConfigured,
+		SetRecipient: chatBridgeRecipient,
+		Destination:  chatBridgeDestination,
+	})
+}
+
+func chatBridgeFactory(channel string) Factory {
+	return func(cfg *config.Config) (Transport, error) {
+		t, err := NewChatBridge(cfg, channel)
+		if err != nil {
+			return nil, err
+		}
+		return t, nil
+	}
+}
+
+func chatBridgeConfigured(cfg *config.Config) bool {
+	return cfg.APIKey != ""
+}
+
+// chatBridgeRecipient rejects contacts: ChatBridge messages the phone
+// registered with the API key, so others need a recipient entry with
+// their own key
+func chatBridgeRecipient(cfg *config.Config, contact config.Contact) error {
+	return errors.New("SMS and WhatsApp only reach the phone registered with the API key (add them to \"recipients\" with their own api_key)")
+}
+
+// chatBridgeDestination identifies the phone by the API key it is
+// registered with
+func chatBridgeDestination(cfg *config.Config) string {
+	return cfg.APIKey
+}
+
+// ChatBridge sends SMS or WhatsApp messages through the ChatBridge API and
+// waits for replies on its SSE endpoint
+type ChatBridge struct {
+	channel  string // "SMS" or "WhatsApp"
+	client   *api.Client
+	listener *sse.Listener
+}
+
+// NewChatBridge creates a ChatBridge transport for the given channel
+func NewChatBridge(cfg *config.Config, channel string) (*ChatBridge, error) {
+	if cfg.APIKey == "" {
+		return nil, errors.New("not logged in: run 'afk login' first")
+	}
+	return &ChatBridge{
+		channel:  channel,
+		client:   api.NewClient(cfg.APIURL, cfg.APIKey),
+		listener: sse.NewListener(cfg.APIURL, cfg.APIKey),
+	}, nil
+}
+
+// Name returns "SMS" or "WhatsApp"
+func (c *ChatBridge) Name() string {
+	return c.channel
+}
+
+// Send sends the message. The server generates the session ID.
+func (c *ChatBridge) Send(ctx context.Context, msg Message) (*Receipt, error) {
+	var resp *api.SendMessageResponse
+	var err error
+
+	if c.channel == "SMS" {
+		resp, err = c.client.SendSMS(msg.Text, "")
+	} else {
+		resp, err = c.client.SendWhatsApp(msg.Text, "", msg.SysName)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Server always generates and returns the session ID for security
+	if resp == nil || resp.SessionID == "" {
+		return nil, errors.New("Server did not return session ID")
+	}
+
+	return &Receipt{SessionID: resp.SessionID, MessageID: resp.MessageID}, nil
+}
+
+// Listen waits for the reply on the session's event stream
+func (c *ChatBridge) Listen(ctx context.Context, sessionID string, opts ListenOptions) (*Reply, error) {
+	event, err := c.listener.ListenWithOptions(ctx, sessionID, sse.ListenOptions{
+		Timeout:          opts.Timeout,
+		ReminderInterval: opts.ReminderInterval,
+		OnReminder:       sse.ReminderHandler(opts.OnReminder),
+		OnReconnect:      sse.ReconnectHandler(opts.OnReconnect),
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, sse.ErrTimeout):
+			return nil, ErrTimeout
+		case errors.Is(err, sse.ErrCancelled):
+			return nil, ErrCancelled
+		}
+		return nil, err
+	}
+
+	// Replies through the web link are labelled separately
+	channel := c.channel
+	if event.From == "web" {
+		channel = "Web"
+	}
+
+	return &Reply{
+		SessionID: sessionID,
+		From:      event.From,
+		Channel:   channel,
+		Content:   event.Content,
+	}, nil
+}
+
+// Health checks that the ChatBridge API is reachable
+func (c *ChatBridge) Health(ctx context.Context) error {
+	_, err := c.client.Health()
+	return err
+}
+
+// Validate checks the API key
+func (c *ChatBridge) Validate(ctx context.Context) error {
+	return c.client.ValidateKey()
+}
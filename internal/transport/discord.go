@@ -0,0 +1,496 @@
+package transport
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/davedotdev/afk/internal/backoff"
+	"github.com/davedotdev/afk/internal/config"
+	"github.com/davedotdev/afk/internal/ws"
+)
+
+func init() {
+	Register(Registration{
+		Name:    "discord",
+		Service: "Discord",
+		Usage:   "Send message via Discord direct message",
+		New: func(cfg *config.Config) (Transport, error) {
+			t, err := NewDiscord(cfg)
+			if err != nil {
+				return nil, err
+			}
+			return t, nil
+		},
+		Configured: func(cfg *config.Config) bool { return cfg.DiscordBotToken != "" },
+		SetRecipient: func(cfg *config.Config, contact config.Contact) error {
+			return setAddress(&cfg.DiscordUserID, contact.Discord, "Discord user ID")
+		},
+		Destination: func(cfg *config.Config) string { return cfg.DiscordUserID },
+	})
+}
+
+// discordIntents subscribes to direct message events only. Message content
+// in DMs with the bot needs no privileged intent.
+const discordIntents = 1 << 12
+
+// discordUserAgent is the User-Agent format the Discord API requires
+const discordUserAgent = "DiscordBot (https://github.com/packetpipe/afk, 1.0)"
+
+// Gateway opcodes
+const (
+	discordOpDispatch       = 0
+	discordOpHeartbeat      = 1
+	discordOpIdentify       = 2
+	discordOpReconnect      = 7
+	discordOpInvalidSession = 9
+	discordOpHello          = 10
+	discordOpHeartbeatACK   = 11
+)
+
+// Reasons to reconnect to the Gateway straight away: Discord asked, or a
+// heartbeat went unacknowledged, so the connection is presumed dead
+var (
+	errGatewayReconnect = errors.New("gateway reconnect requested")
+	errGatewayZombie    = errors.New("gateway did not acknowledge a heartbeat")
+)
+
+// Discord sends a direct message to a user with a bot token and waits for
+// the next DM from them over the Gateway websocket
+type Discord struct {
+	apiURL string
+	token  string
+	userID string
+	client *http.Client
+}
+
+// NewDiscord creates a Discord transport from the config
+func NewDiscord(cfg *config.Config) (*Discord, error) {
+	if cfg.DiscordBotToken == "" || cfg.DiscordUserID == "" {
+		return nil, errors.New("discord not configured: set discord_bot_token and discord_user_id in " + config.Path())
+	}
+	return &Discord{
+		apiURL: strings.TrimRight(cfg.DiscordAPIURL, "/"),
+		token:  cfg.DiscordBotToken,
+		userID: cfg.DiscordUserID,
+		client: &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+// Name returns "Discord"
+func (d *Discord) Name() string {
+	return "Discord"
+}
+
+// discordMessage is a message from the REST API or a MESSAGE_CREATE event
+type discordMessage struct {
+	ID        string `json:"id"`
+	ChannelID string `json:"channel_id"`
+	Content   string `json:"content"`
+	Author    struct {
+		ID         string `json:"id"`
+		Username   string `json:"username"`
+		GlobalName string `json:"global_name"`
+		Bot        bool   `json:"bot"`
+	} `json:"author"`
+	Reference *struct {
+		MessageID string `json:"message_id"`
+	} `json:"message_reference"`
+}
+
+// discordAPIError is a non-2xx REST response
+type discordAPIError struct {
+	path    string
+	status  int
+	message string
+}
+
+func (e *discordAPIError) Error() string {
+	if e.message == "" {
+		return fmt.Sprintf("discord %s: %d %s", e.path, e.status, http.StatusText(e.status))
+	}
+	return fmt.Sprintf("discord %s: %s", e.path, e.message)
+}
+
+// discordRateLimited is a 429 response
+type discordRateLimited struct {
+	retryAfter time.Duration
+}
+
+func (e *discordRateLimited) Error() string {
+	return fmt.Sprintf("discord rate limited, retry after %s", e.retryAfter)
+}
+
+// Send opens (or reuses) the DM channel with the user and posts the message
+func (d *Discord) Send(ctx context.Context, msg Message) (*Receipt, error) {
+	var dm struct {
+		ID string `json:"id"`
+	}
+	err := d.do(ctx, "POST", "/users/@me/channels", map[string]string{"recipient_id": d.userID}, &dm)
+	if err != nil {
+		return nil, err
+	}
+
+	text := msg.Text
+	if msg.SysName != "" {
+		text = fmt.Sprintf("**%s:**\n%s", msg.SysName, msg.Text)
+	}
+
+	var sent discordMessage
+	if err := d.do(ctx, "POST", "/channels/"+dm.ID+"/messages", map[string]string{"content": text}, &sent); err != nil {
+		return nil, err
+	}
+
+	return &Receipt{
+		SessionID: "discord-" + dm.ID + "-" + sent.ID,
+		MessageID: sent.ID,
+	}, nil
+}
+
+// Listen waits on the Gateway for the next DM from the user, reconnecting
+// when the connection drops
+func (d *Discord) Listen(ctx context.Context, sessionID string, opts ListenOptions) (*Reply, error) {
+	channel, messageID, err := parseDiscordSession(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	return wait(ctx, opts, func(ctx context.Context) (*Reply, error) {
+		attempt := 0
+		for {
+			reply, connected, err := d.gatewaySession(ctx, sessionID, channel, messageID)
+			if reply != nil || isDiscordPermanent(err) {
+				return reply, err
+			}
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+
+			if errors.Is(err, errGatewayReconnect) || errors.Is(err, errGatewayZombie) {
+				attempt = 0
+				continue
+			}
+
+			if connected {
+				attempt = 0
+			}
+			attempt++
+			var retryAfter time.Duration
+			var limited *discordRateLimited
+			if errors.As(err, &limited) {
+				retryAfter = limited.retryAfter
+			}
+			delay := backoff.Delay(attempt, retryAfter)
+			if opts.OnReconnect != nil {
+				opts.OnReconnect(attempt, delay, err)
+			}
+			if !sleep(ctx, delay) {
+				return nil, ctx.Err()
+			}
+		}
+	})
+}
+
+// Health checks that the Discord API is reachable
+func (d *Discord) Health(ctx context.Context) error {
+	var gw struct {
+		URL string `json:"url"`
+	}
+	return d.do(ctx, "GET", "/gateway", nil, &gw)
+}
+
+// Validate checks the bot token
+func (d *Discord) Validate(ctx context.Context) error {
+	var me struct {
+		ID string `json:"id"`
+	}
+	return d.do(ctx, "GET", "/users/@me", nil, &me)
+}
+
+// gatewaySession opens one Gateway connection and reads events until a
+// reply arrives or the connection ends. Once the session is ready, DMs
+// sent while disconnected are fetched over REST.
+func (d *Discord) gatewaySession(ctx context.Context, sessionID, channel, messageID string) (*Reply, bool, error) {
+	var gw struct {
+		URL string `json:"url"`
+	}
+	if err := d.do(ctx, "GET", "/gateway/bot", nil, &gw); err != nil {
+		return nil, false, err
+	}
+
+	conn, err := ws.Dial(ctx, strings.TrimRight(gw.URL, "/")+"/?v=10&encoding=json", nil)
+	if err != nil {
+		return nil, false, err
+	}
+	defer conn.Close()
+
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	var seq atomic.Int64
+	seq.Store(-1)
+	heartbeat := func() error {
+		payload := []byte(`{"op":1,"d":null}`)
+		if s := seq.Load(); s >= 0 {
+			payload = []byte(fmt.Sprintf(`{"op":1,"d":%d}`, s))
+		}
+		return conn.WriteMessage(ws.TextMessage, payload)
+	}
+
+	// A scheduled heartbeat sent before the last one was acknowledged
+	// means a half-open connection: close it so the read below fails
+	var acked, zombie atomic.Bool
+	acked.Store(true)
+	beat := func() error {
+		if !acked.Swap(false) {
+			zombie.Store(true)
+			conn.Close()
+			return errGatewayZombie
+		}
+		return heartbeat()
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	connected := false
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			if zombie.Load() {
+				err = errGatewayZombie
+			}
+			return nil, connected, err
+		}
+
+		var event struct {
+			Op   int             `json:"op"`
+			Seq  *int64          `json:"s"`
+			Type string          `json:"t"`
+			Data json.RawMessage `json:"d"`
+		}
+		if err := json.Unmarshal(data, &event); err != nil {
+			continue
+		}
+		if event.Seq != nil {
+			seq.Store(*event.Seq)
+		}
+
+		switch event.Op {
+		case discordOpHello:
+			var hello struct {
+				HeartbeatInterval int64 `json:"heartbeat_interval"`
+			}
+			json.Unmarshal(event.Data, &hello)
+			go d.heartbeatLoop(time.Duration(hello.HeartbeatInterval)*time.Millisecond, beat, done)
+
+			identify, _ := json.Marshal(map[string]interface{}{
+				"op": discordOpIdentify,
+				"d": map[string]interface{}{
+					"token":   d.token,
+					"intents": discordIntents,
+					"properties": map[string]string{
+						"os":      "linux",
+						"browser": "afk",
+						"device":  "afk",
+					},
+				},
+			})
+			if err := conn.WriteMessage(ws.TextMessage, identify); err != nil {
+				return nil, connected, err
+			}
+
+		case discordOpHeartbeat:
+			if err := heartbeat(); err != nil {
+				return nil, connected, err
+			}
+
+		case discordOpHeartbeatACK:
+			acked.Store(true)
+
+		case discordOpReconnect:
+			return nil, connected, errGatewayReconnect
+
+		case discordOpInvalidSession:
+			return nil, connected, errors.New("discord gateway session invalidated")
+
+		case discordOpDispatch:
+			switch event.Type {
+			case "READY":
+				connected = true
+				reply, err := d.findReply(ctx, sessionID, channel, messageID)
+				if reply != nil || err != nil {
+					return reply, connected, err
+				}
+			case "MESSAGE_CREATE":
+				var m discordMessage
+				if json.Unmarshal(event.Data, &m) == nil && isDiscordReply(&m, channel, messageID) {
+					return d.reply(sessionID, &m), connected, nil
+				}
+			}
+		}
+	}
+}
+
+// heartbeatLoop sends heartbeats at the interval Discord asked for, the
+// first after a random fraction of it, until done is closed
+func (d *Discord) heartbeatLoop(interval time.Duration, beat func() error, done <-chan struct{}) {
+	if interval <= 0 {
+		return
+	}
+	timer := time.NewTimer(time.Duration(rand.Int63n(int64(interval))))
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+			if beat() != nil {
+				return
+			}
+			timer.Reset(interval)
+		case <-done:
+			return
+		}
+	}
+}
+
+// findReply looks for a DM from the user already sent after the message
+func (d *Discord) findReply(ctx context.Context, sessionID, channel, messageID string) (*Reply, error) {
+	params := url.Values{"after": {messageID}, "limit": {"50"}}
+	var messages []discordMessage
+	if err := d.do(ctx, "GET", "/channels/"+channel+"/messages?"+params.Encode(), nil, &messages); err != nil {
+		return nil, err
+	}
+	// Newest first; the earliest reply answers the question
+	for i := len(messages) - 1; i >= 0; i-- {
+		messages[i].ChannelID = channel
+		if isDiscordReply(&messages[i], channel, messageID) {
+			return d.reply(sessionID, &messages[i]), nil
+		}
+	}
+	return nil, nil
+}
+
+func (d *Discord) reply(sessionID string, m *discordMessage) *Reply {
+	from := m.Author.GlobalName
+	if from == "" {
+		from = m.Author.Username
+	}
+	return &Reply{
+		SessionID: sessionID,
+		From:      from,
+		Channel:   d.Name(),
+		Content:   m.Content,
+	}
+}
+
+// do calls a REST endpoint with the bot token
+func (d *Discord) do(ctx context.Context, method, path string, body interface{}, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to marshal request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, d.apiURL+path, reader)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Authorization", "Bot "+d.token)
+	req.Header.Set("User-Agent", discordUserAgent)
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("discord request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		var limited struct {
+			RetryAfter float64 `json:"retry_after"`
+		}
+		json.Unmarshal(data, &limited)
+		return &discordRateLimited{retryAfter: time.Duration(limited.RetryAfter * float64(time.Second))}
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var apiErr struct {
+			Message string `json:"message"`
+		}
+		json.Unmarshal(data, &apiErr)
+		endpoint, _, _ := strings.Cut(path, "?")
+		return &discordAPIError{path: endpoint, status: resp.StatusCode, message: apiErr.Message}
+	}
+
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	return nil
+}
+
+// isDiscordPermanent reports whether retrying cannot fix err: a 4xx REST
+// response other than 429, or a Gateway close for bad credentials or intents
+func isDiscordPermanent(err error) bool {
+	var apiErr *discordAPIError
+	if errors.As(err, &apiErr) {
+		return apiErr.status >= 400 && apiErr.status < 500
+	}
+	var closeErr *ws.CloseError
+	if errors.As(err, &closeErr) {
+		switch closeErr.Code {
+		case 4004, 4010, 4011, 4012, 4013, 4014:
+			return true
+		}
+	}
+	return false
+}
+
+// isDiscordReply reports whether m is a user's DM sent after messageID.
+// A message that replies to a different message belongs to another session.
+func isDiscordReply(m *discordMessage, channel, messageID string) bool {
+	if m.ChannelID != channel || m.Author.Bot || m.Content == "" {
+		return false
+	}
+	if m.Reference != nil && m.Reference.MessageID != "" && m.Reference.MessageID != messageID {
+		return false
+	}
+	return snowflakeAfter(m.ID, messageID)
+}
+
+// snowflakeAfter reports whether Discord ID a was created after b
+func snowflakeAfter(a, b string) bool {
+	an, errA := strconv.ParseUint(a, 10, 64)
+	bn, errB := strconv.ParseUint(b, 10, 64)
+	return errA == nil && errB == nil && an > bn
+}
+
+// parseDiscordSession splits "discord-<channel>-<message>" into its parts
+func parseDiscordSession(sessionID string) (channel, messageID string, err error) {
+	rest, ok := strings.CutPrefix(sessionID, "discord-")
+	if ok {
+		channel, messageID, ok = strings.Cut(rest, "-")
+	}
+	if !ok || channel == "" || messageID == "" {
+		return "", "", fmt.Errorf("invalid Discord session ID %q", sessionID)
+	}
+	return channel, messageID, nil
+}
@@ -0,0 +1,244 @@
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/davedotdev/afk/internal/config"
+	"github.com/davedotdev/afk/internal/ws"
+)
+
+// discordGatewayScript is what the stand-in does on one Gateway connection
+type discordGatewayScript struct {
+	ack    bool                     // Acknowledge heartbeats
+	events []map[string]interface{} // Dispatched after READY
+}
+
+// discordStandIn is a local Discord REST API and Gateway. Each Gateway
+// connection follows the next script in turn.
+type discordStandIn struct {
+	t        *testing.T
+	server   *httptest.Server
+	history  []map[string]interface{} // Channel messages returned over REST
+	scripts  []discordGatewayScript
+	interval int // Heartbeat interval in milliseconds
+
+	mu         sync.Mutex
+	posted     map[string]interface{} // Last message body posted
+	connects   int
+	heartbeats []int // Heartbeats received per connection
+}
+
+func newDiscordStandIn(t *testing.T) *discordStandIn {
+	s := &discordStandIn{t: t, interval: 40}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/users/@me/channels", func(w http.ResponseWriter, r *http.Request) {
+		if !s.authorized(w, r) {
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"id": "D100"})
+	})
+	mux.HandleFunc("/channels/D100/messages", func(w http.ResponseWriter, r *http.Request) {
+		if !s.authorized(w, r) {
+			return
+		}
+		if r.Method == "POST" {
+			s.mu.Lock()
+			json.NewDecoder(r.Body).Decode(&s.posted)
+			s.mu.Unlock()
+			json.NewEncoder(w).Encode(map[string]string{"id": "1000", "channel_id": "D100"})
+			return
+		}
+		history := s.history
+		if history == nil {
+			history = []map[string]interface{}{}
+		}
+		json.NewEncoder(w).Encode(history)
+	})
+	mux.HandleFunc("/gateway/bot", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"url": "ws" + strings.TrimPrefix(s.server.URL, "http") + "/gw"})
+	})
+	mux.HandleFunc("/gw/", s.gateway)
+	s.server = httptest.NewServer(mux)
+	t.Cleanup(s.server.Close)
+	return s
+}
+
+func (s *discordStandIn) authorized(w http.ResponseWriter, r *http.Request) bool {
+	if r.Header.Get("Authorization") != "Bot discord-test" {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]interface{}{"message": "401: Unauthorized", "code": 0})
+		return false
+	}
+	return true
+}
+
+// gateway says HELLO, waits for IDENTIFY, sends READY and the script's
+// events, then answers heartbeats until the client hangs up
+func (s *discordStandIn) gateway(w http.ResponseWriter, r *http.Request) {
+	conn, err := ws.Upgrade(w, r)
+	if err != nil {
+		s.t.Errorf("Upgrade: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	s.mu.Lock()
+	n := s.connects
+	s.connects++
+	s.heartbeats = append(s.heartbeats, 0)
+	s.mu.Unlock()
+	if n >= len(s.scripts) {
+		return
+	}
+	script := s.scripts[n]
+
+	send := func(v map[string]interface{}) {
+		data, _ := json.Marshal(v)
+		conn.WriteMessage(ws.TextMessage, data)
+	}
+	send(map[string]interface{}{"op": 10, "d": map[string]int{"heartbeat_interval": s.interval}})
+
+	for seq := 1; ; {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		var payload struct {
+			Op int `json:"op"`
+		}
+		json.Unmarshal(data, &payload)
+
+		switch payload.Op {
+		case 2: // IDENTIFY
+			send(map[string]interface{}{"op": 0, "s": seq, "t": "READY", "d": map[string]string{"session_id": "gw-session"}})
+			for _, event := range script.events {
+				seq++
+				send(map[string]interface{}{"op": 0, "s": seq, "t": "MESSAGE_CREATE", "d": event})
+			}
+		case 1: // HEARTBEAT
+			s.mu.Lock()
+			s.heartbeats[n]++
+			s.mu.Unlock()
+			if script.ack {
+				send(map[string]interface{}{"op": 11})
+			}
+		}
+	}
+}
+
+func (s *discordStandIn) transport(t *testing.T) *Discord {
+	t.Helper()
+	d, err := NewDiscord(&config.Config{DiscordBotToken: "discord-test", DiscordUserID: "U1", DiscordAPIURL: s.server.URL})
+	if err != nil {
+		t.Fatalf("NewDiscord: %v", err)
+	}
+	return d
+}
+
+func discordDM(id, channel, content string, bot bool) map[string]interface{} {
+	return map[string]interface{}{
+		"id":         id,
+		"channel_id": channel,
+		"content":    content,
+		"author":     map[string]interface{}{"id": "U1", "username": "dev", "global_name": "Dev", "bot": bot},
+	}
+}
+
+func TestDiscordSend(t *testing.T) {
+	s := newDiscordStandIn(t)
+
+	receipt, err := s.transport(t).Send(context.Background(), Message{Text: "Deploy?", SysName: "CI"})
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if receipt.SessionID != "discord-D100-1000" {
+		t.Errorf("SessionID = %q", receipt.SessionID)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.posted["content"] != "**CI:**\nDeploy?" {
+		t.Errorf("posted %v", s.posted)
+	}
+}
+
+func TestDiscordSendUnauthorized(t *testing.T) {
+	s := newDiscordStandIn(t)
+	d := s.transport(t)
+	d.token = "wrong"
+
+	_, err := d.Send(context.Background(), Message{Text: "hi"})
+	if !isDiscordPermanent(err) {
+		t.Errorf("Send = %v, want a permanent error", err)
+	}
+}
+
+func TestDiscordListenReconnectsWithoutHeartbeatACK(t *testing.T) {
+	s := newDiscordStandIn(t)
+	s.scripts = []discordGatewayScript{
+		// Half-open: the connection stays up but heartbeats go unanswered
+		{ack: false},
+		{ack: true, events: []map[string]interface{}{
+			discordDM("1001", "D999", "another channel", false),
+			discordDM("1002", "D100", "bot echo", true),
+			discordDM("0999", "D100", "before the question", false),
+			discordDM("1003", "D100", "ship it", false),
+		}},
+	}
+
+	var reconnects []error
+	reply, err := s.transport(t).Listen(context.Background(), "discord-D100-1000", ListenOptions{
+		Timeout:     5 * time.Second,
+		OnReconnect: func(attempt int, delay time.Duration, err error) { reconnects = append(reconnects, err) },
+	})
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	if reply.Content != "ship it" || reply.From != "Dev" || reply.Channel != "Discord" {
+		t.Errorf("reply = %+v", reply)
+	}
+	if len(reconnects) != 0 {
+		t.Errorf("reconnects = %v, want an immediate reconnect without backoff", reconnects)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.connects != 2 {
+		t.Errorf("connected %d times, want 2", s.connects)
+	}
+	if s.heartbeats[0] != 1 {
+		t.Errorf("sent %d heartbeats on the dead connection, want 1 before giving up", s.heartbeats[0])
+	}
+}
+
+func TestDiscordListenFindsMissedReply(t *testing.T) {
+	s := newDiscordStandIn(t)
+	s.interval = 60000
+	s.history = []map[string]interface{}{ // Newest first, as Discord returns them
+		discordDM("1005", "D100", "second thoughts", false),
+		discordDM("1004", "D100", "yes", false),
+	}
+	s.scripts = []discordGatewayScript{{ack: true}}
+
+	reply, err := s.transport(t).Listen(context.Background(), "discord-D100-1000", ListenOptions{Timeout: 5 * time.Second})
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	if reply.Content != "yes" {
+		t.Errorf("Content = %q, want the earliest reply", reply.Content)
+	}
+}
+
+func TestParseDiscordSession(t *testing.T) {
+	for _, bad := range []string{"", "discord-", "discord-D100", "discord--1000", "slack-1-2"} {
+		if _, _, err := parseDiscordSession(bad); err == nil {
+			t.Errorf("parseDiscordSession(%q) succeeded", bad)
+		}
+	}
+}
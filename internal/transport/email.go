@@ -0,0 +1,597 @@
+package transport
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/davedotdev/afk/internal/backoff"
+	"github.com/davedotdev/afk/internal/config"
+	"github.com/davedotdev/afk/internal/imap"
+)
+
+func init() {
+	Register(Registration{
+		Name:    "email",
+		Service: "Email",
+		Usage:   "Send message via email",
+		New: func(cfg *config.Config) (Transport, error) {
+			t, err := NewEmail(cfg)
+			if err != nil {
+				return nil, err
+			}
+			return t, nil
+		},
+		Configured: func(cfg *config.Config) bool { return cfg.EmailSMTPAddr != "" },
+		SetRecipient: func(cfg *config.Config, contact config.Contact) error {
+			return setAddress(&cfg.EmailTo, contact.Email, "email address")
+		},
+		Destination: emailDestination,
+	})
+}
+
+// emailDestination returns the address part of email_to, so the same
+// mailbox under two display names is still one destination
+func emailDestination(cfg *config.Config) string {
+	if addr, err := mail.ParseAddress(cfg.EmailTo); err == nil {
+		return strings.ToLower(addr.Address)
+	}
+	return strings.ToLower(strings.TrimSpace(cfg.EmailTo))
+}
+
+// How long to wait between mailbox searches: in IDLE where the server
+// supports it (renewed well inside the 29 minutes RFC 2177 allows), and
+// otherwise between polls. Variables so tests can shorten them.
+var (
+	emailIdleRefresh  = 5 * time.Minute
+	emailPollInterval = 10 * time.Second
+)
+
+// Email sends messages over SMTP and watches an IMAP mailbox for the reply,
+// matched by In-Reply-To/References against the sent Message-ID
+type Email struct {
+	from     string
+	to       string
+	smtpAddr string
+	imapAddr string
+	username string
+	password string
+	mailbox  string
+	insecure bool
+}
+
+// NewEmail creates an email transport from the config
+func NewEmail(cfg *config.Config) (*Email, error) {
+	if cfg.EmailFrom == "" || cfg.EmailTo == "" || cfg.EmailSMTPAddr == "" || cfg.EmailIMAPAddr == "" {
+		return nil, errors.New("email not configured: set email_from, email_to, email_smtp_addr and email_imap_addr in " + config.Path())
+	}
+
+	from, err := mail.ParseAddress(cfg.EmailFrom)
+	if err != nil {
+		return nil, fmt.Errorf("invalid email_from: %w", err)
+	}
+	if _, err := mail.ParseAddress(cfg.EmailTo); err != nil {
+		return nil, fmt.Errorf("invalid email_to: %w", err)
+	}
+
+	e := &Email{
+		from:     cfg.EmailFrom,
+		to:       cfg.EmailTo,
+		smtpAddr: cfg.EmailSMTPAddr,
+		imapAddr: cfg.EmailIMAPAddr,
+		username: cfg.EmailUsername,
+		password: cfg.EmailPassword,
+		mailbox:  cfg.EmailMailbox,
+		insecure: cfg.EmailInsecure,
+	}
+	if e.username == "" {
+		e.username = from.Address
+	}
+	if e.mailbox == "" {
+		e.mailbox = "INBOX"
+	}
+	return e, nil
+}
+
+// Name returns "Email"
+func (e *Email) Name() string {
+	return "Email"
+}
+
+// Send mails the message. The session token appears in the subject and
+// in the Message-ID that replies reference.
+func (e *Email) Send(ctx context.Context, msg Message) (*Receipt, error) {
+	token, err := newEmailToken()
+	if err != nil {
+		return nil, err
+	}
+	messageID := e.messageID(token)
+
+	subject := firstLine(msg.Text)
+	if msg.SysName != "" {
+		subject = msg.SysName + ": " + subject
+	}
+	subject = fmt.Sprintf("[afk %s] %s", token, subject)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", e.from)
+	fmt.Fprintf(&buf, "To: %s\r\n", e.to)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "Message-ID: %s\r\n", messageID)
+	fmt.Fprintf(&buf, "X-AFK-Session: email-%s\r\n", token)
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+	qp := quotedprintable.NewWriter(&buf)
+	qp.Write([]byte(strings.ReplaceAll(msg.Text, "\n", "\r\n")))
+	qp.Close()
+
+	if err := e.sendMail(ctx, buf.Bytes()); err != nil {
+		return nil, err
+	}
+
+	return &Receipt{
+		SessionID: "email-" + token,
+		MessageID: messageID,
+	}, nil
+}
+
+// Listen searches the mailbox for a message replying to the session's
+// email, waiting for new mail in IDLE or, without it, polling
+func (e *Email) Listen(ctx context.Context, sessionID string, opts ListenOptions) (*Reply, error) {
+	token, ok := strings.CutPrefix(sessionID, "email-")
+	if !ok || token == "" {
+		return nil, fmt.Errorf("invalid email session ID %q", sessionID)
+	}
+	messageID := imap.Quote(e.messageID(token))
+	criteria := "OR HEADER In-Reply-To " + messageID + " HEADER References " + messageID
+
+	return wait(ctx, opts, func(ctx context.Context) (*Reply, error) {
+		var c *imap.Client
+		defer func() {
+			if c != nil {
+				c.Close()
+			}
+		}()
+
+		seen := make(map[uint32]bool)
+		idle := false
+		attempt := 0
+		for {
+			var err error
+			if c == nil {
+				c, err = e.openMailbox(ctx)
+				if err == nil {
+					idle, err = c.Capability(ctx, "IDLE")
+				}
+			}
+
+			var reply *Reply
+			if err == nil {
+				reply, err = e.findReply(ctx, c, sessionID, criteria, seen)
+			}
+			if err == nil {
+				attempt = 0
+				if reply != nil {
+					c.Logout(ctx)
+					c = nil
+					return reply, nil
+				}
+
+				// Wait for new mail before searching again
+				if idle {
+					err = c.Idle(ctx, emailIdleRefresh)
+				} else if !sleep(ctx, emailPollInterval) {
+					return nil, ctx.Err()
+				}
+			}
+			if err == nil {
+				continue
+			}
+
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			// Rejected login, missing mailbox or bad search will not recover
+			var statusErr *imap.StatusError
+			if errors.As(err, &statusErr) {
+				return nil, err
+			}
+			if c != nil {
+				c.Close()
+				c = nil
+			}
+
+			attempt++
+			delay := backoff.Delay(attempt, 0)
+			if opts.OnReconnect != nil {
+				opts.OnReconnect(attempt, delay, err)
+			}
+			if !sleep(ctx, delay) {
+				return nil, ctx.Err()
+			}
+		}
+	})
+}
+
+// Health checks that the SMTP and IMAP servers accept connections
+func (e *Email) Health(ctx context.Context) error {
+	var d net.Dialer
+	for _, addr := range []string{e.smtpAddr, e.imapAddr} {
+		conn, err := d.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return fmt.Errorf("%s unreachable: %w", addr, err)
+		}
+		conn.Close()
+	}
+	return nil
+}
+
+// Validate logs in to both the SMTP and IMAP servers
+func (e *Email) Validate(ctx context.Context) error {
+	c, err := e.dialSMTP(ctx)
+	if err != nil {
+		return err
+	}
+	c.Quit()
+
+	ic, err := e.openMailbox(ctx)
+	if err != nil {
+		return err
+	}
+	return ic.Logout(ctx)
+}
+
+// findReply fetches unseen search matches and returns the first with
+// any new text in it
+func (e *Email) findReply(ctx context.Context, c *imap.Client, sessionID, criteria string, seen map[uint32]bool) (*Reply, error) {
+	if err := c.Noop(ctx); err != nil {
+		return nil, err
+	}
+	uids, err := c.Search(ctx, criteria)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(uids, func(i, j int) bool { return uids[i] < uids[j] })
+
+	for _, uid := range uids {
+		if seen[uid] {
+			continue
+		}
+		raw, err := c.Fetch(ctx, uid)
+		if err != nil {
+			return nil, err
+		}
+		seen[uid] = true
+
+		from, content, err := parseEmailReply(raw)
+		if err != nil || content == "" {
+			continue
+		}
+		return &Reply{
+			SessionID: sessionID,
+			From:      from,
+			Channel:   e.Name(),
+			Content:   content,
+		}, nil
+	}
+	return nil, nil
+}
+
+// openMailbox connects and logs in to the IMAP server and selects the mailbox
+func (e *Email) openMailbox(ctx context.Context) (*imap.Client, error) {
+	security := imap.StartTLS
+	if e.insecure {
+		security = imap.Plain
+	} else if _, port, _ := net.SplitHostPort(e.imapAddr); port == "993" {
+		security = imap.TLS
+	}
+
+	c, err := imap.Dial(ctx, e.imapAddr, security)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.Login(ctx, e.username, e.password); err != nil {
+		c.Close()
+		return nil, err
+	}
+	if err := c.Select(ctx, e.mailbox); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// sendMail delivers a message to the recipient
+func (e *Email) sendMail(ctx context.Context, message []byte) error {
+	c, err := e.dialSMTP(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	from, _ := mail.ParseAddress(e.from)
+	to, _ := mail.ParseAddress(e.to)
+
+	if err := c.Mail(from.Address); err != nil {
+		return fmt.Errorf("smtp MAIL FROM: %w", err)
+	}
+	if err := c.Rcpt(to.Address); err != nil {
+		return fmt.Errorf("smtp RCPT TO: %w", err)
+	}
+	w, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("smtp DATA: %w", err)
+	}
+	if _, err := w.Write(message); err != nil {
+		return fmt.Errorf("smtp DATA: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("smtp DATA: %w", err)
+	}
+	return c.Quit()
+}
+
+// dialSMTP connects to the SMTP server, upgrades to TLS and authenticates.
+// Port 465 uses implicit TLS; other ports require STARTTLS unless
+// email_insecure is set.
+func (e *Email) dialSMTP(ctx context.Context) (*smtp.Client, error) {
+	host, port, err := net.SplitHostPort(e.smtpAddr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid email_smtp_addr: %w", err)
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", e.smtpAddr)
+	if err != nil {
+		return nil, fmt.Errorf("smtp dial failed: %w", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	tlsConfig := &tls.Config{ServerName: host}
+	if port == "465" && !e.insecure {
+		tlsConn := tls.Client(conn, tlsConfig)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("smtp TLS handshake failed: %w", err)
+		}
+		conn = tlsConn
+	}
+
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("smtp: %w", err)
+	}
+
+	if _, isTLS := conn.(*tls.Conn); !isTLS && !e.insecure {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			c.Close()
+			return nil, errors.New("smtp server does not offer STARTTLS")
+		}
+		if err := c.StartTLS(tlsConfig); err != nil {
+			c.Close()
+			return nil, fmt.Errorf("smtp STARTTLS: %w", err)
+		}
+	}
+
+	if e.password != "" {
+		if err := c.Auth(smtp.PlainAuth("", e.username, e.password, host)); err != nil {
+			c.Close()
+			return nil, fmt.Errorf("smtp auth: %w", err)
+		}
+	}
+	return c, nil
+}
+
+// messageID returns the Message-ID header value for a session token
+func (e *Email) messageID(token string) string {
+	domain := "afk.local"
+	if addr, err := mail.ParseAddress(e.from); err == nil {
+		if i := strings.LastIndexByte(addr.Address, '@'); i >= 0 {
+			domain = addr.Address[i+1:]
+		}
+	}
+	return "<afk-" + token + "@" + domain + ">"
+}
+
+func newEmailToken() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate session ID: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// firstLine returns the first line of s, shortened for a subject
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(s), "\n")
+	line = strings.TrimSpace(line)
+	if utf8.RuneCountInString(line) > 60 {
+		line = string([]rune(line)[:57]) + "..."
+	}
+	return line
+}
+
+// parseEmailReply returns the sender and the new text of a reply, with
+// quoted text and signatures removed
+func parseEmailReply(raw []byte) (from, content string, err error) {
+	m, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return "", "", err
+	}
+
+	from = "unknown"
+	if addr, err := mail.ParseAddress(m.Header.Get("From")); err == nil {
+		from = addr.Address
+		if addr.Name != "" {
+			from = addr.Name
+		}
+	}
+
+	text, _, err := bodyText(m.Header.Get("Content-Type"), m.Header.Get("Content-Transfer-Encoding"), m.Body)
+	if err != nil {
+		return from, "", err
+	}
+	return from, stripQuoted(text), nil
+}
+
+// bodyText extracts readable text from a MIME entity, preferring
+// text/plain over text/html. isHTML reports an HTML fallback.
+func bodyText(contentType, encoding string, body io.Reader) (text string, isHTML bool, err error) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType, params = "text/plain", nil
+	}
+
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, body)
+	}
+
+	switch {
+	case strings.HasPrefix(mediaType, "multipart/"):
+		mr := multipart.NewReader(body, params["boundary"])
+		var fallback string
+		for {
+			part, err := mr.NextRawPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return "", false, err
+			}
+			if strings.HasPrefix(part.Header.Get("Content-Disposition"), "attachment") {
+				continue
+			}
+			text, isHTML, err := bodyText(part.Header.Get("Content-Type"), part.Header.Get("Content-Transfer-Encoding"), part)
+			if err != nil {
+				return "", false, err
+			}
+			if text == "" {
+				continue
+			}
+			if !isHTML {
+				return text, false, nil
+			}
+			if fallback == "" {
+				fallback = text
+			}
+		}
+		return fallback, fallback != "", nil
+
+	case mediaType == "text/plain", mediaType == "text/html":
+		data, err := io.ReadAll(io.LimitReader(body, 1<<20))
+		if err != nil {
+			return "", false, err
+		}
+		text := decodeCharset(data, params["charset"])
+		if mediaType == "text/html" {
+			return htmlToText(text), true, nil
+		}
+		return text, false, nil
+	}
+	return "", false, nil
+}
+
+// decodeCharset converts Latin-1 style bodies to UTF-8; anything else is
+// assumed to be UTF-8 already
+func decodeCharset(data []byte, charset string) string {
+	switch strings.ToLower(charset) {
+	case "iso-8859-1", "latin1", "windows-1252":
+		runes := make([]rune, len(data))
+		for i, b := range data {
+			runes[i] = rune(b)
+		}
+		return string(runes)
+	}
+	return string(data)
+}
+
+var (
+	htmlDropRe  = regexp.MustCompile(`(?is)<(style|script|head)[^>]*>.*?</(style|script|head)>`)
+	htmlBreakRe = regexp.MustCompile(`(?i)<br\s*/?>|</(p|div|li|tr|h[1-6])>|<blockquote[^>]*>`)
+	htmlTagRe   = regexp.MustCompile(`<[^>]*>`)
+)
+
+// htmlToText reduces an HTML body to plain lines
+func htmlToText(s string) string {
+	s = htmlDropRe.ReplaceAllString(s, "")
+	s = htmlBreakRe.ReplaceAllString(s, "\n")
+	s = htmlTagRe.ReplaceAllString(s, "")
+	return html.UnescapeString(s)
+}
+
+// stripQuoted keeps the new text of a reply: it drops ">" quoted lines,
+// stops at the signature delimiter or a mobile signature, and stops at a
+// quote attribution ("On ... wrote:", Outlook separators) once some reply
+// text has been seen, so bottom-posted replies survive
+func stripQuoted(text string) string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	lines := strings.Split(text, "\n")
+
+	var kept []string
+	hasContent := false
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimRight(lines[i], " \t")
+		trimmed := strings.TrimSpace(line)
+
+		if trimmed == "--" || strings.HasPrefix(trimmed, "Sent from my ") {
+			break
+		}
+		if strings.HasPrefix(trimmed, ">") {
+			continue
+		}
+
+		attribution := false
+		switch {
+		case strings.HasPrefix(trimmed, "On ") && strings.HasSuffix(trimmed, "wrote:"):
+			attribution = true
+		case strings.HasPrefix(trimmed, "On ") && i+1 < len(lines) && strings.HasSuffix(strings.TrimSpace(lines[i+1]), "wrote:"):
+			// Attribution wrapped over two lines
+			attribution = true
+			i++
+		case strings.HasPrefix(trimmed, "-----Original Message-----"),
+			len(trimmed) >= 10 && strings.Trim(trimmed, "_") == "":
+			attribution = true
+		}
+		if attribution {
+			if hasContent {
+				break
+			}
+			continue
+		}
+
+		if trimmed != "" {
+			hasContent = true
+		}
+		kept = append(kept, line)
+	}
+
+	return strings.TrimSpace(strings.Join(kept, "\n"))
+}
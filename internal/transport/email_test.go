@@ -0,0 +1,462 @@
+package transport
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/mail"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/davedotdev/afk/internal/config"
+)
+
+// smtpStandIn accepts one message per connection and records it
+type smtpStandIn struct {
+	addr string
+
+	mu   sync.Mutex
+	auth string // Decoded AUTH PLAIN response
+	from string
+	to   []string
+	data string
+}
+
+func newSMTPStandIn(t *testing.T) *smtpStandIn {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	s := &smtpStandIn{addr: ln.Addr().String()}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *smtpStandIn) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { fmt.Fprintf(conn, "%s\r\n", line) }
+
+	reply("220 localhost ESMTP stand-in")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		verb, arg, _ := strings.Cut(line, " ")
+
+		s.mu.Lock()
+		switch strings.ToUpper(verb) {
+		case "EHLO":
+			reply("250-localhost")
+			reply("250 AUTH PLAIN") // No STARTTLS, so only email_insecure can send
+		case "AUTH":
+			_, initial, _ := strings.Cut(arg, " ")
+			decoded, _ := base64.StdEncoding.DecodeString(initial)
+			s.auth = string(decoded)
+			reply("235 2.7.0 Authentication successful")
+		case "MAIL":
+			s.from = arg
+			reply("250 OK")
+		case "RCPT":
+			s.to = append(s.to, arg)
+			reply("250 OK")
+		case "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					s.mu.Unlock()
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				data.WriteString(strings.TrimPrefix(l, "."))
+			}
+			s.data = data.String()
+			reply("250 OK queued")
+		case "QUIT":
+			reply("221 Bye")
+			s.mu.Unlock()
+			return
+		default:
+			reply("502 Command not implemented")
+		}
+		s.mu.Unlock()
+	}
+}
+
+// imapStandIn is a scripted IMAP server holding one mailbox. SEARCH
+// understands the OR/HEADER criteria the email transport sends.
+type imapStandIn struct {
+	addr string
+	idle bool // Advertise IDLE
+
+	mu        sync.Mutex
+	messages  []string // Raw messages; the UID is the index plus one
+	commands  []string // Commands received, without tags or arguments
+	delivered chan struct{}
+}
+
+func newIMAPStandIn(t *testing.T, idle bool) *imapStandIn {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	s := &imapStandIn{addr: ln.Addr().String(), idle: idle, delivered: make(chan struct{}, 8)}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+// deliver adds a message to the mailbox, waking any client in IDLE
+func (s *imapStandIn) deliver(raw string) {
+	s.mu.Lock()
+	s.messages = append(s.messages, strings.ReplaceAll(raw, "\n", "\r\n"))
+	s.mu.Unlock()
+	s.delivered <- struct{}{}
+}
+
+// waitFor blocks until the client has sent command n times
+func (s *imapStandIn) waitFor(t *testing.T, command string, n int) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		if s.count(command) >= n {
+			return
+		}
+	}
+	t.Fatalf("client never sent %s %d times; sent %v", command, n, s.log())
+}
+
+func (s *imapStandIn) count(command string) int {
+	n := 0
+	for _, c := range s.log() {
+		if c == command {
+			n++
+		}
+	}
+	return n
+}
+
+func (s *imapStandIn) log() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.commands...)
+}
+
+func (s *imapStandIn) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	send := func(format string, args ...interface{}) { fmt.Fprintf(conn, format+"\r\n", args...) }
+
+	send("* OK IMAP4rev1 stand-in ready")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		tag, command, _ := strings.Cut(strings.TrimRight(line, "\r\n"), " ")
+		name, args, _ := strings.Cut(command, " ")
+		if name == "UID" {
+			name, args, _ = strings.Cut(args, " ")
+		}
+		s.mu.Lock()
+		s.commands = append(s.commands, name)
+		s.mu.Unlock()
+
+		switch name {
+		case "CAPABILITY":
+			if s.idle {
+				send("* CAPABILITY IMAP4rev1 IDLE")
+			} else {
+				send("* CAPABILITY IMAP4rev1")
+			}
+		case "LOGIN":
+			if args != `"afk@example.com" "secret"` {
+				send("%s NO [AUTHENTICATIONFAILED] Invalid credentials", tag)
+				continue
+			}
+		case "EXAMINE":
+			s.mu.Lock()
+			send("* %d EXISTS", len(s.messages))
+			s.mu.Unlock()
+		case "SEARCH":
+			send("* SEARCH%s", s.search(args))
+		case "FETCH":
+			var uid int
+			fmt.Sscanf(args, "%d", &uid)
+			s.mu.Lock()
+			if uid >= 1 && uid <= len(s.messages) {
+				raw := s.messages[uid-1]
+				send("* %d FETCH (UID %d BODY[] {%d}", uid, uid, len(raw))
+				fmt.Fprint(conn, raw)
+				send(")")
+			}
+			s.mu.Unlock()
+		case "IDLE":
+			if !s.idle {
+				send("%s BAD Unknown command", tag)
+				continue
+			}
+			send("+ idling")
+			done := make(chan struct{})
+			go func() {
+				select {
+				case <-s.delivered:
+					s.mu.Lock()
+					send("* %d EXISTS", len(s.messages))
+					s.mu.Unlock()
+				case <-done:
+				}
+			}()
+			l, err := r.ReadString('\n')
+			close(done)
+			if err != nil || strings.TrimSpace(l) != "DONE" {
+				return
+			}
+		case "LOGOUT":
+			send("* BYE logging out")
+			send("%s OK LOGOUT completed", tag)
+			return
+		}
+		send("%s OK %s completed", tag, name)
+	}
+}
+
+// search returns " uid uid ..." for messages whose In-Reply-To or
+// References header contains the Message-ID in criteria
+func (s *imapStandIn) search(criteria string) string {
+	var id string
+	if i := strings.Index(criteria, `"<`); i >= 0 {
+		id, _, _ = strings.Cut(criteria[i+1:], `"`)
+	}
+	if !strings.Contains(criteria, "HEADER In-Reply-To") || !strings.Contains(criteria, "HEADER References") {
+		id = "" // Match nothing unless both headers are searched
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var uids string
+	for i, raw := range s.messages {
+		m, err := mail.ReadMessage(strings.NewReader(raw))
+		if err != nil || id == "" {
+			continue
+		}
+		if strings.Contains(m.Header.Get("In-Reply-To"), id) || strings.Contains(m.Header.Get("References"), id) {
+			uids += fmt.Sprintf(" %d", i+1)
+		}
+	}
+	return uids
+}
+
+func newTestEmail(t *testing.T, smtpAddr, imapAddr string) *Email {
+	t.Helper()
+	e, err := NewEmail(&config.Config{
+		EmailFrom:     "afk@example.com",
+		EmailTo:       "Reviewer <reviewer@example.com>",
+		EmailSMTPAddr: smtpAddr,
+		EmailIMAPAddr: imapAddr,
+		EmailPassword: "secret",
+		EmailInsecure: true,
+	})
+	if err != nil {
+		t.Fatalf("NewEmail: %v", err)
+	}
+	return e
+}
+
+func TestEmailSend(t *testing.T) {
+	s := newSMTPStandIn(t)
+	e := newTestEmail(t, s.addr, "127.0.0.1:1")
+
+	receipt, err := e.Send(context.Background(), Message{Text: "Merge the release branch?\nCI is green.", SysName: "CI"})
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	token := strings.TrimPrefix(receipt.SessionID, "email-")
+	if receipt.MessageID != "<afk-"+token+"@example.com>" {
+		t.Errorf("MessageID = %q", receipt.MessageID)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.auth != "\x00afk@example.com\x00secret" {
+		t.Errorf("AUTH PLAIN = %q", s.auth)
+	}
+	if s.from != "FROM:<afk@example.com>" || len(s.to) != 1 || s.to[0] != "TO:<reviewer@example.com>" {
+		t.Errorf("envelope from %q to %q", s.from, s.to)
+	}
+
+	m, err := mail.ReadMessage(strings.NewReader(s.data))
+	if err != nil {
+		t.Fatalf("sent message: %v", err)
+	}
+	if got, want := m.Header.Get("Subject"), "[afk "+token+"] CI: Merge the release branch?"; got != want {
+		t.Errorf("Subject = %q, want %q", got, want)
+	}
+	if m.Header.Get("Message-ID") != receipt.MessageID || m.Header.Get("X-AFK-Session") != receipt.SessionID {
+		t.Errorf("headers %v", m.Header)
+	}
+	body := parseEmailBody(t, s.data)
+	if body = strings.TrimRight(body, "\r\n"); body != "Merge the release branch?\r\nCI is green." {
+		t.Errorf("body = %q", body)
+	}
+}
+
+// parseEmailBody decodes the text of a sent message
+func parseEmailBody(t *testing.T, raw string) string {
+	t.Helper()
+	m, err := mail.ReadMessage(strings.NewReader(raw))
+	if err != nil {
+		t.Fatalf("ReadMessage: %v", err)
+	}
+	text, _, err := bodyText(m.Header.Get("Content-Type"), m.Header.Get("Content-Transfer-Encoding"), m.Body)
+	if err != nil {
+		t.Fatalf("bodyText: %v", err)
+	}
+	return text
+}
+
+func TestEmailSendRequiresSTARTTLS(t *testing.T) {
+	s := newSMTPStandIn(t)
+	e := newTestEmail(t, s.addr, "127.0.0.1:1")
+	e.insecure = false
+
+	_, err := e.Send(context.Background(), Message{Text: "hi"})
+	if err == nil || !strings.Contains(err.Error(), "STARTTLS") {
+		t.Errorf("Send = %v, want a STARTTLS error", err)
+	}
+}
+
+// emailReply is a message from the reviewer with the threading headers given
+func emailReply(headers, body string) string {
+	return "From: Reviewer <reviewer@example.com>\n" +
+		"To: afk@example.com\n" +
+		"Subject: Re: [afk] question\n" +
+		headers +
+		"Content-Type: text/plain; charset=utf-8\n\n" +
+		body
+}
+
+func listenEmail(t *testing.T, e *Email, sessionID string) (chan *Reply, chan error) {
+	replies, errs := make(chan *Reply, 1), make(chan error, 1)
+	go func() {
+		reply, err := e.Listen(context.Background(), sessionID, ListenOptions{Timeout: 10 * time.Second})
+		replies <- reply
+		errs <- err
+	}()
+	return replies, errs
+}
+
+func TestEmailListenIdle(t *testing.T) {
+	const token = "00112233aabbccdd"
+	const messageID = "<afk-" + token + "@example.com>"
+	s := newIMAPStandIn(t, true)
+	s.messages = []string{
+		emailReply("In-Reply-To: <afk-ffffffffffffffff@example.com>\n", "Answer to another question"),
+		emailReply("", "Unthreaded mail"),
+	}
+	e := newTestEmail(t, "127.0.0.1:1", s.addr)
+
+	replies, errs := listenEmail(t, e, "email-"+token)
+	s.waitFor(t, "IDLE", 1)
+
+	// A reply that only names the session in References, as some clients send
+	s.deliver(emailReply("References: <thread-start@example.com> "+messageID+"\n",
+		"Yes, ship it.\n\nOn Mon, 1 Jan 2024, afk wrote:\n> Merge the release branch?\n-- \nReviewer"))
+
+	reply, err := <-replies, <-errs
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	if reply.Content != "Yes, ship it." || reply.From != "Reviewer" || reply.Channel != "Email" {
+		t.Errorf("reply = %+v", reply)
+	}
+	if s.count("SEARCH") != 2 || s.count("IDLE") != 1 {
+		t.Errorf("commands %v, want one IDLE between two searches", s.log())
+	}
+}
+
+func TestEmailListenPolling(t *testing.T) {
+	defer func(d time.Duration) { emailPollInterval = d }(emailPollInterval)
+	emailPollInterval = 20 * time.Millisecond
+
+	const token = "0123456789abcdef"
+	s := newIMAPStandIn(t, false)
+	e := newTestEmail(t, "127.0.0.1:1", s.addr)
+
+	replies, errs := listenEmail(t, e, "email-"+token)
+	s.waitFor(t, "SEARCH", 1)
+	s.deliver(emailReply("In-Reply-To: <afk-"+token+"@example.com>\n", "> Merge?\nno"))
+
+	reply, err := <-replies, <-errs
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	if reply.Content != "no" {
+		t.Errorf("Content = %q, want %q", reply.Content, "no")
+	}
+	if s.count("IDLE") != 0 {
+		t.Errorf("IDLE sent to a server without it: %v", s.log())
+	}
+	if s.count("SEARCH") < 2 {
+		t.Errorf("commands %v, want repeated searches", s.log())
+	}
+}
+
+func TestEmailListenLoginRejected(t *testing.T) {
+	s := newIMAPStandIn(t, true)
+	e := newTestEmail(t, "127.0.0.1:1", s.addr)
+	e.password = "wrong"
+
+	_, err := e.Listen(context.Background(), "email-0123456789abcdef", ListenOptions{Timeout: 5 * time.Second})
+	if err == nil || !strings.Contains(err.Error(), "AUTHENTICATIONFAILED") {
+		t.Errorf("Listen = %v, want the login failure", err)
+	}
+}
+
+func TestStripQuoted(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"top-posted", "Approved\n\nOn Tue, Jan 2, 2024 at 9:00 AM afk <afk@example.com> wrote:\n> Deploy?", "Approved"},
+		{"wrapped attribution", "B please\nOn Tue, Jan 2, 2024 at 9:00 AM afk\n<afk@example.com> wrote:\n> Pick one", "B please"},
+		{"bottom-posted", "On Tue, afk wrote:\n> Deploy?\n\nyes", "yes"},
+		{"signature", "lgtm\n-- \nJane Doe\nStaff Engineer", "lgtm"},
+		{"mobile signature", "ok\n\nSent from my iPhone", "ok"},
+		{"outlook separator", "No.\r\n\r\n-----Original Message-----\r\nFrom: afk", "No."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := stripQuoted(tt.text); got != tt.want {
+				t.Errorf("stripQuoted = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
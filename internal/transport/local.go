@@ -0,0 +1,239 @@
+package transport
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/user"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/davedotdev/afk/internal/config"
+)
+
+func init() {
+	Register(Registration{
+		Name:    "local",
+		Service: "Local",
+		Usage:   "Ask on this machine (desktop notification and terminal prompt)",
+		New: func(cfg *config.Config) (Transport, error) {
+			return NewLocal(cfg), nil
+		},
+		// Needs no credentials, so it is always available
+		Configured: func(cfg *config.Config) bool { return true },
+		// Every recipient would be asked at the same terminal
+		Destination: func(cfg *config.Config) string { return "this machine" },
+	})
+}
+
+// Local asks the developer at this machine: it raises a desktop
+// notification and reads the reply from the controlling terminal, or from
+// a dialog when there is no terminal
+type Local struct {
+	notify bool
+	text   string // Last message sent, shown again by a dialog prompt
+
+	// openTTY and createTTY open the terminal for reading and writing
+	openTTY   func() (io.ReadCloser, error)
+	createTTY func() (io.WriteCloser, error)
+}
+
+// NewLocal creates a local transport from the config
+func NewLocal(cfg *config.Config) *Local {
+	return &Local{
+		notify:    !cfg.LocalNoNotify,
+		openTTY:   func() (io.ReadCloser, error) { return os.Open(ttyInPath()) },
+		createTTY: func() (io.WriteCloser, error) { return os.OpenFile(ttyOutPath(), os.O_WRONLY, 0) },
+	}
+}
+
+// Name returns "Local"
+func (l *Local) Name() string {
+	return "Local"
+}
+
+// Send shows the message on the terminal and as a desktop notification.
+// Neither is required to succeed; Listen reports when no prompt is possible.
+func (l *Local) Send(ctx context.Context, msg Message) (*Receipt, error) {
+	title := "afk"
+	if msg.SysName != "" {
+		title = msg.SysName
+	}
+	l.text = msg.Text
+
+	if l.notify {
+		notifyDesktop(ctx, title, msg.Text)
+	}
+	if tty, err := l.createTTY(); err == nil {
+		fmt.Fprintf(tty, "\n── %s asks ──\n%s\n", title, msg.Text)
+		tty.Close()
+	}
+
+	return &Receipt{
+		SessionID: fmt.Sprintf("local-%d-%d", os.Getpid(), time.Now().Unix()),
+	}, nil
+}
+
+// Listen prompts on the controlling terminal, falling back to a dialog
+// (zenity, kdialog or osascript) when afk has no terminal
+func (l *Local) Listen(ctx context.Context, sessionID string, opts ListenOptions) (*Reply, error) {
+	if !strings.HasPrefix(sessionID, "local-") {
+		return nil, fmt.Errorf("invalid local session ID %q", sessionID)
+	}
+
+	text := l.text
+	if text == "" {
+		text = "Reply to afk session " + sessionID
+	}
+
+	return wait(ctx, opts, func(ctx context.Context) (*Reply, error) {
+		if tty, err := l.openTTY(); err == nil {
+			return l.promptTTY(ctx, tty, sessionID)
+		}
+		if dialog := dialogCommand(ctx, text); dialog != nil {
+			return l.promptDialog(dialog, sessionID)
+		}
+		return nil, errors.New("no terminal or dialog tool (zenity, kdialog) to read the reply")
+	})
+}
+
+// Health always succeeds; there is no service
+func (l *Local) Health(ctx context.Context) error {
+	return nil
+}
+
+// Validate checks that a reply can be read
+func (l *Local) Validate(ctx context.Context) error {
+	if tty, err := l.openTTY(); err == nil {
+		tty.Close()
+		return nil
+	}
+	if dialogCommand(ctx, "") != nil {
+		return nil
+	}
+	return errors.New("no terminal or dialog tool (zenity, kdialog) to read the reply")
+}
+
+// promptTTY reads one non-empty line from the terminal
+func (l *Local) promptTTY(ctx context.Context, tty io.ReadCloser, sessionID string) (*Reply, error) {
+	defer tty.Close()
+	// Closing the terminal unblocks the read when the wait ends
+	stop := context.AfterFunc(ctx, func() { tty.Close() })
+	defer stop()
+
+	if out, err := l.createTTY(); err == nil {
+		defer out.Close()
+		fmt.Fprint(out, "Reply: ")
+	}
+
+	reader := bufio.NewReader(tty)
+	for {
+		line, err := reader.ReadString('\n')
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if line = strings.TrimSpace(line); line != "" {
+			return l.reply(sessionID, line), nil
+		}
+		if err == io.EOF {
+			return nil, ErrCancelled
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read terminal: %w", err)
+		}
+	}
+}
+
+// promptDialog runs a dialog and returns the text entered
+func (l *Local) promptDialog(cmd *exec.Cmd, sessionID string) (*Reply, error) {
+	output, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			// The dialog was dismissed
+			return nil, ErrCancelled
+		}
+		return nil, fmt.Errorf("dialog failed: %w", err)
+	}
+	content := strings.TrimSpace(string(output))
+	if content == "" {
+		return nil, ErrCancelled
+	}
+	return l.reply(sessionID, content), nil
+}
+
+func (l *Local) reply(sessionID, content string) *Reply {
+	from := "local"
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		from = u.Username
+	}
+	return &Reply{
+		SessionID: sessionID,
+		From:      from,
+		Channel:   l.Name(),
+		Content:   content,
+	}
+}
+
+// notifyDesktop raises a notification with notify-send (freedesktop
+// notifications over D-Bus) or, on macOS, osascript. Failures are ignored.
+func notifyDesktop(ctx context.Context, title, body string) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.CommandContext(ctx, "osascript",
+			"-e", "on run argv",
+			"-e", "display notification (item 2 of argv) with title (item 1 of argv)",
+			"-e", "end run", title, body)
+	case "windows":
+		return
+	default:
+		path, err := exec.LookPath("notify-send")
+		if err != nil {
+			return
+		}
+		cmd = exec.CommandContext(ctx, path, "--app-name=afk", "--urgency=critical", title, body)
+	}
+	cmd.Run()
+}
+
+// dialogCommand returns a command that shows text and prints the entered
+// reply, or nil when no dialog tool is available
+func dialogCommand(ctx context.Context, text string) *exec.Cmd {
+	if runtime.GOOS == "darwin" {
+		return exec.CommandContext(ctx, "osascript",
+			"-e", "on run argv",
+			"-e", `text returned of (display dialog (item 1 of argv) default answer "" with title "afk")`,
+			"-e", "end run", text)
+	}
+	if os.Getenv("DISPLAY") == "" && os.Getenv("WAYLAND_DISPLAY") == "" {
+		return nil
+	}
+	if path, err := exec.LookPath("zenity"); err == nil {
+		return exec.CommandContext(ctx, path, "--entry", "--title=afk", "--text="+text)
+	}
+	if path, err := exec.LookPath("kdialog"); err == nil {
+		return exec.CommandContext(ctx, path, "--title", "afk", "--inputbox", text)
+	}
+	return nil
+}
+
+// ttyInPath and ttyOutPath name the controlling terminal
+func ttyInPath() string {
+	if runtime.GOOS == "windows" {
+		return "CONIN$"
+	}
+	return "/dev/tty"
+}
+
+func ttyOutPath() string {
+	if runtime.GOOS == "windows" {
+		return "CONOUT$"
+	}
+	return "/dev/tty"
+}
@@ -0,0 +1,123 @@
+package transport
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/davedotdev/afk/internal/config"
+)
+
+// localTerminal stands in for the controlling terminal: replies are read
+// from in and everything afk writes lands in out
+type localTerminal struct {
+	in io.ReadCloser
+
+	mu  sync.Mutex
+	out bytes.Buffer
+}
+
+func (t *localTerminal) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.out.Write(p)
+}
+
+func (t *localTerminal) Close() error {
+	return nil
+}
+
+func (t *localTerminal) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.out.String()
+}
+
+func newLocalTerminal(in io.ReadCloser) (*Local, *localTerminal) {
+	term := &localTerminal{in: in}
+	l := NewLocal(&config.Config{LocalNoNotify: true})
+	l.openTTY = func() (io.ReadCloser, error) { return term.in, nil }
+	l.createTTY = func() (io.WriteCloser, error) { return term, nil }
+	return l, term
+}
+
+func TestLocalSend(t *testing.T) {
+	l, term := newLocalTerminal(nil)
+
+	receipt, err := l.Send(context.Background(), Message{Text: "Deploy?", SysName: "CI"})
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if !strings.HasPrefix(receipt.SessionID, "local-") {
+		t.Errorf("SessionID = %q", receipt.SessionID)
+	}
+	if out := term.String(); !strings.Contains(out, "── CI asks ──\nDeploy?\n") {
+		t.Errorf("terminal shows %q", out)
+	}
+}
+
+func TestLocalListenTerminal(t *testing.T) {
+	l, term := newLocalTerminal(io.NopCloser(strings.NewReader("\n   \n  ship it  \nignored\n")))
+
+	reply, err := l.Listen(context.Background(), "local-1-2", ListenOptions{Timeout: 5 * time.Second})
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	if reply.Content != "ship it" || reply.SessionID != "local-1-2" || reply.Channel != "Local" || reply.From == "" {
+		t.Errorf("reply = %+v", reply)
+	}
+	if out := term.String(); out != "Reply: " {
+		t.Errorf("terminal shows %q, want the prompt", out)
+	}
+}
+
+func TestLocalListenTerminalClosed(t *testing.T) {
+	l, _ := newLocalTerminal(io.NopCloser(strings.NewReader("\n\n")))
+
+	_, err := l.Listen(context.Background(), "local-1-2", ListenOptions{Timeout: 5 * time.Second})
+	if !errors.Is(err, ErrCancelled) {
+		t.Errorf("Listen = %v, want ErrCancelled", err)
+	}
+}
+
+// closeNotifier reports when the terminal it wraps is closed
+type closeNotifier struct {
+	io.ReadCloser
+	once   sync.Once
+	closed chan struct{}
+}
+
+func (c *closeNotifier) Close() error {
+	c.once.Do(func() { close(c.closed) })
+	return c.ReadCloser.Close()
+}
+
+func TestLocalListenTimeout(t *testing.T) {
+	r, w := io.Pipe()
+	defer w.Close()
+	tty := &closeNotifier{ReadCloser: r, closed: make(chan struct{})}
+	l, _ := newLocalTerminal(tty)
+
+	_, err := l.Listen(context.Background(), "local-1-2", ListenOptions{Timeout: 50 * time.Millisecond})
+	if !errors.Is(err, ErrTimeout) {
+		t.Errorf("Listen = %v, want ErrTimeout", err)
+	}
+	// The terminal is closed so the pending read gives up
+	select {
+	case <-tty.closed:
+	case <-time.After(5 * time.Second):
+		t.Error("terminal left open after the timeout")
+	}
+}
+
+func TestLocalListenBadSession(t *testing.T) {
+	l, _ := newLocalTerminal(io.NopCloser(strings.NewReader("yes\n")))
+	if _, err := l.Listen(context.Background(), "slack-1-2", ListenOptions{Timeout: time.Second}); err == nil {
+		t.Error("Listen succeeded with a non-local session")
+	}
+}
@@ -0,0 +1,455 @@
+package transport
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/davedotdev/afk/internal/backoff"
+	"github.com/davedotdev/afk/internal/config"
+)
+
+func init() {
+	Register(Registration{
+		Name:    "matrix",
+		Service: "Matrix",
+		Usage:   "Send message via Matrix",
+		New: func(cfg *config.Config) (Transport, error) {
+			t, err := NewMatrix(cfg)
+			if err != nil {
+				return nil, err
+			}
+			return t, nil
+		},
+		Configured: func(cfg *config.Config) bool { return cfg.MatrixAccessToken != "" },
+		SetRecipient: func(cfg *config.Config, contact config.Contact) error {
+			return setAddress(&cfg.MatrixRoomID, contact.Matrix, "Matrix room ID")
+		},
+		Destination: func(cfg *config.Config) string { return cfg.MatrixRoomID },
+	})
+}
+
+// matrixSyncTimeout is how long the homeserver holds each /sync request
+const matrixSyncTimeout = 30 * time.Second
+
+// matrixMaxPages caps how many /messages pages are read back through a gap
+// in a /sync timeline
+const matrixMaxPages = 20
+
+// Matrix sends m.room.message events to a room with an access token and
+// waits for the reply with /sync long polling
+type Matrix struct {
+	homeserver      string
+	token           string
+	roomID          string
+	requireRelation bool
+	client          *http.Client
+}
+
+// NewMatrix creates a Matrix transport from the config
+func NewMatrix(cfg *config.Config) (*Matrix, error) {
+	if cfg.MatrixHomeserver == "" || cfg.MatrixAccessToken == "" || cfg.MatrixRoomID == "" {
+		return nil, errors.New("matrix not configured: set matrix_homeserver, matrix_access_token and matrix_room_id in " + config.Path())
+	}
+	return &Matrix{
+		homeserver:      strings.TrimRight(cfg.MatrixHomeserver, "/"),
+		token:           cfg.MatrixAccessToken,
+		roomID:          cfg.MatrixRoomID,
+		requireRelation: cfg.MatrixRequireRelation,
+		client:          &http.Client{Timeout: matrixSyncTimeout + 30*time.Second},
+	}, nil
+}
+
+// Name returns "Matrix"
+func (m *Matrix) Name() string {
+	return "Matrix"
+}
+
+// matrixEvent is a room event from /sync or /event
+type matrixEvent struct {
+	Type           string `json:"type"`
+	EventID        string `json:"event_id"`
+	Sender         string `json:"sender"`
+	OriginServerTS int64  `json:"origin_server_ts"`
+	Content        struct {
+		MsgType   string `json:"msgtype"`
+		Body      string `json:"body"`
+		RelatesTo *struct {
+			RelType   string `json:"rel_type"`
+			EventID   string `json:"event_id"`
+			InReplyTo *struct {
+				EventID string `json:"event_id"`
+			} `json:"m.in_reply_to"`
+		} `json:"m.relates_to"`
+	} `json:"content"`
+}
+
+// matrixError is a standard Matrix error response
+type matrixError struct {
+	status     int
+	code       string
+	message    string
+	retryAfter time.Duration
+}
+
+func (e *matrixError) Error() string {
+	return fmt.Sprintf("matrix %s: %s", e.code, e.message)
+}
+
+// permanent reports whether retrying cannot fix the error
+func (e *matrixError) permanent() bool {
+	return e.status >= 400 && e.status < 500 && e.status != http.StatusTooManyRequests
+}
+
+// Send posts the message as an m.text event
+func (m *Matrix) Send(ctx context.Context, msg Message) (*Receipt, error) {
+	text := msg.Text
+	if msg.SysName != "" {
+		text = msg.SysName + ":\n" + msg.Text
+	}
+
+	txnID := "afk" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	path := "/rooms/" + url.PathEscape(m.roomID) + "/send/m.room.message/" + txnID
+
+	var resp struct {
+		EventID string `json:"event_id"`
+	}
+	err := m.do(ctx, "PUT", path, nil, map[string]string{
+		"msgtype": "m.text",
+		"body":    text,
+	}, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	// Event IDs start with "$"; leave it out so the session is shell-safe
+	return &Receipt{
+		SessionID: "matrix-" + strings.TrimPrefix(resp.EventID, "$"),
+		MessageID: resp.EventID,
+	}, nil
+}
+
+// Listen waits for a message in the room sent after ours by someone else.
+// Threaded replies and replies that point at our event always match;
+// messages related to other events never do.
+func (m *Matrix) Listen(ctx context.Context, sessionID string, opts ListenOptions) (*Reply, error) {
+	eventID, ok := strings.CutPrefix(sessionID, "matrix-")
+	if !ok || eventID == "" {
+		return nil, fmt.Errorf("invalid Matrix session ID %q", sessionID)
+	}
+	eventID = "$" + eventID
+
+	return wait(ctx, opts, func(ctx context.Context) (*Reply, error) {
+		var sent *matrixEvent
+		since := ""
+		attempt := 0
+
+		for {
+			var err error
+			var reply *Reply
+			if sent == nil {
+				sent, err = m.event(ctx, eventID)
+			}
+			if err == nil {
+				reply, since, err = m.sync(ctx, sessionID, sent, since)
+			}
+
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil, ctx.Err()
+				}
+				var mxErr *matrixError
+				if errors.As(err, &mxErr) && mxErr.permanent() {
+					return nil, err
+				}
+
+				attempt++
+				var retryAfter time.Duration
+				if errors.As(err, &mxErr) {
+					retryAfter = mxErr.retryAfter
+				}
+				delay := backoff.Delay(attempt, retryAfter)
+				if opts.OnReconnect != nil {
+					opts.OnReconnect(attempt, delay, err)
+				}
+				if !sleep(ctx, delay) {
+					return nil, ctx.Err()
+				}
+				continue
+			}
+			attempt = 0
+
+			if reply != nil {
+				return reply, nil
+			}
+		}
+	})
+}
+
+// Health checks that the homeserver is reachable
+func (m *Matrix) Health(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", m.homeserver+"/_matrix/client/versions", nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("matrix request failed: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("matrix homeserver: %s", resp.Status)
+	}
+	return nil
+}
+
+// Validate checks the access token
+func (m *Matrix) Validate(ctx context.Context) error {
+	var whoami struct {
+		UserID string `json:"user_id"`
+	}
+	return m.do(ctx, "GET", "/account/whoami", nil, nil, &whoami)
+}
+
+// event fetches one event from the room
+func (m *Matrix) event(ctx context.Context, eventID string) (*matrixEvent, error) {
+	var ev matrixEvent
+	path := "/rooms/" + url.PathEscape(m.roomID) + "/event/" + url.PathEscape(eventID)
+	if err := m.do(ctx, "GET", path, nil, nil, &ev); err != nil {
+		return nil, err
+	}
+	return &ev, nil
+}
+
+// sync runs one /sync request and returns the first reply in the room's
+// timeline, plus the batch token for the next request. The first request
+// returns at once with recent history, so replies sent before afk started
+// listening are found too. When the timeline is limited (more events
+// arrived than it holds), the gap is paged back to the sent event so a
+// reply in a busy room is not skipped.
+func (m *Matrix) sync(ctx context.Context, sessionID string, sent *matrixEvent, since string) (*Reply, string, error) {
+	filter, _ := json.Marshal(map[string]interface{}{
+		"room": map[string]interface{}{
+			"rooms":    []string{m.roomID},
+			"timeline": map[string]interface{}{"types": []string{"m.room.message"}, "limit": 50},
+			"state":    map[string]interface{}{"types": []string{}},
+		},
+		"presence":     map[string]interface{}{"types": []string{}},
+		"account_data": map[string]interface{}{"types": []string{}},
+	})
+	params := url.Values{"filter": {string(filter)}, "timeout": {"0"}}
+	if since != "" {
+		params.Set("since", since)
+		params.Set("timeout", strconv.Itoa(int(matrixSyncTimeout/time.Millisecond)))
+	}
+
+	var resp struct {
+		NextBatch string `json:"next_batch"`
+		Rooms     struct {
+			Join map[string]struct {
+				Timeline struct {
+					Events    []matrixEvent `json:"events"`
+					Limited   bool          `json:"limited"`
+					PrevBatch string        `json:"prev_batch"`
+				} `json:"timeline"`
+			} `json:"join"`
+		} `json:"rooms"`
+	}
+	if err := m.do(ctx, "GET", "/sync", params, nil, &resp); err != nil {
+		return nil, since, err
+	}
+
+	timeline := resp.Rooms.Join[m.roomID].Timeline
+	events := timeline.Events
+	if timeline.Limited && timeline.PrevBatch != "" {
+		earlier, err := m.eventsBefore(ctx, timeline.PrevBatch, sent)
+		if err != nil {
+			return nil, since, err
+		}
+		events = append(earlier, events...)
+	}
+
+	for _, ev := range events {
+		if isMatrixReply(&ev, sent, m.requireRelation) {
+			return m.reply(ctx, sessionID, &ev), resp.NextBatch, nil
+		}
+	}
+	return nil, resp.NextBatch, nil
+}
+
+// eventsBefore pages back through the room's messages from the token from
+// until it reaches the sent event, and returns those after it, oldest first
+func (m *Matrix) eventsBefore(ctx context.Context, from string, sent *matrixEvent) ([]matrixEvent, error) {
+	filter, _ := json.Marshal(map[string]interface{}{"types": []string{"m.room.message"}})
+	path := "/rooms/" + url.PathEscape(m.roomID) + "/messages"
+
+	var newestFirst []matrixEvent
+	for page := 0; page < matrixMaxPages && from != ""; page++ {
+		params := url.Values{"from": {from}, "dir": {"b"}, "limit": {"100"}, "filter": {string(filter)}}
+		var resp struct {
+			Chunk []matrixEvent `json:"chunk"`
+			End   string        `json:"end"`
+		}
+		if err := m.do(ctx, "GET", path, params, nil, &resp); err != nil {
+			return nil, err
+		}
+
+		reached := len(resp.Chunk) == 0
+		for _, ev := range resp.Chunk {
+			if ev.EventID == sent.EventID || ev.OriginServerTS < sent.OriginServerTS {
+				reached = true
+				break
+			}
+			newestFirst = append(newestFirst, ev)
+		}
+		if reached {
+			break
+		}
+		from = resp.End
+	}
+
+	events := make([]matrixEvent, len(newestFirst))
+	for i, ev := range newestFirst {
+		events[len(events)-1-i] = ev
+	}
+	return events, nil
+}
+
+// reply converts an event to a Reply, resolving the sender's display name
+func (m *Matrix) reply(ctx context.Context, sessionID string, ev *matrixEvent) *Reply {
+	from := ev.Sender
+	var profile struct {
+		DisplayName string `json:"displayname"`
+	}
+	if err := m.do(ctx, "GET", "/profile/"+url.PathEscape(ev.Sender)+"/displayname", nil, nil, &profile); err == nil && profile.DisplayName != "" {
+		from = profile.DisplayName
+	}
+
+	body := ev.Content.Body
+	if ev.Content.RelatesTo != nil && ev.Content.RelatesTo.InReplyTo != nil {
+		body = stripReplyFallback(body)
+	}
+
+	return &Reply{
+		SessionID: sessionID,
+		From:      from,
+		Channel:   m.Name(),
+		Content:   body,
+	}
+}
+
+// do calls a client-server API endpoint under /_matrix/client/v3
+func (m *Matrix) do(ctx context.Context, method, path string, params url.Values, body interface{}, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to marshal request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	endpoint := m.homeserver + "/_matrix/client/v3" + path
+	if len(params) > 0 {
+		endpoint += "?" + params.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, reader)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Authorization", "Bearer "+m.token)
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("matrix request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var mxErr struct {
+			ErrCode      string `json:"errcode"`
+			Error        string `json:"error"`
+			RetryAfterMs int64  `json:"retry_after_ms"`
+		}
+		json.Unmarshal(data, &mxErr)
+		if mxErr.ErrCode == "" {
+			mxErr.ErrCode, mxErr.Error = strconv.Itoa(resp.StatusCode), resp.Status
+		}
+		return &matrixError{
+			status:     resp.StatusCode,
+			code:       mxErr.ErrCode,
+			message:    mxErr.Error,
+			retryAfter: time.Duration(mxErr.RetryAfterMs) * time.Millisecond,
+		}
+	}
+
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	return nil
+}
+
+// isMatrixReply reports whether ev answers the sent event: a later text
+// message from someone else that is either related to the sent event or,
+// unless requireRelation is set, related to nothing
+func isMatrixReply(ev, sent *matrixEvent, requireRelation bool) bool {
+	if ev.Type != "m.room.message" || ev.EventID == sent.EventID || ev.Sender == sent.Sender {
+		return false
+	}
+	if ev.OriginServerTS < sent.OriginServerTS || ev.Content.Body == "" {
+		return false
+	}
+	switch ev.Content.MsgType {
+	case "m.text", "m.notice", "m.emote":
+	default:
+		return false
+	}
+
+	related := ""
+	if rel := ev.Content.RelatesTo; rel != nil {
+		switch {
+		case rel.RelType == "m.thread":
+			related = rel.EventID
+		case rel.RelType == "m.replace":
+			// Edits are not answers
+			return false
+		case rel.InReplyTo != nil:
+			related = rel.InReplyTo.EventID
+		}
+	}
+
+	if related != "" {
+		return related == sent.EventID
+	}
+	return !requireRelation
+}
+
+// stripReplyFallback removes the quoted "> <@user> ..." block that older
+// clients prepend to the body of a reply
+func stripReplyFallback(body string) string {
+	if !strings.HasPrefix(body, "> ") {
+		return body
+	}
+	lines := strings.Split(body, "\n")
+	i := 0
+	for i < len(lines) && strings.HasPrefix(lines[i], ">") {
+		i++
+	}
+	return strings.TrimSpace(strings.Join(lines[i:], "\n"))
+}
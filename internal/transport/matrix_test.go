@@ -0,0 +1,221 @@
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/davedotdev/afk/internal/config"
+)
+
+const matrixTestRoom = "!ops:example.org"
+
+// matrixStandIn is a local homeserver holding one room. Each /sync call is
+// answered with the next scripted timeline; once the script runs out, syncs
+// hang until the client gives up, as a long poll with nothing new would.
+type matrixStandIn struct {
+	server   *httptest.Server
+	syncs    []map[string]interface{}          // Room timelines, in order
+	messages map[string]map[string]interface{} // /messages responses by from token
+
+	mu     sync.Mutex
+	sent   map[string]interface{} // Last message body sent
+	since  []string               // since of each /sync call
+	paging []string               // from of each /messages call
+}
+
+func newMatrixStandIn(t *testing.T) *matrixStandIn {
+	s := &matrixStandIn{}
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer mx-test" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"errcode":"M_UNKNOWN_TOKEN","error":"Invalid access token passed."}`))
+			return
+		}
+		path := strings.TrimPrefix(r.URL.Path, "/_matrix/client/v3")
+		room := "/rooms/" + matrixTestRoom
+
+		switch {
+		case r.Method == "PUT" && strings.HasPrefix(path, room+"/send/m.room.message/"):
+			s.mu.Lock()
+			json.NewDecoder(r.Body).Decode(&s.sent)
+			s.mu.Unlock()
+			json.NewEncoder(w).Encode(map[string]string{"event_id": "$question"})
+		case path == room+"/event/$question":
+			json.NewEncoder(w).Encode(matrixMessage("$question", "@afk:example.org", 1000, "Deploy?", ""))
+		case path == "/sync":
+			s.mu.Lock()
+			n := len(s.since)
+			s.since = append(s.since, r.URL.Query().Get("since"))
+			s.mu.Unlock()
+			if n >= len(s.syncs) {
+				<-r.Context().Done()
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"next_batch": fmt.Sprintf("s%d", n+1),
+				"rooms":      map[string]interface{}{"join": map[string]interface{}{matrixTestRoom: map[string]interface{}{"timeline": s.syncs[n]}}},
+			})
+		case path == room+"/messages":
+			from := r.URL.Query().Get("from")
+			s.mu.Lock()
+			s.paging = append(s.paging, from)
+			s.mu.Unlock()
+			if r.URL.Query().Get("dir") != "b" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			json.NewEncoder(w).Encode(s.messages[from])
+		case path == "/profile/@dev:example.org/displayname":
+			json.NewEncoder(w).Encode(map[string]string{"displayname": "Dev"})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errcode":"M_NOT_FOUND","error":"Not found"}`))
+		}
+	}))
+	t.Cleanup(s.server.Close)
+	return s
+}
+
+func (s *matrixStandIn) transport(t *testing.T) *Matrix {
+	t.Helper()
+	m, err := NewMatrix(&config.Config{MatrixHomeserver: s.server.URL, MatrixAccessToken: "mx-test", MatrixRoomID: matrixTestRoom})
+	if err != nil {
+		t.Fatalf("NewMatrix: %v", err)
+	}
+	return m
+}
+
+// matrixMessage is an m.text event, in reply to inReplyTo if set
+func matrixMessage(id, sender string, ts int64, body, inReplyTo string) map[string]interface{} {
+	content := map[string]interface{}{"msgtype": "m.text", "body": body}
+	if inReplyTo != "" {
+		content["m.relates_to"] = map[string]interface{}{"m.in_reply_to": map[string]string{"event_id": inReplyTo}}
+	}
+	return map[string]interface{}{"type": "m.room.message", "event_id": id, "sender": sender, "origin_server_ts": ts, "content": content}
+}
+
+// matrixChatter is n messages from another conversation in the room,
+// newest first, with timestamps counting down from ts
+func matrixChatter(prefix string, n int, ts int64) []interface{} {
+	events := make([]interface{}, n)
+	for i := range events {
+		events[i] = matrixMessage(fmt.Sprintf("$%s%d", prefix, i), "@other:example.org", ts-int64(i), "chatter", "$elsewhere")
+	}
+	return events
+}
+
+func TestMatrixSend(t *testing.T) {
+	s := newMatrixStandIn(t)
+
+	receipt, err := s.transport(t).Send(context.Background(), Message{Text: "Deploy?", SysName: "CI"})
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if receipt.SessionID != "matrix-question" || receipt.MessageID != "$question" {
+		t.Errorf("receipt = %+v", receipt)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.sent["msgtype"] != "m.text" || s.sent["body"] != "CI:\nDeploy?" {
+		t.Errorf("sent %v", s.sent)
+	}
+}
+
+func TestMatrixSendUnauthorized(t *testing.T) {
+	s := newMatrixStandIn(t)
+	m := s.transport(t)
+	m.token = "wrong"
+
+	_, err := m.Send(context.Background(), Message{Text: "hi"})
+	if err == nil || !strings.Contains(err.Error(), "M_UNKNOWN_TOKEN") {
+		t.Errorf("Send = %v, want M_UNKNOWN_TOKEN", err)
+	}
+}
+
+func TestMatrixListen(t *testing.T) {
+	s := newMatrixStandIn(t)
+	s.syncs = []map[string]interface{}{
+		{"events": []interface{}{
+			matrixMessage("$question", "@afk:example.org", 1000, "Deploy?", ""),
+			matrixMessage("$before", "@dev:example.org", 900, "sent before the question", ""),
+			matrixMessage("$other", "@dev:example.org", 1100, "about something else", "$elsewhere"),
+		}},
+		{"events": []interface{}{
+			matrixMessage("$reply", "@dev:example.org", 1200, "> <@afk:example.org> Deploy?\n\nship it", "$question"),
+		}},
+	}
+
+	reply, err := s.transport(t).Listen(context.Background(), "matrix-question", ListenOptions{Timeout: 5 * time.Second})
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	if reply.Content != "ship it" || reply.From != "Dev" || reply.Channel != "Matrix" {
+		t.Errorf("reply = %+v", reply)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.since) != 2 || s.since[0] != "" || s.since[1] != "s1" {
+		t.Errorf("sync since %q, want the first sync's next_batch on the second", s.since)
+	}
+	if len(s.paging) != 0 {
+		t.Errorf("paged %q through a timeline with no gap", s.paging)
+	}
+}
+
+func TestMatrixListenPagesThroughGap(t *testing.T) {
+	s := newMatrixStandIn(t)
+	// More than a timeline's worth of chatter arrived after the reply
+	s.syncs = []map[string]interface{}{
+		{"limited": true, "prev_batch": "p1", "events": matrixOldestFirst(matrixChatter("late", 50, 2000))},
+	}
+	s.messages = map[string]map[string]interface{}{
+		"p1": {"chunk": matrixChatter("mid", 100, 1900), "end": "p2"},
+		"p2": {"chunk": append(
+			append(matrixChatter("early", 10, 1700),
+				matrixMessage("$second", "@dev:example.org", 1300, "second thoughts", ""),
+				matrixMessage("$reply", "@dev:example.org", 1200, "yes", "")),
+			matrixMessage("$question", "@afk:example.org", 1000, "Deploy?", ""),
+			matrixMessage("$before", "@dev:example.org", 900, "sent before the question", ""),
+		), "end": "p3"},
+	}
+
+	reply, err := s.transport(t).Listen(context.Background(), "matrix-question", ListenOptions{Timeout: 5 * time.Second})
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	if reply.Content != "yes" || reply.From != "Dev" {
+		t.Errorf("reply = %+v, want the earliest reply", reply)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if strings.Join(s.paging, ",") != "p1,p2" {
+		t.Errorf("paged from %q, want p1,p2 and a stop at the question", s.paging)
+	}
+}
+
+func TestMatrixListenBadSession(t *testing.T) {
+	s := newMatrixStandIn(t)
+	for _, bad := range []string{"", "matrix-", "slack-1-2"} {
+		if _, err := s.transport(t).Listen(context.Background(), bad, ListenOptions{Timeout: time.Second}); err == nil {
+			t.Errorf("Listen(%q) succeeded", bad)
+		}
+	}
+}
+
+// matrixOldestFirst returns events oldest first, as a /sync timeline holds them
+func matrixOldestFirst(events []interface{}) []interface{} {
+	out := make([]interface{}, len(events))
+	for i, ev := range events {
+		out[len(out)-1-i] = ev
+	}
+	return out
+}
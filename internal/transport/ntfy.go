@@ -0,0 +1,377 @@
+package transport
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/davedotdev/afk/internal/backoff"
+	"github.com/davedotdev/afk/internal/config"
+	"github.com/davedotdev/afk/internal/sse"
+)
+
+func init() {
+	Register(Registration{
+		Name:    "ntfy",
+		Service: "ntfy",
+		Usage:   "Send push notification via ntfy",
+		New: func(cfg *config.Config) (Transport, error) {
+			t, err := NewNtfy(cfg)
+			if err != nil {
+				return nil, err
+			}
+			return t, nil
+		},
+		Configured: func(cfg *config.Config) bool { return cfg.NtfyTopic != "" },
+		SetRecipient: func(cfg *config.Config, contact config.Contact) error {
+			return setAddress(&cfg.NtfyTopic, contact.Ntfy, "ntfy topic")
+		},
+		Destination: func(cfg *config.Config) string { return cfg.NtfyTopic },
+	})
+}
+
+// ntfyMaxActions is the most action buttons ntfy shows on a notification
+const ntfyMaxActions = 3
+
+// Ntfy publishes push notifications to an ntfy topic. Options and
+// approvals become HTTP action buttons that publish the answer to a reply
+// topic of the session's own, which afk subscribes to over SSE.
+type Ntfy struct {
+	server     string
+	topic      string
+	replyTopic string
+	token      string
+	client     *http.Client
+}
+
+// NewNtfy creates an ntfy transport from the config
+func NewNtfy(cfg *config.Config) (*Ntfy, error) {
+	if cfg.NtfyTopic == "" {
+		return nil, errors.New("ntfy not configured: set ntfy_topic in " + config.Path())
+	}
+	replyTopic := cfg.NtfyReplyTopic
+	if replyTopic == "" {
+		replyTopic = cfg.NtfyTopic + "-reply"
+	}
+	return &Ntfy{
+		server:     strings.TrimRight(cfg.NtfyServer, "/"),
+		topic:      cfg.NtfyTopic,
+		replyTopic: replyTopic,
+		token:      cfg.NtfyToken,
+		// No timeout - the subscription is a long-lived stream bounded by context
+		client: &http.Client{},
+	}, nil
+}
+
+// Name returns "ntfy"
+func (n *Ntfy) Name() string {
+	return "ntfy"
+}
+
+// ntfyEvent is a message on an ntfy SSE or JSON stream
+type ntfyEvent struct {
+	ID      string `json:"id"`
+	Time    int64  `json:"time"`
+	Event   string `json:"event"`
+	Topic   string `json:"topic"`
+	Title   string `json:"title"`
+	Message string `json:"message"`
+}
+
+// ntfyError is a non-2xx ntfy response
+type ntfyError struct {
+	status  int
+	message string
+}
+
+func (e *ntfyError) Error() string {
+	return fmt.Sprintf("ntfy: %d %s", e.status, e.message)
+}
+
+// Send publishes the notification. Each action button posts its label to
+// the session's reply topic, titled with the session ID. The buttons carry
+// no token, since anyone who can read the topic can see them.
+func (n *Ntfy) Send(ctx context.Context, msg Message) (*Receipt, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return nil, fmt.Errorf("failed to generate session ID: %w", err)
+	}
+	sessionID := fmt.Sprintf("ntfy-%d-%s", time.Now().Unix(), hex.EncodeToString(b))
+	replyTopic, err := n.sessionTopic(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	labels := msg.Options
+	if msg.Approval {
+		labels = []string{"Approve", "Reject"}
+	}
+	if len(labels) > ntfyMaxActions {
+		// Too many to show; the numbered list in the text still applies
+		labels = nil
+	}
+
+	var actions []map[string]interface{}
+	for _, label := range labels {
+		actions = append(actions, map[string]interface{}{
+			"action":  "http",
+			"label":   label,
+			"url":     n.server + "/" + url.PathEscape(replyTopic),
+			"method":  "POST",
+			"headers": map[string]string{"X-Title": sessionID},
+			"body":    label,
+			"clear":   true,
+		})
+	}
+
+	title := msg.SysName
+	if title == "" {
+		title = "afk"
+	}
+	publish := map[string]interface{}{
+		"topic":    n.topic,
+		"title":    title,
+		"message":  msg.Text,
+		"priority": 4,
+		"tags":     []string{"question"},
+	}
+	if len(actions) > 0 {
+		publish["actions"] = actions
+	}
+
+	body, err := json.Marshal(publish)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", n.server, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	var sent ntfyEvent
+	if err := n.do(req, &sent); err != nil {
+		return nil, err
+	}
+
+	return &Receipt{
+		SessionID: sessionID,
+		MessageID: sent.ID,
+	}, nil
+}
+
+// Listen subscribes to the session's reply topic and returns the first
+// message there titled with the session ID: a button press, or a free-text
+// answer published with that title
+func (n *Ntfy) Listen(ctx context.Context, sessionID string, opts ListenOptions) (*Reply, error) {
+	sentAt, _, err := parseNtfySession(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	replyTopic, err := n.sessionTopic(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	return wait(ctx, opts, func(ctx context.Context) (*Reply, error) {
+		since := strconv.FormatInt(sentAt, 10)
+		attempt := 0
+		for {
+			reply, lastID, connected, err := n.subscribe(ctx, sessionID, replyTopic, since)
+			if reply != nil {
+				return reply, nil
+			}
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			var ntfyErr *ntfyError
+			if errors.As(err, &ntfyErr) && ntfyErr.status >= 400 && ntfyErr.status < 500 && ntfyErr.status != http.StatusTooManyRequests {
+				return nil, err
+			}
+			if lastID != "" {
+				since = lastID
+			}
+			if err == nil {
+				err = errors.New("subscription closed")
+			}
+
+			if connected {
+				attempt = 0
+			}
+			attempt++
+			delay := backoff.Delay(attempt, 0)
+			if opts.OnReconnect != nil {
+				opts.OnReconnect(attempt, delay, err)
+			}
+			if !sleep(ctx, delay) {
+				return nil, ctx.Err()
+			}
+		}
+	})
+}
+
+// Health checks the server's health endpoint
+func (n *Ntfy) Health(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", n.server+"/v1/health", nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	var health struct {
+		Healthy bool `json:"healthy"`
+	}
+	if err := n.do(req, &health); err != nil {
+		return err
+	}
+	if !health.Healthy {
+		return errors.New("ntfy server reports unhealthy")
+	}
+	return nil
+}
+
+// Validate checks access to the notification topic and to a reply topic
+// named like a question's. The bare reply prefix is never used, and access
+// control may grant the per-question topics only by wildcard.
+func (n *Ntfy) Validate(ctx context.Context) error {
+	replyTopic, err := n.sessionTopic("ntfy-0-probe")
+	if err != nil {
+		return err
+	}
+	for _, topic := range []string{n.topic, replyTopic} {
+		req, err := http.NewRequestWithContext(ctx, "GET", n.server+"/"+url.PathEscape(topic)+"/auth", nil)
+		if err != nil {
+			return fmt.Errorf("failed to create request: %w", err)
+		}
+		var auth struct {
+			Success bool `json:"success"`
+		}
+		if err := n.do(req, &auth); err != nil {
+			return fmt.Errorf("topic %s: %w", topic, err)
+		}
+	}
+	return nil
+}
+
+// subscribe reads a reply topic's SSE stream from since (a Unix time or
+// message ID) until an answer arrives or the stream ends. It returns the
+// last message ID seen so a reconnect can resume after it.
+func (n *Ntfy) subscribe(ctx context.Context, sessionID, replyTopic, since string) (reply *Reply, lastID string, connected bool, err error) {
+	endpoint := n.server + "/" + url.PathEscape(replyTopic) + "/sse?since=" + url.QueryEscape(since)
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return nil, "", false, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	if n.token != "" {
+		req.Header.Set("Authorization", "Bearer "+n.token)
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return nil, "", false, fmt.Errorf("ntfy subscribe failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, "", false, ntfyStatusError(resp.StatusCode, data)
+	}
+
+	decoder := sse.NewDecoder(resp.Body)
+	for {
+		frame, err := decoder.Next()
+		if err != nil {
+			if err == io.EOF {
+				err = nil
+			}
+			return nil, lastID, true, err
+		}
+
+		var ev ntfyEvent
+		if err := json.Unmarshal([]byte(frame.Data), &ev); err != nil || ev.Event != "message" {
+			continue
+		}
+		lastID = ev.ID
+
+		// Answers carry the session as their title, which only the
+		// notification's readers know
+		if ev.Title != sessionID {
+			continue
+		}
+		if strings.TrimSpace(ev.Message) == "" {
+			continue
+		}
+		return &Reply{
+			SessionID: sessionID,
+			From:      "ntfy",
+			Channel:   n.Name(),
+			Content:   ev.Message,
+		}, lastID, true, nil
+	}
+}
+
+func (n *Ntfy) do(req *http.Request, out interface{}) error {
+	if n.token != "" {
+		req.Header.Set("Authorization", "Bearer "+n.token)
+	}
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("ntfy request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return ntfyStatusError(resp.StatusCode, data)
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	return nil
+}
+
+// ntfyStatusError builds an error from an ntfy error body
+func ntfyStatusError(status int, data []byte) error {
+	var body struct {
+		Error string `json:"error"`
+	}
+	if json.Unmarshal(data, &body) != nil || body.Error == "" {
+		body.Error = http.StatusText(status)
+	}
+	return &ntfyError{status: status, message: body.Error}
+}
+
+// sessionTopic returns the reply topic for one session: the configured
+// reply topic with the session's random token appended
+func (n *Ntfy) sessionTopic(sessionID string) (string, error) {
+	_, token, err := parseNtfySession(sessionID)
+	if err != nil {
+		return "", err
+	}
+	return n.replyTopic + "-" + token, nil
+}
+
+// parseNtfySession returns the send time and token encoded in
+// "ntfy-<unix>-<token>"
+func parseNtfySession(sessionID string) (sentAt int64, token string, err error) {
+	rest, ok := strings.CutPrefix(sessionID, "ntfy-")
+	if ok {
+		var unix string
+		unix, token, ok = strings.Cut(rest, "-")
+		if sentAt, err := strconv.ParseInt(unix, 10, 64); ok && err == nil && token != "" {
+			return sentAt, token, nil
+		}
+	}
+	return 0, "", fmt.Errorf("invalid ntfy session ID %q", sessionID)
+}
@@ -0,0 +1,160 @@
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/davedotdev/afk/internal/config"
+)
+
+// ntfyStandIn publishes to and streams topics like an ntfy server
+type ntfyStandIn struct {
+	server *httptest.Server
+
+	mu         sync.Mutex
+	published  map[string]interface{} // Last notification published
+	subscribed []string               // Topics subscribed to
+	stream     []ntfyEvent            // Sent to every subscriber
+}
+
+func newNtfyStandIn(t *testing.T) *ntfyStandIn {
+	s := &ntfyStandIn{}
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer tk_secret" {
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `{"error":"forbidden"}`)
+			return
+		}
+		if r.Method == "POST" && r.URL.Path == "/" {
+			s.mu.Lock()
+			json.NewDecoder(r.Body).Decode(&s.published)
+			s.mu.Unlock()
+			fmt.Fprint(w, `{"id":"n1","event":"message"}`)
+			return
+		}
+		if topic, ok := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/"), "/auth"); ok {
+			// Access is granted on the topic and, by wildcard, its reply topics
+			if topic != "afk-alerts" && !strings.HasPrefix(topic, "afk-alerts-reply-") {
+				w.WriteHeader(http.StatusForbidden)
+				fmt.Fprint(w, `{"error":"forbidden"}`)
+				return
+			}
+			fmt.Fprint(w, `{"success":true}`)
+			return
+		}
+		topic, ok := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/"), "/sse")
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		s.mu.Lock()
+		s.subscribed = append(s.subscribed, topic)
+		stream := s.stream
+		s.mu.Unlock()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, ev := range stream {
+			ev.Event, ev.Topic = "message", topic
+			data, _ := json.Marshal(ev)
+			fmt.Fprintf(w, "id: %s\ndata: %s\n\n", ev.ID, data)
+		}
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	t.Cleanup(s.server.Close)
+	return s
+}
+
+func (s *ntfyStandIn) transport(t *testing.T) *Ntfy {
+	t.Helper()
+	n, err := NewNtfy(&config.Config{NtfyServer: s.server.URL, NtfyTopic: "afk-alerts", NtfyToken: "tk_secret"})
+	if err != nil {
+		t.Fatalf("NewNtfy: %v", err)
+	}
+	return n
+}
+
+func TestNtfySendButtons(t *testing.T) {
+	s := newNtfyStandIn(t)
+
+	receipt, err := s.transport(t).Send(context.Background(), Message{Text: "Deploy?", Approval: true})
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	_, token, err := parseNtfySession(receipt.SessionID)
+	if err != nil {
+		t.Fatalf("session %q: %v", receipt.SessionID, err)
+	}
+
+	s.mu.Lock()
+	published, _ := json.Marshal(s.published)
+	s.mu.Unlock()
+	if strings.Contains(string(published), "tk_secret") {
+		t.Errorf("token published in the notification: %s", published)
+	}
+	for _, want := range []string{
+		`"url":"` + s.server.URL + `/afk-alerts-reply-` + token + `"`,
+		`"X-Title":"` + receipt.SessionID + `"`,
+		`"label":"Approve"`,
+	} {
+		if !strings.Contains(string(published), want) {
+			t.Errorf("notification %s lacks %s", published, want)
+		}
+	}
+}
+
+func TestNtfyListenSessionTitle(t *testing.T) {
+	const sessionID = "ntfy-1700000000-00112233aabbccdd"
+	s := newNtfyStandIn(t)
+	s.stream = []ntfyEvent{
+		{ID: "m1", Message: "Approve"}, // Untitled: anyone could have sent it
+		{ID: "m2", Title: "ntfy-1700000000-ffffffffffffffff", Message: "Approve"},
+		{ID: "m3", Title: sessionID, Message: "   "},
+		{ID: "m4", Title: sessionID, Message: "Reject"},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	reply, err := s.transport(t).Listen(ctx, sessionID, ListenOptions{Timeout: 5 * time.Second})
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	if reply.Content != "Reject" {
+		t.Errorf("Content = %q, want the titled answer", reply.Content)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.subscribed) != 1 || s.subscribed[0] != "afk-alerts-reply-00112233aabbccdd" {
+		t.Errorf("subscribed to %q, want the session's reply topic", s.subscribed)
+	}
+}
+
+func TestParseNtfySession(t *testing.T) {
+	for _, bad := range []string{"", "ntfy-", "ntfy-123", "ntfy-123-", "ntfy-x-abc", "slack-1-2"} {
+		if _, _, err := parseNtfySession(bad); err == nil {
+			t.Errorf("parseNtfySession(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestNtfyValidateReplyTopics(t *testing.T) {
+	s := newNtfyStandIn(t)
+	n := s.transport(t)
+	if err := n.Validate(context.Background()); err != nil {
+		t.Errorf("Validate with a wildcard grant: %v", err)
+	}
+
+	// A reply prefix the wildcard grant does not cover
+	n.replyTopic = "afk-alerts-replies"
+	if err := n.Validate(context.Background()); err == nil || !strings.Contains(err.Error(), "afk-alerts-replies-probe") {
+		t.Errorf("Validate = %v, want the reply topics refused", err)
+	}
+}
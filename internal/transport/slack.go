@@ -0,0 +1,537 @@
+package transport
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/davedotdev/afk/internal/backoff"
+	"github.com/davedotdev/afk/internal/config"
+	"github.com/davedotdev/afk/internal/ws"
+)
+
+func init() {
+	Register(Registration{
+		Name:    "slack",
+		Service: "Slack",
+		Usage:   "Send message via Slack",
+		New: func(cfg *config.Config) (Transport, error) {
+			t, err := NewSlack(cfg)
+			if err != nil {
+				return nil, err
+			}
+			return t, nil
+		},
+		Configured: func(cfg *config.Config) bool { return cfg.SlackBotToken != "" },
+		SetRecipient: func(cfg *config.Config, contact config.Contact) error {
+			return setAddress(&cfg.SlackChannel, contact.Slack, "Slack user ID")
+		},
+		Destination: func(cfg *config.Config) string { return cfg.SlackChannel },
+	})
+}
+
+// slackPollInterval is how often replies are polled without Socket Mode
+const slackPollInterval = 5 * time.Second
+
+// slackMaxButtons is the most elements an actions block may hold
+const slackMaxButtons = 25
+
+// errSocketRefresh means Slack asked the client to reconnect
+var errSocketRefresh = errors.New("socket mode refresh requested")
+
+// Slack posts messages with a bot token and waits for the threaded reply,
+// over Socket Mode when an app-level token is configured and by polling
+// conversations.replies otherwise
+type Slack struct {
+	apiURL   string
+	botToken string
+	appToken string
+	channel  string
+	client   *http.Client
+}
+
+// NewSlack creates a Slack transport from the config
+func NewSlack(cfg *config.Config) (*Slack, error) {
+	if cfg.SlackBotToken == "" || cfg.SlackChannel == "" {
+		return nil, errors.New("slack not configured: set slack_bot_token and slack_channel in " + config.Path())
+	}
+	return &Slack{
+		apiURL:   strings.TrimRight(cfg.SlackAPIURL, "/"),
+		botToken: cfg.SlackBotToken,
+		appToken: cfg.SlackAppToken,
+		channel:  cfg.SlackChannel,
+		client:   &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+// Name returns "Slack"
+func (s *Slack) Name() string {
+	return "Slack"
+}
+
+// slackResponse is the envelope common to all Web API responses
+type slackResponse struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// slackMessage is a message from the Web API or an Events API payload
+type slackMessage struct {
+	Type     string `json:"type"`
+	Subtype  string `json:"subtype,omitempty"`
+	Channel  string `json:"channel,omitempty"`
+	User     string `json:"user,omitempty"`
+	BotID    string `json:"bot_id,omitempty"`
+	Text     string `json:"text"`
+	TS       string `json:"ts"`
+	ThreadTS string `json:"thread_ts,omitempty"`
+}
+
+// slackAPIError is an ok:false response; retrying will not help
+type slackAPIError struct {
+	method string
+	code   string
+}
+
+func (e *slackAPIError) Error() string {
+	return fmt.Sprintf("slack %s: %s", e.method, e.code)
+}
+
+// slackRateLimited is a 429 response
+type slackRateLimited struct {
+	retryAfter time.Duration
+}
+
+func (e *slackRateLimited) Error() string {
+	return fmt.Sprintf("slack rate limited, retry after %s", e.retryAfter)
+}
+
+// Send posts the message and returns a session encoding its channel and
+// timestamp. Over Socket Mode, options and approvals are offered as
+// buttons; a click arrives as an interactive payload.
+func (s *Slack) Send(ctx context.Context, msg Message) (*Receipt, error) {
+	text := msg.Text
+	if msg.SysName != "" {
+		text = fmt.Sprintf("*%s:*\n%s", msg.SysName, msg.Text)
+	}
+
+	params := map[string]interface{}{
+		"channel": s.channel,
+		"text":    text,
+	}
+	labels := msg.Options
+	if msg.Approval {
+		labels = []string{"Approve", "Reject"}
+	}
+	if s.appToken != "" && len(labels) > 0 && len(labels) <= slackMaxButtons {
+		var buttons []map[string]interface{}
+		for i, label := range labels {
+			buttons = append(buttons, map[string]interface{}{
+				"type":      "button",
+				"action_id": fmt.Sprintf("afk_%d", i+1),
+				"text":      map[string]string{"type": "plain_text", "text": label},
+				"value":     label,
+			})
+		}
+		params["blocks"] = []map[string]interface{}{
+			{"type": "section", "text": map[string]string{"type": "mrkdwn", "text": text}},
+			{"type": "actions", "elements": buttons},
+		}
+	}
+
+	var resp struct {
+		slackResponse
+		Channel string `json:"channel"`
+		TS      string `json:"ts"`
+	}
+	err := s.post(ctx, s.botToken, "chat.postMessage", params, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Receipt{
+		SessionID: "slack-" + resp.Channel + "-" + resp.TS,
+		MessageID: resp.TS,
+	}, nil
+}
+
+// Listen waits for a reply in the message's thread (or, in a direct
+// message, any later message from the user)
+func (s *Slack) Listen(ctx context.Context, sessionID string, opts ListenOptions) (*Reply, error) {
+	channel, ts, err := parseSlackSession(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	return wait(ctx, opts, func(ctx context.Context) (*Reply, error) {
+		if s.appToken != "" {
+			return s.listenSocket(ctx, sessionID, channel, ts, opts.OnReconnect)
+		}
+		return s.poll(ctx, sessionID, channel, ts)
+	})
+}
+
+// Health checks that the Slack API is reachable
+func (s *Slack) Health(ctx context.Context) error {
+	var resp slackResponse
+	return s.post(ctx, "", "api.test", nil, &resp)
+}
+
+// Validate checks the bot token
+func (s *Slack) Validate(ctx context.Context) error {
+	var resp slackResponse
+	return s.post(ctx, s.botToken, "auth.test", nil, &resp)
+}
+
+// listenSocket waits on a Socket Mode connection, reconnecting when it
+// drops. Replies posted while disconnected are caught by a history check
+// before each connection.
+func (s *Slack) listenSocket(ctx context.Context, sessionID, channel, ts string, onReconnect ReconnectHandler) (*Reply, error) {
+	attempt := 0
+	for {
+		reply, err := s.findReply(ctx, sessionID, channel, ts)
+		if reply != nil || isSlackPermanent(err) {
+			return reply, err
+		}
+
+		connected := false
+		if err == nil {
+			reply, connected, err = s.socketSession(ctx, sessionID, channel, ts)
+			if reply != nil || isSlackPermanent(err) {
+				return reply, err
+			}
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		// Slack rotates Socket Mode connections; reconnect straight away
+		if errors.Is(err, errSocketRefresh) {
+			attempt = 0
+			continue
+		}
+
+		if connected {
+			attempt = 0
+		}
+		attempt++
+		var retryAfter time.Duration
+		var limited *slackRateLimited
+		if errors.As(err, &limited) {
+			retryAfter = limited.retryAfter
+		}
+		delay := backoff.Delay(attempt, retryAfter)
+		if onReconnect != nil {
+			onReconnect(attempt, delay, err)
+		}
+		if !sleep(ctx, delay) {
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// socketSession opens one Socket Mode connection and reads events until a
+// reply arrives or the connection ends
+func (s *Slack) socketSession(ctx context.Context, sessionID, channel, ts string) (*Reply, bool, error) {
+	var open struct {
+		slackResponse
+		URL string `json:"url"`
+	}
+	if err := s.post(ctx, s.appToken, "apps.connections.open", nil, &open); err != nil {
+		return nil, false, err
+	}
+
+	conn, err := ws.Dial(ctx, open.URL, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	defer conn.Close()
+
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return nil, true, err
+		}
+
+		var envelope struct {
+			EnvelopeID string          `json:"envelope_id"`
+			Type       string          `json:"type"`
+			Payload    json.RawMessage `json:"payload"`
+		}
+		if err := json.Unmarshal(data, &envelope); err != nil {
+			continue
+		}
+
+		// Every envelope must be acknowledged or Slack redelivers it
+		if envelope.EnvelopeID != "" {
+			ack, _ := json.Marshal(map[string]string{"envelope_id": envelope.EnvelopeID})
+			if err := conn.WriteMessage(ws.TextMessage, ack); err != nil {
+				return nil, true, err
+			}
+		}
+
+		switch envelope.Type {
+		case "disconnect":
+			return nil, true, errSocketRefresh
+		case "events_api":
+			var payload struct {
+				Event slackMessage `json:"event"`
+			}
+			if json.Unmarshal(envelope.Payload, &payload) == nil && isSlackReply(&payload.Event, channel, ts) {
+				return s.reply(ctx, sessionID, &payload.Event), true, nil
+			}
+		case "interactive":
+			var action slackAction
+			if json.Unmarshal(envelope.Payload, &action) == nil {
+				if reply := s.actionReply(ctx, sessionID, &action, channel, ts); reply != nil {
+					return reply, true, nil
+				}
+			}
+		}
+	}
+}
+
+// poll checks for a reply every slackPollInterval
+func (s *Slack) poll(ctx context.Context, sessionID, channel, ts string) (*Reply, error) {
+	for {
+		reply, err := s.findReply(ctx, sessionID, channel, ts)
+		if reply != nil || isSlackPermanent(err) {
+			return reply, err
+		}
+
+		delay := slackPollInterval
+		var limited *slackRateLimited
+		if errors.As(err, &limited) && limited.retryAfter > delay {
+			delay = limited.retryAfter
+		}
+		if !sleep(ctx, delay) {
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// findReply looks for a reply already posted in the thread or, for direct
+// messages, in the conversation after the original message
+func (s *Slack) findReply(ctx context.Context, sessionID, channel, ts string) (*Reply, error) {
+	params := url.Values{"channel": {channel}, "ts": {ts}, "oldest": {ts}}
+	var thread struct {
+		slackResponse
+		Messages []slackMessage `json:"messages"`
+	}
+	if err := s.get(ctx, "conversations.replies", params, &thread); err != nil {
+		return nil, err
+	}
+	for i := range thread.Messages {
+		if isSlackReply(&thread.Messages[i], channel, ts) {
+			return s.reply(ctx, sessionID, &thread.Messages[i]), nil
+		}
+	}
+
+	if !isDirectChannel(channel) {
+		return nil, nil
+	}
+
+	params = url.Values{"channel": {channel}, "oldest": {ts}}
+	var history struct {
+		slackResponse
+		Messages []slackMessage `json:"messages"`
+	}
+	if err := s.get(ctx, "conversations.history", params, &history); err != nil {
+		return nil, err
+	}
+	// History is newest first; the earliest reply answers the question
+	for i := len(history.Messages) - 1; i >= 0; i-- {
+		if isSlackReply(&history.Messages[i], channel, ts) {
+			return s.reply(ctx, sessionID, &history.Messages[i]), nil
+		}
+	}
+	return nil, nil
+}
+
+// slackAction is the interactive payload sent when a button is clicked
+type slackAction struct {
+	Type string `json:"type"`
+	User struct {
+		ID string `json:"id"`
+	} `json:"user"`
+	Container struct {
+		ChannelID string `json:"channel_id"`
+		MessageTS string `json:"message_ts"`
+	} `json:"container"`
+	Actions []struct {
+		Value string `json:"value"`
+	} `json:"actions"`
+}
+
+// actionReply converts a button click on the message at ts to a Reply, or
+// returns nil for any other interaction
+func (s *Slack) actionReply(ctx context.Context, sessionID string, a *slackAction, channel, ts string) *Reply {
+	if a.Type != "block_actions" || a.Container.ChannelID != channel || a.Container.MessageTS != ts ||
+		len(a.Actions) == 0 || a.User.ID == "" {
+		return nil
+	}
+	return s.reply(ctx, sessionID, &slackMessage{User: a.User.ID, Text: a.Actions[0].Value})
+}
+
+// reply converts a Slack message to a Reply, resolving the sender's name
+func (s *Slack) reply(ctx context.Context, sessionID string, m *slackMessage) *Reply {
+	return &Reply{
+		SessionID: sessionID,
+		From:      s.userName(ctx, m.User),
+		Channel:   s.Name(),
+		Content:   m.Text,
+	}
+}
+
+// userName looks up a user's display name, falling back to the user ID
+func (s *Slack) userName(ctx context.Context, userID string) string {
+	var resp struct {
+		slackResponse
+		User struct {
+			Name    string `json:"name"`
+			Profile struct {
+				DisplayName string `json:"display_name"`
+				RealName    string `json:"real_name"`
+			} `json:"profile"`
+		} `json:"user"`
+	}
+	if err := s.get(ctx, "users.info", url.Values{"user": {userID}}, &resp); err != nil {
+		return userID
+	}
+	for _, name := range []string{resp.User.Profile.DisplayName, resp.User.Profile.RealName, resp.User.Name} {
+		if name != "" {
+			return name
+		}
+	}
+	return userID
+}
+
+// post calls a Web API method with a JSON body
+func (s *Slack) post(ctx context.Context, token, method string, body interface{}, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to marshal request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", s.apiURL+"/"+method, reader)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return s.do(req, method, out)
+}
+
+// get calls a Web API read method with query parameters
+func (s *Slack) get(ctx context.Context, method string, params url.Values, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", s.apiURL+"/"+method+"?"+params.Encode(), nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+s.botToken)
+	return s.do(req, method, out)
+}
+
+func (s *Slack) do(req *http.Request, method string, out interface{}) error {
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("slack request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		secs, _ := strconv.Atoi(resp.Header.Get("Retry-After"))
+		return &slackRateLimited{retryAfter: time.Duration(secs) * time.Second}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("slack %s: %s", method, resp.Status)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	var status slackResponse
+	if err := json.Unmarshal(data, &status); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	if !status.OK {
+		return &slackAPIError{method: method, code: status.Error}
+	}
+
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	return nil
+}
+
+// isSlackPermanent reports whether err is a Slack API error that retrying cannot fix
+func isSlackPermanent(err error) bool {
+	var apiErr *slackAPIError
+	return errors.As(err, &apiErr)
+}
+
+// isSlackReply reports whether m is a human reply to the message at ts
+func isSlackReply(m *slackMessage, channel, ts string) bool {
+	if m.Type != "message" || m.Subtype != "" || m.BotID != "" || m.User == "" {
+		return false
+	}
+	if m.Channel != "" && m.Channel != channel {
+		return false
+	}
+	if !slackTSAfter(m.TS, ts) {
+		return false
+	}
+	return m.ThreadTS == ts || (m.ThreadTS == "" && isDirectChannel(channel))
+}
+
+// isDirectChannel reports whether a conversation ID is a direct message
+func isDirectChannel(channel string) bool {
+	return strings.HasPrefix(channel, "D")
+}
+
+// slackTSAfter reports whether Slack timestamp a is later than b
+func slackTSAfter(a, b string) bool {
+	aSec, aFrac, _ := strings.Cut(a, ".")
+	bSec, bFrac, _ := strings.Cut(b, ".")
+	as, _ := strconv.ParseInt(aSec, 10, 64)
+	bs, _ := strconv.ParseInt(bSec, 10, 64)
+	if as != bs {
+		return as > bs
+	}
+	af, _ := strconv.ParseInt(aFrac, 10, 64)
+	bf, _ := strconv.ParseInt(bFrac, 10, 64)
+	return af > bf
+}
+
+// parseSlackSession splits "slack-<channel>-<ts>" into its parts
+func parseSlackSession(sessionID string) (channel, ts string, err error) {
+	rest, ok := strings.CutPrefix(sessionID, "slack-")
+	if ok {
+		channel, ts, ok = strings.Cut(rest, "-")
+	}
+	if !ok || channel == "" || ts == "" {
+		return "", "", fmt.Errorf("invalid Slack session ID %q", sessionID)
+	}
+	return channel, ts, nil
+}
@@ -0,0 +1,299 @@
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/davedotdev/afk/internal/config"
+	"github.com/davedotdev/afk/internal/ws"
+)
+
+// slackStandIn is a local Slack Web API and Socket Mode gateway. Each
+// Socket Mode connection is sent the next script of envelopes in turn.
+type slackStandIn struct {
+	t       *testing.T
+	server  *httptest.Server
+	replies []map[string]interface{} // conversations.replies messages
+	scripts [][]map[string]interface{}
+
+	mu       sync.Mutex
+	posted   map[string]interface{} // Last chat.postMessage body
+	acks     []string               // Envelope IDs acknowledged
+	connects int
+}
+
+func newSlackStandIn(t *testing.T) *slackStandIn {
+	s := &slackStandIn{t: t}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/chat.postMessage", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer xoxb-test" {
+			s.json(w, map[string]interface{}{"ok": false, "error": "invalid_auth"})
+			return
+		}
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		s.mu.Lock()
+		s.posted = body
+		s.mu.Unlock()
+		s.json(w, map[string]interface{}{"ok": true, "channel": "C0TEAM", "ts": "1700000000.000100"})
+	})
+	mux.HandleFunc("/conversations.replies", func(w http.ResponseWriter, r *http.Request) {
+		s.json(w, map[string]interface{}{"ok": true, "messages": s.replies})
+	})
+	mux.HandleFunc("/users.info", func(w http.ResponseWriter, r *http.Request) {
+		name := map[string]string{"U0DEV": "dev", "U0OPS": "ops"}[r.URL.Query().Get("user")]
+		s.json(w, map[string]interface{}{"ok": true, "user": map[string]interface{}{"profile": map[string]string{"display_name": name}}})
+	})
+	mux.HandleFunc("/apps.connections.open", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer xapp-test" {
+			s.json(w, map[string]interface{}{"ok": false, "error": "not_allowed_token_type"})
+			return
+		}
+		s.json(w, map[string]interface{}{"ok": true, "url": "ws" + strings.TrimPrefix(s.server.URL, "http") + "/socket"})
+	})
+	mux.HandleFunc("/socket", s.socket)
+	s.server = httptest.NewServer(mux)
+	t.Cleanup(s.server.Close)
+	return s
+}
+
+// lastPosted returns the body of the last chat.postMessage call
+func (s *slackStandIn) lastPosted() map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.posted
+}
+
+func (s *slackStandIn) json(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// socket sends the connection's script, then reads acknowledgements
+func (s *slackStandIn) socket(w http.ResponseWriter, r *http.Request) {
+	conn, err := ws.Upgrade(w, r)
+	if err != nil {
+		s.t.Errorf("Upgrade: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	s.mu.Lock()
+	n := s.connects
+	s.connects++
+	s.mu.Unlock()
+	if n >= len(s.scripts) {
+		return
+	}
+
+	conn.WriteMessage(ws.TextMessage, []byte(`{"type":"hello"}`))
+	for _, envelope := range s.scripts[n] {
+		data, _ := json.Marshal(envelope)
+		if err := conn.WriteMessage(ws.TextMessage, data); err != nil {
+			return
+		}
+	}
+
+	// Record acknowledgements until the client hangs up
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		var ack struct {
+			EnvelopeID string `json:"envelope_id"`
+		}
+		json.Unmarshal(data, &ack)
+		s.mu.Lock()
+		s.acks = append(s.acks, ack.EnvelopeID)
+		s.mu.Unlock()
+	}
+}
+
+func (s *slackStandIn) transport(t *testing.T, appToken string) *Slack {
+	t.Helper()
+	slack, err := NewSlack(&config.Config{
+		SlackBotToken: "xoxb-test",
+		SlackAppToken: appToken,
+		SlackChannel:  "C0TEAM",
+		SlackAPIURL:   s.server.URL,
+	})
+	if err != nil {
+		t.Fatalf("NewSlack: %v", err)
+	}
+	return slack
+}
+
+func eventEnvelope(id string, event map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"envelope_id": id,
+		"type":        "events_api",
+		"payload":     map[string]interface{}{"event": event},
+	}
+}
+
+func actionEnvelope(id, channel, ts, user, value string) map[string]interface{} {
+	return map[string]interface{}{
+		"envelope_id": id,
+		"type":        "interactive",
+		"payload": map[string]interface{}{
+			"type":      "block_actions",
+			"user":      map[string]string{"id": user},
+			"container": map[string]string{"channel_id": channel, "message_ts": ts},
+			"actions":   []map[string]string{{"value": value}},
+		},
+	}
+}
+
+func listenSlack(t *testing.T, slack *Slack, sessionID string) *Reply {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	reply, err := slack.Listen(ctx, sessionID, ListenOptions{Timeout: 5 * time.Second})
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	return reply
+}
+
+func TestSlackSend(t *testing.T) {
+	s := newSlackStandIn(t)
+
+	receipt, err := s.transport(t, "xapp-test").Send(context.Background(), Message{Text: "Deploy?", SysName: "CI", Approval: true})
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if receipt.SessionID != "slack-C0TEAM-1700000000.000100" {
+		t.Errorf("SessionID = %q", receipt.SessionID)
+	}
+	posted := s.lastPosted()
+	if posted["text"] != "*CI:*\nDeploy?" || posted["channel"] != "C0TEAM" {
+		t.Errorf("posted %v", posted)
+	}
+	blocks, _ := json.Marshal(posted["blocks"])
+	for _, want := range []string{`"type":"actions"`, `"value":"Approve"`, `"value":"Reject"`} {
+		if !strings.Contains(string(blocks), want) {
+			t.Errorf("blocks %s lack %s", blocks, want)
+		}
+	}
+
+	// Without Socket Mode a click could not be received, so no buttons
+	if _, err := s.transport(t, "").Send(context.Background(), Message{Text: "Deploy?", Approval: true}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if posted := s.lastPosted(); posted["blocks"] != nil {
+		t.Errorf("buttons sent without an app token: %v", posted)
+	}
+}
+
+func TestSlackSendInvalidToken(t *testing.T) {
+	s := newSlackStandIn(t)
+	slack := s.transport(t, "")
+	slack.botToken = "xoxb-wrong"
+
+	_, err := slack.Send(context.Background(), Message{Text: "hi"})
+	if err == nil || !isSlackPermanent(err) || !strings.Contains(err.Error(), "invalid_auth") {
+		t.Errorf("Send = %v, want a permanent invalid_auth error", err)
+	}
+}
+
+func TestSlackSocketModeThreadReply(t *testing.T) {
+	const ts = "1700000000.000100"
+	s := newSlackStandIn(t)
+	s.scripts = [][]map[string]interface{}{
+		{
+			// Slack rotates the connection before anything arrives
+			{"type": "disconnect", "reason": "refresh_requested"},
+		},
+		{
+			eventEnvelope("e1", map[string]interface{}{"type": "message", "channel": "C0TEAM", "user": "U0DEV", "text": "other thread", "ts": "1700000001.000000", "thread_ts": "1690000000.000000"}),
+			eventEnvelope("e2", map[string]interface{}{"type": "message", "channel": "C0TEAM", "bot_id": "B01", "user": "U0BOT", "text": "bot echo", "ts": "1700000002.000000", "thread_ts": ts}),
+			eventEnvelope("e3", map[string]interface{}{"type": "message", "channel": "C0TEAM", "user": "U0DEV", "text": "not in a thread", "ts": "1700000003.000000"}),
+			eventEnvelope("e4", map[string]interface{}{"type": "message", "channel": "C0TEAM", "user": "U0DEV", "text": "Option B", "ts": "1700000004.000000", "thread_ts": ts}),
+		},
+	}
+
+	reply := listenSlack(t, s.transport(t, "xapp-test"), "slack-C0TEAM-"+ts)
+	if reply.Content != "Option B" || reply.From != "dev" || reply.Channel != "Slack" {
+		t.Errorf("reply = %+v", reply)
+	}
+
+	// The last acknowledgement may still be in flight when Listen returns
+	want := "e1,e2,e3,e4"
+	var acks string
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		s.mu.Lock()
+		acks = strings.Join(s.acks, ",")
+		s.mu.Unlock()
+		if acks == want {
+			break
+		}
+	}
+	if acks != want {
+		t.Errorf("acknowledged %q, want %q", acks, want)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.connects != 2 {
+		t.Errorf("connected %d times, want 2", s.connects)
+	}
+}
+
+func TestSlackSocketModeInteractive(t *testing.T) {
+	const ts = "1700000000.000100"
+	s := newSlackStandIn(t)
+	s.scripts = [][]map[string]interface{}{{
+		actionEnvelope("a1", "C0TEAM", "1690000000.000000", "U0DEV", "Reject"), // Another message's buttons
+		actionEnvelope("a2", "C0OTHER", ts, "U0DEV", "Reject"),
+		actionEnvelope("a3", "C0TEAM", ts, "U0OPS", "Approve"),
+	}}
+
+	reply := listenSlack(t, s.transport(t, "xapp-test"), "slack-C0TEAM-"+ts)
+	if reply.Content != "Approve" || reply.From != "ops" {
+		t.Errorf("reply = %+v", reply)
+	}
+}
+
+func TestSlackPollThreadReply(t *testing.T) {
+	const ts = "1700000000.000100"
+	s := newSlackStandIn(t)
+	s.replies = []map[string]interface{}{
+		{"type": "message", "user": "U0BOT", "bot_id": "B01", "text": "Deploy?", "ts": ts},
+		{"type": "message", "subtype": "channel_join", "user": "U0OPS", "text": "joined", "ts": "1700000001.000000", "thread_ts": ts},
+		{"type": "message", "user": "U0DEV", "text": "yes", "ts": "1700000002.000000", "thread_ts": ts},
+	}
+
+	reply := listenSlack(t, s.transport(t, ""), "slack-C0TEAM-"+ts)
+	if reply.Content != "yes" || reply.From != "dev" {
+		t.Errorf("reply = %+v", reply)
+	}
+}
+
+func TestSlackListenTimeout(t *testing.T) {
+	s := newSlackStandIn(t)
+	s.scripts = [][]map[string]interface{}{{}}
+
+	_, err := s.transport(t, "xapp-test").Listen(context.Background(), "slack-C0TEAM-1700000000.000100", ListenOptions{Timeout: 200 * time.Millisecond})
+	if !errors.Is(err, ErrTimeout) {
+		t.Errorf("Listen = %v, want ErrTimeout", err)
+	}
+}
+
+func TestParseSlackSession(t *testing.T) {
+	channel, ts, err := parseSlackSession("slack-D0DM-1700000000.000100")
+	if err != nil || channel != "D0DM" || ts != "1700000000.000100" {
+		t.Errorf("parseSlackSession = %q, %q, %v", channel, ts, err)
+	}
+	for _, bad := range []string{"", "slack-", "slack-C0TEAM", "tg-1-2"} {
+		if _, _, err := parseSlackSession(bad); err == nil {
+			t.Errorf("parseSlackSession(%q) succeeded", bad)
+		}
+	}
+}
@@ -0,0 +1,330 @@
+package transport
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/davedotdev/afk/internal/backoff"
+	"github.com/davedotdev/afk/internal/config"
+)
+
+func init() {
+	Register(Registration{
+		Name:    "telegram",
+		Service: "Telegram",
+		Usage:   "Send message via Telegram",
+		New: func(cfg *config.Config) (Transport, error) {
+			t, err := NewTelegram(cfg)
+			if err != nil {
+				return nil, err
+			}
+			return t, nil
+		},
+		Configured: func(cfg *config.Config) bool { return cfg.TelegramBotToken != "" },
+		SetRecipient: func(cfg *config.Config, contact config.Contact) error {
+			return setAddress(&cfg.TelegramChatID, contact.Telegram, "Telegram chat ID")
+		},
+		Destination: func(cfg *config.Config) string { return cfg.TelegramChatID },
+		// Telegram lets one getUpdates consumer poll a bot at a time
+		Poller: func(cfg *config.Config) string { return cfg.TelegramBotToken },
+	})
+}
+
+// telegramPollTimeout is the long-poll duration for getUpdates
+const telegramPollTimeout = 50 * time.Second
+
+// Telegram sends messages through the Bot API and waits for the reply with
+// getUpdates long polling
+type Telegram struct {
+	apiURL string
+	token  string
+	chatID string
+	client *http.Client
+}
+
+// NewTelegram creates a Telegram transport from the config
+func NewTelegram(cfg *config.Config) (*Telegram, error) {
+	if cfg.TelegramBotToken == "" || cfg.TelegramChatID == "" {
+		return nil, errors.New("telegram not configured: set telegram_bot_token and telegram_chat_id in " + config.Path())
+	}
+	return &Telegram{
+		apiURL: strings.TrimRight(cfg.TelegramAPIURL, "/"),
+		token:  cfg.TelegramBotToken,
+		chatID: cfg.TelegramChatID,
+		// No timeout - getUpdates long polls; requests are bounded by context
+		client: &http.Client{},
+	}, nil
+}
+
+// Name returns "Telegram"
+func (t *Telegram) Name() string {
+	return "Telegram"
+}
+
+// telegramMessage is the subset of a Bot API Message that afk reads
+type telegramMessage struct {
+	MessageID int64  `json:"message_id"`
+	Date      int64  `json:"date"`
+	Text      string `json:"text"`
+	Chat      struct {
+		ID int64 `json:"id"`
+	} `json:"chat"`
+	From *struct {
+		IsBot     bool   `json:"is_bot"`
+		FirstName string `json:"first_name"`
+		Username  string `json:"username"`
+	} `json:"from"`
+	ReplyTo *struct {
+		MessageID int64 `json:"message_id"`
+	} `json:"reply_to_message"`
+}
+
+// telegramError is an ok:false Bot API response
+type telegramError struct {
+	method     string
+	code       int
+	message    string
+	retryAfter time.Duration
+}
+
+func (e *telegramError) Error() string {
+	return fmt.Sprintf("telegram %s: %s", e.method, e.message)
+}
+
+// permanent reports whether retrying cannot fix the error (bad token,
+// unknown chat, or a webhook blocking getUpdates). Other 409 conflicts
+// come from another getUpdates poll on the same bot, which may end.
+func (e *telegramError) permanent() bool {
+	switch e.code {
+	case 400, 401, 403, 404:
+		return true
+	case 409:
+		return strings.Contains(strings.ToLower(e.message), "webhook")
+	}
+	return false
+}
+
+// Send sends the message. Multiple-choice options are offered as a one-time
+// reply keyboard.
+func (t *Telegram) Send(ctx context.Context, msg Message) (*Receipt, error) {
+	text := msg.Text
+	if msg.SysName != "" {
+		text = msg.SysName + ":\n" + msg.Text
+	}
+
+	params := map[string]interface{}{
+		"chat_id": t.chatID,
+		"text":    text,
+	}
+	if len(msg.Options) > 0 {
+		var rows [][]map[string]string
+		for _, opt := range msg.Options {
+			rows = append(rows, []map[string]string{{"text": opt}})
+		}
+		params["reply_markup"] = map[string]interface{}{
+			"keyboard":          rows,
+			"one_time_keyboard": true,
+			"resize_keyboard":   true,
+		}
+	}
+
+	var sent telegramMessage
+	if err := t.call(ctx, "sendMessage", params, &sent); err != nil {
+		return nil, err
+	}
+
+	messageID := strconv.FormatInt(sent.MessageID, 10)
+	return &Receipt{
+		SessionID: fmt.Sprintf("tg-%d-%s", sent.Chat.ID, messageID),
+		MessageID: messageID,
+	}, nil
+}
+
+// Listen waits for a reply to the message, or any later message in the chat
+func (t *Telegram) Listen(ctx context.Context, sessionID string, opts ListenOptions) (*Reply, error) {
+	chatID, messageID, err := parseTelegramSession(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	return wait(ctx, opts, func(ctx context.Context) (*Reply, error) {
+		var offset int64
+		attempt := 0
+
+		for {
+			var updates []struct {
+				UpdateID int64            `json:"update_id"`
+				Message  *telegramMessage `json:"message"`
+			}
+			err := t.call(ctx, "getUpdates", map[string]interface{}{
+				"offset":          offset,
+				"timeout":         int(telegramPollTimeout / time.Second),
+				"allowed_updates": []string{"message"},
+			}, &updates)
+
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil, ctx.Err()
+				}
+				var tgErr *telegramError
+				if errors.As(err, &tgErr) && tgErr.permanent() {
+					return nil, err
+				}
+
+				attempt++
+				var retryAfter time.Duration
+				if errors.As(err, &tgErr) {
+					retryAfter = tgErr.retryAfter
+				}
+				delay := backoff.Delay(attempt, retryAfter)
+				if opts.OnReconnect != nil {
+					opts.OnReconnect(attempt, delay, err)
+				}
+				if !sleep(ctx, delay) {
+					return nil, ctx.Err()
+				}
+				continue
+			}
+			attempt = 0
+
+			for _, u := range updates {
+				offset = u.UpdateID + 1
+				if m := u.Message; m != nil && isTelegramReply(m, chatID, messageID) {
+					// Confirm the update so it is not delivered again
+					t.call(ctx, "getUpdates", map[string]interface{}{"offset": offset, "timeout": 0}, &updates)
+					return t.reply(sessionID, m), nil
+				}
+			}
+		}
+	})
+}
+
+// Health checks that the Bot API is reachable
+func (t *Telegram) Health(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", t.apiURL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("telegram unreachable: %w", err)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// Validate checks the bot token
+func (t *Telegram) Validate(ctx context.Context) error {
+	var me struct {
+		Username string `json:"username"`
+	}
+	return t.call(ctx, "getMe", nil, &me)
+}
+
+func (t *Telegram) reply(sessionID string, m *telegramMessage) *Reply {
+	from := "unknown"
+	if m.From != nil {
+		from = m.From.FirstName
+		if m.From.Username != "" {
+			from = m.From.Username
+		}
+	}
+	return &Reply{
+		SessionID: sessionID,
+		From:      from,
+		Channel:   t.Name(),
+		Content:   m.Text,
+	}
+}
+
+// call invokes a Bot API method and decodes its result into out
+func (t *Telegram) call(ctx context.Context, method string, params interface{}, out interface{}) error {
+	if params == nil {
+		params = map[string]interface{}{}
+	}
+	body, err := json.Marshal(params)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	url := fmt.Sprintf("%s/bot%s/%s", t.apiURL, t.token, method)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		// The URL contains the token; report only the method
+		return fmt.Errorf("telegram %s request failed", method)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	var result struct {
+		OK          bool            `json:"ok"`
+		Result      json.RawMessage `json:"result"`
+		ErrorCode   int             `json:"error_code"`
+		Description string          `json:"description"`
+		Parameters  struct {
+			RetryAfter int `json:"retry_after"`
+		} `json:"parameters"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return fmt.Errorf("telegram %s: %s", method, resp.Status)
+	}
+	if !result.OK {
+		return &telegramError{
+			method:     method,
+			code:       result.ErrorCode,
+			message:    result.Description,
+			retryAfter: time.Duration(result.Parameters.RetryAfter) * time.Second,
+		}
+	}
+
+	if err := json.Unmarshal(result.Result, out); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	return nil
+}
+
+// isTelegramReply reports whether m answers the message messageID in chatID:
+// a reply to it, or a plain message sent after it
+func isTelegramReply(m *telegramMessage, chatID, messageID int64) bool {
+	if m.Chat.ID != chatID || m.Text == "" || (m.From != nil && m.From.IsBot) {
+		return false
+	}
+	if m.ReplyTo != nil {
+		return m.ReplyTo.MessageID == messageID
+	}
+	return m.MessageID > messageID
+}
+
+// parseTelegramSession splits "tg-<chat>-<message>"; chat IDs may be negative
+func parseTelegramSession(sessionID string) (chatID, messageID int64, err error) {
+	rest, ok := strings.CutPrefix(sessionID, "tg-")
+	i := strings.LastIndex(rest, "-")
+	if !ok || i <= 0 {
+		return 0, 0, fmt.Errorf("invalid Telegram session ID %q", sessionID)
+	}
+	if chatID, err = strconv.ParseInt(rest[:i], 10, 64); err == nil {
+		messageID, err = strconv.ParseInt(rest[i+1:], 10, 64)
+	}
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid Telegram session ID %q", sessionID)
+	}
+	return chatID, messageID, nil
+}
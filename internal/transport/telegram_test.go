@@ -0,0 +1,179 @@
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/davedotdev/afk/internal/config"
+)
+
+// telegramStandIn is a local Bot API. Each getUpdates call is answered
+// with the next scripted response; once the script runs out, polls hang
+// until the client gives up, as a long poll with nothing new would.
+type telegramStandIn struct {
+	server  *httptest.Server
+	updates []string // Raw getUpdates responses, in order
+
+	mu      sync.Mutex
+	sent    map[string]interface{} // Last sendMessage body
+	offsets []float64              // Offset of each getUpdates call
+}
+
+func newTelegramStandIn(t *testing.T) *telegramStandIn {
+	s := &telegramStandIn{}
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, method, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/bot"), "/")
+		if token != "123:test" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"ok":false,"error_code":401,"description":"Unauthorized"}`))
+			return
+		}
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+
+		w.Header().Set("Content-Type", "application/json")
+		switch method {
+		case "sendMessage":
+			s.mu.Lock()
+			s.sent = body
+			s.mu.Unlock()
+			w.Write([]byte(`{"ok":true,"result":{"message_id":42,"date":1700000000,"chat":{"id":1001},"text":"sent"}}`))
+		case "getUpdates":
+			offset, _ := body["offset"].(float64)
+			s.mu.Lock()
+			n := len(s.offsets)
+			s.offsets = append(s.offsets, offset)
+			s.mu.Unlock()
+			if n >= len(s.updates) {
+				<-r.Context().Done()
+				return
+			}
+			w.Write([]byte(s.updates[n]))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(s.server.Close)
+	return s
+}
+
+func (s *telegramStandIn) transport(t *testing.T) *Telegram {
+	t.Helper()
+	tg, err := NewTelegram(&config.Config{TelegramBotToken: "123:test", TelegramChatID: "1001", TelegramAPIURL: s.server.URL})
+	if err != nil {
+		t.Fatalf("NewTelegram: %v", err)
+	}
+	return tg
+}
+
+func TestTelegramSend(t *testing.T) {
+	s := newTelegramStandIn(t)
+
+	receipt, err := s.transport(t).Send(context.Background(), Message{Text: "Which region?", SysName: "CI", Options: []string{"us-east", "eu-west"}})
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if receipt.SessionID != "tg-1001-42" || receipt.MessageID != "42" {
+		t.Errorf("receipt = %+v", receipt)
+	}
+
+	s.mu.Lock()
+	sent, _ := json.Marshal(s.sent)
+	s.mu.Unlock()
+	for _, want := range []string{`"chat_id":"1001"`, `"text":"CI:\nWhich region?"`, `"keyboard":[[{"text":"us-east"}],[{"text":"eu-west"}]]`} {
+		if !strings.Contains(string(sent), want) {
+			t.Errorf("sendMessage %s lacks %s", sent, want)
+		}
+	}
+}
+
+func TestTelegramSendUnauthorized(t *testing.T) {
+	s := newTelegramStandIn(t)
+	tg := s.transport(t)
+	tg.token = "123:wrong"
+
+	_, err := tg.Send(context.Background(), Message{Text: "hi"})
+	var tgErr *telegramError
+	if !errors.As(err, &tgErr) || !tgErr.permanent() {
+		t.Errorf("Send = %v, want a permanent error", err)
+	}
+	if err != nil && strings.Contains(err.Error(), "123:wrong") {
+		t.Errorf("error %q reveals the token", err)
+	}
+}
+
+func TestTelegramListen(t *testing.T) {
+	s := newTelegramStandIn(t)
+	s.updates = []string{
+		// Another afk wait on the bot holds the poll; retried, not fatal
+		`{"ok":false,"error_code":409,"description":"Conflict: terminated by other getUpdates request; make sure that only one bot instance is running"}`,
+		`{"ok":true,"result":[
+			{"update_id":7,"message":{"message_id":40,"chat":{"id":1001},"from":{"first_name":"Dev"},"text":"sent before the question"}},
+			{"update_id":8,"message":{"message_id":43,"chat":{"id":2002},"from":{"first_name":"Other"},"text":"another chat"}},
+			{"update_id":9,"message":{"message_id":44,"chat":{"id":1001},"from":{"first_name":"Bot","is_bot":true},"text":"bot echo"}},
+			{"update_id":10,"message":{"message_id":45,"chat":{"id":1001},"from":{"first_name":"Dev"},"text":"to an older message","reply_to_message":{"message_id":30}}}
+		]}`,
+		`{"ok":true,"result":[
+			{"update_id":11,"message":{"message_id":46,"chat":{"id":1001},"from":{"first_name":"Dev","username":"dev"},"text":"us-east","reply_to_message":{"message_id":42}}}
+		]}`,
+		`{"ok":true,"result":[]}`,
+	}
+
+	var reconnects []error
+	reply, err := s.transport(t).Listen(context.Background(), "tg-1001-42", ListenOptions{
+		Timeout:     10 * time.Second,
+		OnReconnect: func(attempt int, delay time.Duration, err error) { reconnects = append(reconnects, err) },
+	})
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	if reply.Content != "us-east" || reply.From != "dev" || reply.Channel != "Telegram" {
+		t.Errorf("reply = %+v", reply)
+	}
+	if len(reconnects) != 1 || !strings.Contains(reconnects[0].Error(), "Conflict") {
+		t.Errorf("reconnects = %v, want the conflict retried once", reconnects)
+	}
+
+	// Each poll confirms the updates before it, and the reply is confirmed
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	want := []float64{0, 0, 11, 12}
+	if len(s.offsets) != len(want) {
+		t.Fatalf("getUpdates offsets %v, want %v", s.offsets, want)
+	}
+	for i := range want {
+		if s.offsets[i] != want[i] {
+			t.Errorf("getUpdates offsets %v, want %v", s.offsets, want)
+			break
+		}
+	}
+}
+
+func TestTelegramListenWebhookConflict(t *testing.T) {
+	s := newTelegramStandIn(t)
+	s.updates = []string{`{"ok":false,"error_code":409,"description":"Conflict: can't use getUpdates method while webhook is active; use deleteWebhook to delete the webhook first"}`}
+
+	_, err := s.transport(t).Listen(context.Background(), "tg-1001-42", ListenOptions{Timeout: 5 * time.Second})
+	if err == nil || !strings.Contains(err.Error(), "webhook is active") {
+		t.Errorf("Listen = %v, want the webhook conflict", err)
+	}
+}
+
+func TestParseTelegramSession(t *testing.T) {
+	chatID, messageID, err := parseTelegramSession("tg--1001234-42")
+	if err != nil || chatID != -1001234 || messageID != 42 {
+		t.Errorf("parseTelegramSession = %d, %d, %v", chatID, messageID, err)
+	}
+	for _, bad := range []string{"", "tg-", "tg-1001", "tg-x-42", "slack-1-2"} {
+		if _, _, err := parseTelegramSession(bad); err == nil {
+			t.Errorf("parseTelegramSession(%q) succeeded", bad)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/davedotdev/afk/internal/config"
+)
+
+// Errors returned by Listen when no reply arrives
+var (
+	ErrTimeout   = errors.New("timeout waiting for response")
+	ErrCancelled = errors.New("cancelled")
+)
+
+// Message is an outgoing message
+type Message struct {
+	Text     string
+	SysName  string   // Name of the AI agent/system sending the message
+	Options  []string // Multiple-choice options, for channels that can offer buttons
+	Approval bool     // Yes/no question, for channels that can offer approve/reject buttons
+}
+
+// Receipt identifies a sent message
+type Receipt struct {
+	SessionID string // Pass to Listen to wait for the reply
+	MessageID string
+}
+
+// Reply is a response received for a session
+type Reply struct {
+	SessionID string
+	From      string
+	Channel   string // Where the reply came from (e.g. "WhatsApp", "Web")
+	Content   string
+}
+
+// ReminderHandler is called at reminder intervals
+type ReminderHandler func(elapsed, remaining time.Duration)
+
+// ReconnectHandler is called before a dropped connection is retried
+type ReconnectHandler func(attempt int, delay time.Duration, err error)
+
+// ListenOptions configures waiting for a reply
+type ListenOptions struct {
+	Timeout          time.Duration
+	ReminderInterval time.Duration
+	OnReminder       ReminderHandler
+	OnReconnect      ReconnectHandler
+}
+
+// Transport sends messages on one channel and waits for replies
+type Transport interface {
+	// Name is the channel's display name (e.g. "WhatsApp")
+	Name() string
+
+	// Send delivers a message and returns the session to listen on
+	Send(ctx context.Context, msg Message) (*Receipt, error)
+
+	// Listen waits for the reply to a session. It returns ErrTimeout or
+	// ErrCancelled when none arrives.
+	Listen(ctx context.Context, sessionID string, opts ListenOptions) (*Reply, error)
+
+	// Health checks that the service is reachable
+	Health(ctx context.Context) error
+
+	// Validate checks that the configured credentials are accepted
+	Validate(ctx context.Context) error
+}
+
+// Factory creates a transport from the loaded config
+type Factory func(cfg *config.Config) (Transport, error)
+
+// Registration describes a channel selectable on the command line
+type Registration struct {
+	Name       string                        // Flag name, e.g. "whatsapp"
+	Service    string                        // Backing service; channels sharing one are checked once
+	Usage      string                        // Flag help text
+	New        Factory                       // Creates the transport
+	Configured func(cfg *config.Config) bool // Reports whether credentials are set
+
+	// Arg names the value the channel flag takes (e.g. "url"); empty for a
+	// plain on/off flag. SetArg stores the value in the config before New.
+	Arg    string
+	SetArg func(cfg *config.Config, value string)
+
+	// SetRecipient addresses the message to a contact (--to), failing when
+	// the contact has no address on this channel. Nil for channels that
+	// don't message a person.
+	SetRecipient func(cfg *config.Config, contact config.Contact) error
+
+	// Destination returns who the config reaches (phone, chat ID, user ID
+	// or address), empty when unset, so that two recipients reaching the
+	// same person can be told apart. Nil when it can't be known.
+	Destination func(cfg *config.Config) string
+
+	// Poller returns the update stream a wait reads when only one reader
+	// may poll it at a time (a Telegram bot), so --to can refuse to wait
+	// on it twice. Nil when every wait listens on its own.
+	Poller func(cfg *config.Config) string
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Registration)
+)
+
+// Register makes a channel available by name. It panics on duplicates, as
+// registrations happen in init functions.
+func Register(r Registration) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if _, dup := registry[r.Name]; dup {
+		panic("transport: duplicate registration for " + r.Name)
+	}
+	registry[r.Name] = r
+}
+
+// Lookup returns the registration for a channel name
+func Lookup(name string) (Registration, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	r, ok := registry[name]
+	return r, ok
+}
+
+// Registrations returns all registered channels sorted by name
+func Registrations() []Registration {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	regs := make([]Registration, 0, len(registry))
+	for _, r := range registry {
+		regs = append(regs, r)
+	}
+	sort.Slice(regs, func(i, j int) bool {
+		return regs[i].Name < regs[j].Name
+	})
+	return regs
+}
+
+// setAddress stores a contact's address for a channel, failing when the
+// contact has none
+func setAddress(dst *string, address, what string) error {
+	if address == "" {
+		return fmt.Errorf("no %s", what)
+	}
+	*dst = address
+	return nil
+}
+
+// New creates the transport registered under name
+func New(name string, cfg *config.Config) (Transport, error) {
+	r, ok := Lookup(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown channel %q", name)
+	}
+	return r.New(cfg)
+}
@@ -0,0 +1,77 @@
+package transport
+
+import (
+	"context"
+	"time"
+)
+
+// receiveFunc blocks until a reply arrives or ctx ends
+type receiveFunc func(ctx context.Context) (*Reply, error)
+
+// wait runs receive within opts.Timeout, calling opts.OnReminder at each
+// reminder interval. It is the shared Listen loop for transports that have
+// no event stream of their own to drive reminders.
+func wait(ctx context.Context, opts ListenOptions, receive receiveFunc) (*Reply, error) {
+	startTime := time.Now()
+
+	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+
+	var reminderChan <-chan time.Time
+	if opts.ReminderInterval > 0 && opts.OnReminder != nil {
+		ticker := time.NewTicker(opts.ReminderInterval)
+		defer ticker.Stop()
+		reminderChan = ticker.C
+	}
+
+	type result struct {
+		reply *Reply
+		err   error
+	}
+	results := make(chan result, 1)
+	go func() {
+		reply, err := receive(ctx)
+		results <- result{reply, err}
+	}()
+
+	for {
+		select {
+		case res := <-results:
+			if res.err != nil && ctx.Err() != nil {
+				return nil, contextError(ctx)
+			}
+			return res.reply, res.err
+
+		case <-reminderChan:
+			elapsed := time.Since(startTime)
+			remaining := opts.Timeout - elapsed
+			if remaining < 0 {
+				remaining = 0
+			}
+			opts.OnReminder(elapsed, remaining)
+
+		case <-ctx.Done():
+			return nil, contextError(ctx)
+		}
+	}
+}
+
+// contextError maps a finished context to ErrTimeout or ErrCancelled
+func contextError(ctx context.Context) error {
+	if ctx.Err() == context.DeadlineExceeded {
+		return ErrTimeout
+	}
+	return ErrCancelled
+}
+
+// sleep waits for d or until ctx ends, reporting whether the full delay passed
+func sleep(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
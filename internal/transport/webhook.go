@@ -0,0 +1,475 @@
+package transport
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/davedotdev/afk/internal/backoff"
+	"github.com/davedotdev/afk/internal/config"
+)
+
+func init() {
+	Register(Registration{
+		Name:    "webhook",
+		Service: "Webhook",
+		Usage:   "Send message to the webhook `url`",
+		New: func(cfg *config.Config) (Transport, error) {
+			t, err := NewWebhook(cfg)
+			if err != nil {
+				return nil, err
+			}
+			return t, nil
+		},
+		Configured:  func(cfg *config.Config) bool { return cfg.WebhookSecret != "" },
+		Arg:         "url",
+		SetArg:      func(cfg *config.Config, value string) { cfg.WebhookURL = value },
+		Destination: func(cfg *config.Config) string { return cfg.WebhookURL },
+	})
+}
+
+// Webhook poll interval and reply size limit
+const (
+	webhookPollInterval  = 5 * time.Second
+	maxWebhookReplyBytes = 1 << 20
+)
+
+// webhookSignatureHeader carries "sha256=<hex HMAC>" on every request
+const webhookSignatureHeader = "X-AFK-Signature"
+
+// errWebhookSignature means a reply was unsigned or signed with another secret
+var errWebhookSignature = errors.New("webhook answer has a missing or invalid " + webhookSignatureHeader)
+
+// Webhook POSTs a signed JSON envelope to an arbitrary endpoint. The answer
+// comes back as a signed POST to a short-lived local listener, or is
+// polled from a reply URL.
+type Webhook struct {
+	url        string
+	secret     string
+	listenAddr string
+	replyURL   string
+	pollURL    string
+	client     *http.Client
+
+	// Started by Send so an answer that arrives before Listen is kept
+	server *webhookServer
+}
+
+// NewWebhook creates a webhook transport from the config
+func NewWebhook(cfg *config.Config) (*Webhook, error) {
+	if cfg.WebhookSecret == "" {
+		return nil, errors.New("webhook not configured: set webhook_secret in " + config.Path())
+	}
+	return &Webhook{
+		url:        cfg.WebhookURL,
+		secret:     cfg.WebhookSecret,
+		listenAddr: cfg.WebhookListen,
+		replyURL:   cfg.WebhookReplyURL,
+		pollURL:    cfg.WebhookPollURL,
+		client:     &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+// WebhookResumable reports why a reply could not be picked up by a later
+// afk wait: the reply_url sent names this process's listener, and a free
+// port chosen per question is gone once it exits. Polling or a fixed
+// listen port lets another process receive the answer.
+func WebhookResumable(cfg *config.Config) error {
+	if cfg.WebhookPollURL != "" {
+		return nil
+	}
+	if _, port, err := net.SplitHostPort(cfg.WebhookListen); err == nil && port != "" && port != "0" {
+		return nil
+	}
+	return errors.New("webhook replies go to a listener on a free port that closes when afk exits (set webhook_poll_url, or webhook_listen with a fixed port)")
+}
+
+// Name returns "Webhook"
+func (w *Webhook) Name() string {
+	return "Webhook"
+}
+
+// webhookEnvelope is the JSON body POSTed to the webhook URL
+type webhookEnvelope struct {
+	Session  string   `json:"session"`
+	Message  string   `json:"message"`
+	SysName  string   `json:"sys_name,omitempty"`
+	Options  []string `json:"options,omitempty"`
+	Approval bool     `json:"approval,omitempty"`
+	ReplyURL string   `json:"reply_url,omitempty"`
+	PollURL  string   `json:"poll_url,omitempty"`
+	SentAt   string   `json:"sent_at"`
+}
+
+// webhookAnswer is the JSON body of a reply, POSTed back or polled
+type webhookAnswer struct {
+	Session string `json:"session"`
+	Reply   string `json:"reply"`
+	From    string `json:"from,omitempty"`
+}
+
+// webhookStatusError is a non-2xx response from the endpoint
+type webhookStatusError struct {
+	status int
+	body   string
+}
+
+func (e *webhookStatusError) Error() string {
+	if e.body == "" {
+		return fmt.Sprintf("webhook: %d %s", e.status, http.StatusText(e.status))
+	}
+	return fmt.Sprintf("webhook: %d %s", e.status, e.body)
+}
+
+// Send POSTs the envelope to the webhook URL. Unless replies are polled,
+// the reply listener is started first so an immediate answer is not lost.
+func (w *Webhook) Send(ctx context.Context, msg Message) (*Receipt, error) {
+	if w.url == "" {
+		return nil, errors.New("webhook URL required: use --webhook <url>")
+	}
+	if u, err := url.Parse(w.url); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid webhook URL %q", w.url)
+	}
+
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return nil, fmt.Errorf("failed to generate session ID: %w", err)
+	}
+	sessionID := "webhook-" + hex.EncodeToString(b)
+
+	envelope := webhookEnvelope{
+		Session:  sessionID,
+		Message:  msg.Text,
+		SysName:  msg.SysName,
+		Options:  msg.Options,
+		Approval: msg.Approval,
+		SentAt:   time.Now().UTC().Format(time.RFC3339),
+	}
+	if w.pollURL != "" {
+		envelope.PollURL = w.sessionPollURL(sessionID)
+	} else {
+		server, err := w.listen(sessionID)
+		if err != nil {
+			return nil, err
+		}
+		w.server = server
+		envelope.ReplyURL = server.replyURL
+	}
+
+	body, err := json.Marshal(envelope)
+	if err != nil {
+		w.closeServer()
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", w.url, bytes.NewReader(body))
+	if err != nil {
+		w.closeServer()
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(webhookSignatureHeader, w.sign(body))
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		w.closeServer()
+		return nil, fmt.Errorf("webhook request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		w.closeServer()
+		return nil, webhookError(resp)
+	}
+
+	return &Receipt{SessionID: sessionID}, nil
+}
+
+// Listen waits for the answer on the local listener, or polls the reply URL
+func (w *Webhook) Listen(ctx context.Context, sessionID string, opts ListenOptions) (*Reply, error) {
+	if !strings.HasPrefix(sessionID, "webhook-") {
+		return nil, fmt.Errorf("invalid webhook session ID %q", sessionID)
+	}
+
+	if w.pollURL != "" {
+		return wait(ctx, opts, func(ctx context.Context) (*Reply, error) {
+			return w.poll(ctx, sessionID, opts.OnReconnect)
+		})
+	}
+
+	server := w.server
+	if server == nil || server.session != sessionID {
+		var err error
+		if server, err = w.listen(sessionID); err != nil {
+			return nil, err
+		}
+	}
+	w.server = nil
+	defer server.close()
+
+	return wait(ctx, opts, func(ctx context.Context) (*Reply, error) {
+		select {
+		case answer := <-server.answers:
+			return w.reply(sessionID, answer), nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	})
+}
+
+// Health checks that the reply listener address can be bound, or that the
+// poll URL's host accepts connections
+func (w *Webhook) Health(ctx context.Context) error {
+	if w.pollURL != "" {
+		u, err := url.Parse(w.pollURL)
+		if err != nil {
+			return fmt.Errorf("invalid webhook_poll_url: %w", err)
+		}
+		host := u.Host
+		if u.Port() == "" {
+			port := "443"
+			if u.Scheme == "http" {
+				port = "80"
+			}
+			host = net.JoinHostPort(u.Hostname(), port)
+		}
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "tcp", host)
+		if err != nil {
+			return fmt.Errorf("%s unreachable: %w", host, err)
+		}
+		conn.Close()
+		return nil
+	}
+
+	ln, err := net.Listen("tcp", w.listenAddr)
+	if err != nil {
+		return fmt.Errorf("cannot listen on %s: %w", w.listenAddr, err)
+	}
+	ln.Close()
+	return nil
+}
+
+// Validate checks the shared secret. The endpoint itself is only known
+// at send time.
+func (w *Webhook) Validate(ctx context.Context) error {
+	if len(w.secret) < 16 {
+		return errors.New("webhook_secret must be at least 16 characters")
+	}
+	return nil
+}
+
+// poll fetches the poll URL until it returns an answer. 200 carries the
+// answer; 202, 204 and 404 mean none yet.
+func (w *Webhook) poll(ctx context.Context, sessionID string, onReconnect ReconnectHandler) (*Reply, error) {
+	pollURL := w.sessionPollURL(sessionID)
+	attempt := 0
+	for {
+		answer, err := w.fetchAnswer(ctx, pollURL, sessionID)
+		if answer != nil {
+			return w.reply(sessionID, answer), nil
+		}
+
+		delay := webhookPollInterval
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			var statusErr *webhookStatusError
+			if errors.As(err, &statusErr) && statusErr.status >= 400 && statusErr.status < 500 && statusErr.status != http.StatusTooManyRequests {
+				return nil, err
+			}
+			if errors.Is(err, errWebhookSignature) {
+				return nil, err
+			}
+			attempt++
+			delay = backoff.Delay(attempt, webhookPollInterval)
+			if onReconnect != nil {
+				onReconnect(attempt, delay, err)
+			}
+		} else {
+			attempt = 0
+		}
+
+		if !sleep(ctx, delay) {
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// fetchAnswer makes one poll request, signed over the session ID. An
+// answer must be signed over its body like a POSTed reply.
+func (w *Webhook) fetchAnswer(ctx context.Context, pollURL, sessionID string) (*webhookAnswer, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", pollURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set(webhookSignatureHeader, w.sign([]byte(sessionID)))
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("webhook poll failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusAccepted, http.StatusNoContent, http.StatusNotFound:
+		return nil, nil
+	default:
+		return nil, webhookError(resp)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxWebhookReplyBytes))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if !w.verify(body, resp.Header.Get(webhookSignatureHeader)) {
+		return nil, errWebhookSignature
+	}
+
+	var answer webhookAnswer
+	if err := json.Unmarshal(body, &answer); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	if answer.Session != "" && answer.Session != sessionID {
+		return nil, fmt.Errorf("webhook poll answered for session %s", answer.Session)
+	}
+	if strings.TrimSpace(answer.Reply) == "" {
+		return nil, nil
+	}
+	return &answer, nil
+}
+
+func (w *Webhook) reply(sessionID string, answer *webhookAnswer) *Reply {
+	from := answer.From
+	if from == "" {
+		from = "webhook"
+	}
+	return &Reply{
+		SessionID: sessionID,
+		From:      from,
+		Channel:   w.Name(),
+		Content:   answer.Reply,
+	}
+}
+
+// sessionPollURL fills the {session} placeholder of the poll URL, or
+// appends the session as a path segment when there is none
+func (w *Webhook) sessionPollURL(sessionID string) string {
+	if strings.Contains(w.pollURL, "{session}") {
+		return strings.ReplaceAll(w.pollURL, "{session}", url.PathEscape(sessionID))
+	}
+	return strings.TrimRight(w.pollURL, "/") + "/" + url.PathEscape(sessionID)
+}
+
+// sign returns the signature header value for body
+func (w *Webhook) sign(body []byte) string {
+	mac := hmac.New(sha256.New, []byte(w.secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// verify reports whether signature is the signature header value for body
+func (w *Webhook) verify(body []byte, signature string) bool {
+	return hmac.Equal([]byte(signature), []byte(w.sign(body)))
+}
+
+func (w *Webhook) closeServer() {
+	if w.server != nil {
+		w.server.close()
+		w.server = nil
+	}
+}
+
+// webhookServer receives the answer for one session
+type webhookServer struct {
+	session  string
+	replyURL string
+	answers  chan *webhookAnswer
+	srv      *http.Server
+}
+
+// listen starts the reply listener for a session. With the default
+// address it binds a free port, and the reply URL names that port.
+func (w *Webhook) listen(sessionID string) (*webhookServer, error) {
+	ln, err := net.Listen("tcp", w.listenAddr)
+	if err != nil {
+		return nil, fmt.Errorf("webhook reply listener: %w", err)
+	}
+
+	s := &webhookServer{
+		session:  sessionID,
+		replyURL: w.replyURL,
+		answers:  make(chan *webhookAnswer, 1),
+	}
+	if s.replyURL == "" {
+		s.replyURL = "http://" + ln.Addr().String() + "/reply"
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/reply", func(rw http.ResponseWriter, r *http.Request) {
+		w.handleReply(s, rw, r)
+	})
+	s.srv = &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go s.srv.Serve(ln)
+	return s, nil
+}
+
+// handleReply accepts a signed answer for the server's session
+func (w *Webhook) handleReply(s *webhookServer, rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookReplyBytes))
+	if err != nil {
+		http.Error(rw, "failed to read body", http.StatusBadRequest)
+		return
+	}
+	if !w.verify(body, r.Header.Get(webhookSignatureHeader)) {
+		http.Error(rw, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	var answer webhookAnswer
+	if err := json.Unmarshal(body, &answer); err != nil || strings.TrimSpace(answer.Reply) == "" {
+		http.Error(rw, `expected JSON {"session", "reply"}`, http.StatusBadRequest)
+		return
+	}
+	if answer.Session != s.session {
+		http.Error(rw, "unknown session", http.StatusNotFound)
+		return
+	}
+
+	select {
+	case s.answers <- &answer:
+		rw.Header().Set("Content-Type", "application/json")
+		io.WriteString(rw, `{"ok":true}`)
+	default:
+		http.Error(rw, "already answered", http.StatusConflict)
+	}
+}
+
+func (s *webhookServer) close() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	s.srv.Shutdown(ctx)
+}
+
+// webhookError builds an error from a non-2xx response
+func webhookError(resp *http.Response) error {
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return &webhookStatusError{status: resp.StatusCode, body: strings.TrimSpace(string(data))}
+}
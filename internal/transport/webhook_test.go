@@ -0,0 +1,159 @@
+package transport
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/davedotdev/afk/internal/config"
+)
+
+const testWebhookSecret = "0123456789abcdef-test"
+
+func newTestWebhook(t *testing.T, endpoint, pollURL string) *Webhook {
+	t.Helper()
+	w, err := NewWebhook(&config.Config{
+		WebhookURL:     endpoint,
+		WebhookSecret:  testWebhookSecret,
+		WebhookListen:  config.DefaultWebhookListen,
+		WebhookPollURL: pollURL,
+	})
+	if err != nil {
+		t.Fatalf("NewWebhook: %v", err)
+	}
+	return w
+}
+
+// webhookEndpoint records the envelopes POSTed to it
+type webhookEndpoint struct {
+	mu        sync.Mutex
+	envelopes []webhookEnvelope
+}
+
+func (e *webhookEndpoint) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	var env webhookEnvelope
+	json.NewDecoder(r.Body).Decode(&env)
+	e.mu.Lock()
+	e.envelopes = append(e.envelopes, env)
+	e.mu.Unlock()
+}
+
+func TestWebhookListenersPerSession(t *testing.T) {
+	endpoint := &webhookEndpoint{}
+	server := httptest.NewServer(endpoint)
+	defer server.Close()
+
+	// Two recipients of a broadcast wait at the same time
+	first := newTestWebhook(t, server.URL, "")
+	second := newTestWebhook(t, server.URL, "")
+	if _, err := first.Send(context.Background(), Message{Text: "Deploy?"}); err != nil {
+		t.Fatalf("first Send: %v", err)
+	}
+	r2, err := second.Send(context.Background(), Message{Text: "Deploy?"})
+	if err != nil {
+		t.Fatalf("second Send: %v", err)
+	}
+	defer first.closeServer()
+
+	endpoint.mu.Lock()
+	envelopes := endpoint.envelopes
+	endpoint.mu.Unlock()
+	if len(envelopes) != 2 || envelopes[0].ReplyURL == envelopes[1].ReplyURL {
+		t.Fatalf("envelopes %+v, want a reply URL each", envelopes)
+	}
+
+	// Answer the second on its own callback URL
+	body, _ := json.Marshal(webhookAnswer{Session: r2.SessionID, Reply: "yes", From: "bob"})
+	req, _ := http.NewRequest("POST", envelopes[1].ReplyURL, bytes.NewReader(body))
+	req.Header.Set(webhookSignatureHeader, second.sign(body))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("POST reply: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("POST reply: %s", resp.Status)
+	}
+
+	reply, err := second.Listen(context.Background(), r2.SessionID, ListenOptions{Timeout: 5 * time.Second})
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	if reply.Content != "yes" || reply.From != "bob" {
+		t.Errorf("reply = %+v", reply)
+	}
+}
+
+func TestWebhookPollSignature(t *testing.T) {
+	tests := []struct {
+		name    string
+		sign    func(w *Webhook, body []byte) string
+		wantErr error
+	}{
+		{"signed", func(w *Webhook, body []byte) string { return w.sign(body) }, nil},
+		{"unsigned", func(w *Webhook, body []byte) string { return "" }, errWebhookSignature},
+		{"other secret", func(w *Webhook, body []byte) string {
+			other := *w
+			other.secret = "someone-elses-secret"
+			return other.sign(body)
+		}, errWebhookSignature},
+		{"signed over the session only", func(w *Webhook, body []byte) string { return w.sign([]byte("webhook-00112233aabbccdd")) }, errWebhookSignature},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			const sessionID = "webhook-00112233aabbccdd"
+			var w *Webhook
+			polls := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+				if !strings.HasSuffix(r.URL.Path, "/"+sessionID) || !w.verify([]byte(sessionID), r.Header.Get(webhookSignatureHeader)) {
+					http.Error(rw, "bad poll", http.StatusBadRequest)
+					return
+				}
+				body, _ := json.Marshal(webhookAnswer{Session: sessionID, Reply: "approve", From: "alice"})
+				rw.Header().Set(webhookSignatureHeader, tt.sign(w, body))
+				rw.Write(body)
+			}))
+			defer polls.Close()
+			w = newTestWebhook(t, "", polls.URL+"/answers/{session}")
+
+			reply, err := w.Listen(context.Background(), sessionID, ListenOptions{Timeout: 5 * time.Second})
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("Listen = %+v, %v; want %v", reply, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Listen: %v", err)
+			}
+			if reply.Content != "approve" || reply.From != "alice" {
+				t.Errorf("reply = %+v", reply)
+			}
+		})
+	}
+}
+
+func TestWebhookResumable(t *testing.T) {
+	tests := []struct {
+		name      string
+		cfg       config.Config
+		resumable bool
+	}{
+		{"free port", config.Config{WebhookListen: config.DefaultWebhookListen}, false},
+		{"unset", config.Config{}, false},
+		{"fixed port", config.Config{WebhookListen: "127.0.0.1:8789"}, true},
+		{"polled", config.Config{WebhookListen: config.DefaultWebhookListen, WebhookPollURL: "https://tools.example/answers/{session}"}, true},
+	}
+	for _, tt := range tests {
+		if err := WebhookResumable(&tt.cfg); (err == nil) != tt.resumable {
+			t.Errorf("%s: WebhookResumable = %v, want resumable %v", tt.name, err, tt.resumable)
+		}
+	}
+}